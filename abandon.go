@@ -0,0 +1,40 @@
+package background
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// abandoner is implemented by Backgrounds that can give up waiting for
+// their children, see Options' Abandon.
+type abandoner interface {
+	// abandon records that the Background gave up waiting.
+	abandon()
+
+	// isAbandoned reports whether the Background gave up waiting.
+	isAbandoned() bool
+}
+
+func (g *group) abandon()          { atomic.StoreInt32(&g.abandoned, 1) }
+func (g *group) isAbandoned() bool { return atomic.LoadInt32(&g.abandoned) == 1 }
+
+func (d *dependBackground) abandon()          { atomic.StoreInt32(&d.abandoned, 1) }
+func (d *dependBackground) isAbandoned() bool { return atomic.LoadInt32(&d.abandoned) == 1 }
+
+// awaitFinish blocks until bg finishes closing and returns true.
+// If Options' Abandon is enabled and ctx is done before bg is finished,
+// it records that waiter gave up waiting for bg and returns false.
+func awaitFinish(ctx context.Context, waiter abandoner, bg Background) bool {
+	if !optionsFrom(ctx).Abandon {
+		<-bg.finishSig()
+		return true
+	}
+
+	select {
+	case <-bg.finishSig():
+		return true
+	case <-ctx.Done():
+		waiter.abandon()
+		return false
+	}
+}
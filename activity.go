@@ -0,0 +1,190 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdle is the error returned by Background.Err when the Background
+// created with WithIdleTimeout begins closing its children after
+// the idle period.
+var ErrIdle = errors.New("idle timeout")
+
+type activityBackground struct {
+	// last is the time of the last activity in Unix nanoseconds.
+	// It is accessed atomically and must stay the first field to be 64-bit
+	// aligned on 32-bit platforms.
+	last int64
+
+	*group
+
+	clock Clock
+}
+
+// ActivityTail detaches after activity Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background, e.g. a worker that touches it on every task.
+type ActivityTail interface {
+	// Touch records the activity of the job at the current time.
+	Touch()
+}
+
+// WithActivity returns new Background with merged children and ActivityTail
+// that records the activity of a job, so the idleness of the tree can be
+// found with IdleSince and acted on with WithIdleTimeout. The Background
+// is considered active at the moment of its creation.
+//
+// The activity is tracked by the clock assigned to children with WithClock
+// or by the system clock.
+func WithActivity(children ...Background) (Background, ActivityTail) {
+	a := withActivity(children...)
+	return a, a
+}
+
+func withActivity(children ...Background) *activityBackground {
+	g := merge(children...)
+	a := &activityBackground{group: g, clock: clockOf(g)}
+	a.Touch()
+
+	return a
+}
+
+func (a *activityBackground) Touch() {
+	atomic.StoreInt64(&a.last, a.clock.Now().UnixNano())
+}
+
+func (a *activityBackground) lastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&a.last))
+}
+
+func (a *activityBackground) DependsOn(children ...Background) Background {
+	return withDependency(a, children...)
+}
+
+// IdleSince returns the time of the last activity recorded by ActivityTails
+// in bg's tree, see WithActivity. The second result is false if there are
+// no Backgrounds created with WithActivity in the tree.
+func IdleSince(bg Background) (since time.Time, ok bool) {
+	walk(bg, func(bg Background) {
+		a, isActivity := bg.(*activityBackground)
+		if !isActivity {
+			return
+		}
+
+		if last := a.lastActivity(); !ok || last.After(since) {
+			since, ok = last, true
+		}
+	})
+
+	return since, ok
+}
+
+type idleBackground struct {
+	*group
+
+	idle  time.Duration
+	clock Clock
+	timer Timer
+	err   error
+
+	sync.RWMutex
+}
+
+// WithIdleTimeout returns new Background with merged children that begins
+// closing its children when no activity is recorded in their trees for
+// the idle duration, see WithActivity. It is useful for on-demand workers
+// and development environments. If there are no Backgrounds created with
+// WithActivity among the children, the children are never considered idle.
+//
+// After the idle timeout Background's Err returns ErrIdle.
+// Shutting the Background down stops tracking the idleness.
+//
+// The idleness is tracked by the clock assigned to children with WithClock
+// or by the system clock.
+func WithIdleTimeout(idle time.Duration, children ...Background) Background {
+	g := merge(children...)
+
+	b := &idleBackground{
+		group: g,
+		idle:  idle,
+		clock: clockOf(g),
+	}
+
+	b.Lock()
+	b.timer = b.clock.AfterFunc(idle, b.check)
+	b.Unlock()
+
+	return b
+}
+
+// check begins closing the children if they are idle for the idle duration,
+// otherwise it schedules the next check.
+func (b *idleBackground) check() {
+	b.Lock()
+
+	if b.IsClosing() {
+		b.Unlock()
+		return
+	}
+
+	next := b.idle
+
+	if since, ok := IdleSince(b.group); ok {
+		next = b.idle - b.clock.Now().Sub(since)
+	}
+
+	if next > 0 {
+		b.timer.Reset(next)
+		b.Unlock()
+
+		return
+	}
+
+	b.err = ErrIdle
+	b.Unlock()
+
+	errorsChanged()
+
+	ctx := withInitiator(context.Background(), b)
+	ctx = WithShutdownReason(ctx, ErrIdle)
+
+	go func() {
+		announce(ctx, b)
+		closePhases(ctx, b)
+		b.group.close(ctx)
+	}()
+}
+
+// Err returns ErrIdle if Background's children were idle for the idle
+// duration, otherwise it returns the first encountered error
+// in Background's children.
+func (b *idleBackground) Err() error {
+	b.RLock()
+	err := b.err
+	b.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return b.group.Err()
+}
+
+func (b *idleBackground) Shutdown(ctx context.Context) error {
+	return b.result.do(ctx, b, func() error { return shutdown(ctx, b) })
+}
+
+func (b *idleBackground) close(ctx context.Context) {
+	b.Lock()
+	b.timer.Stop()
+	b.Unlock()
+
+	b.group.close(ctx)
+}
+
+func (b *idleBackground) DependsOn(children ...Background) Background {
+	return withDependency(b, children...)
+}
@@ -0,0 +1,198 @@
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Shutdowner is implemented by components that can be gracefully shut down.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Waiter is implemented by components that can be waited for.
+type Waiter interface {
+	Wait()
+}
+
+// Readier is implemented by components that signal their readiness.
+type Readier interface {
+	Ready() <-chan struct{}
+}
+
+// Valuer is implemented by components that carry values.
+type Valuer interface {
+	Value(key interface{}) interface{}
+}
+
+type adapterBackground struct {
+	*group
+
+	component interface{}
+	finished  chan struct{}
+	err       error
+	ready     readySignal
+
+	// closeOnce ensures that the adapted component is shut down once.
+	closeOnce sync.Once
+
+	sync.RWMutex
+}
+
+// Adapt returns new Background built from component, allowing to integrate
+// existing components into the tree of Backgrounds.
+//
+// The component's capabilities are detected by the interfaces it implements:
+// Shutdowner, Waiter, Readier and Valuer. For every capability the component
+// lacks, the returned Background has Empty semantics.
+//
+// The component's Shutdown is called with the context of the Shutdown call
+// that initiated the closing of the Background. An error
+// returned from the component's Shutdown is returned by the Background's Err
+// after the shutdown.
+func Adapt(component interface{}) Background {
+	return adapt(component)
+}
+
+func adapt(component interface{}) *adapterBackground {
+	a := &adapterBackground{
+		group:     merge(),
+		component: component,
+		finished:  make(chan struct{}),
+	}
+
+	if _, ok := component.(Shutdowner); !ok {
+		// components that can't be shut down are considered closed
+		a.closing.fire()
+		close(a.finished)
+	}
+
+	return a
+}
+
+func (a *adapterBackground) Err() error {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.err
+}
+
+func (a *adapterBackground) Shutdown(ctx context.Context) error {
+	return a.result.do(ctx, a, func() error {
+		if err := shutdown(ctx, a); err != nil {
+			return err
+		}
+
+		return a.Err()
+	})
+}
+
+func (a *adapterBackground) Wait() {
+	if w, ok := a.component.(Waiter); ok {
+		w.Wait()
+	}
+}
+
+func (a *adapterBackground) WaitContext(ctx context.Context) error {
+	w, ok := a.component.(Waiter)
+	if !ok {
+		return nil
+	}
+
+	// the component can only be waited for with a goroutine,
+	// which stays blocked until the component is drained
+	done := make(chan struct{})
+
+	go func() {
+		w.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *adapterBackground) Ready() <-chan struct{} {
+	if r, ok := a.component.(Readier); ok {
+		return r.Ready()
+	}
+
+	return closedchan
+}
+
+func (a *adapterBackground) onReady(f func()) {
+	r, ok := a.component.(Readier)
+	if !ok {
+		f()
+		return
+	}
+
+	a.ready.follow(func(fire func()) {
+		go func() {
+			<-r.Ready()
+			fire()
+		}()
+	})
+	a.ready.subscribe(f)
+}
+
+func (a *adapterBackground) Value(key interface{}) interface{} {
+	if v, ok := a.component.(Valuer); ok {
+		return v.Value(key)
+	}
+
+	return nil
+}
+
+func (a *adapterBackground) DependsOn(children ...Background) Background {
+	return withDependency(a, children...)
+}
+
+func (a *adapterBackground) close(ctx context.Context) {
+	s, ok := a.component.(Shutdowner)
+	if !ok {
+		return
+	}
+
+	a.closeOnce.Do(func() {
+		a.closing.fire()
+
+		err := s.Shutdown(ctx)
+
+		a.Lock()
+		a.err = err
+		a.Unlock()
+
+		if err != nil {
+			errorsChanged()
+		}
+
+		close(a.finished)
+	})
+}
+
+func (a *adapterBackground) Finished() <-chan struct{} {
+	return a.finished
+}
+
+func (a *adapterBackground) IsFinished() bool {
+	return isClosed(a.finished)
+}
+
+func (a *adapterBackground) finishSig() <-chan struct{} {
+	return a.finished
+}
+
+func (a *adapterBackground) cause(ctx context.Context) error {
+	select {
+	case <-a.finished:
+		return nil
+	default:
+		return newTimeoutError(ctx, time.Time{})
+	}
+}
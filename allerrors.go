@@ -0,0 +1,99 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// MergedErrors is the error returned by Err of Backgrounds created with
+// MergeAllErrors when children fail.
+type MergedErrors struct {
+	// Errs are the errors of the failed children in the order the children
+	// were merged. The errors of the annotated children are annotated.
+	Errs []error
+}
+
+func (e *MergedErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the children's errors matches target.
+func (e *MergedErrors) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the children's errors that matches target,
+// see errors.As.
+func (e *MergedErrors) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type allErrorsBackground struct {
+	*group
+}
+
+// MergeAllErrors returns new Background with merged children, which Err
+// returns MergedErrors with the errors of all failed children instead of
+// the first encountered one. For sibling components the first error
+// is arbitrary and hides the concurrent failures, e.g.:
+//
+//	bg := background.MergeAllErrors(
+//		background.WithAnnotation("db", dbBg),
+//		background.WithAnnotation("broker", brokerBg),
+//	)
+//
+//	bg.Err() // db: connection refused; broker: connection refused
+//
+// During shutdown the children are closed simultaneously.
+func MergeAllErrors(bgs ...Background) Background {
+	return &allErrorsBackground{group: withSource(SourceMerge, merge(bgs...))}
+}
+
+// Err returns MergedErrors with the errors of all failed children,
+// or nil if none of them failed.
+func (a *allErrorsBackground) Err() error {
+	// fast path: the result of the group is cached until errors change
+	if a.group.Err() == nil {
+		return nil
+	}
+
+	var errs []error
+
+	for _, bg := range a.backgrounds {
+		if err := bg.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &MergedErrors{Errs: errs}
+}
+
+func (a *allErrorsBackground) Shutdown(ctx context.Context) error {
+	return a.result.do(ctx, a, func() error { return shutdown(ctx, a) })
+}
+
+func (a *allErrorsBackground) DependsOn(children ...Background) Background {
+	return withDependency(a, children...)
+}
@@ -3,6 +3,8 @@ package background
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"runtime"
 )
 
 type annotationBackground struct {
@@ -16,21 +18,49 @@ func WithAnnotation(message string, children ...Background) Background {
 	return withAnnotation(message, children...)
 }
 
+// WithCallerAnnotation returns new Background with merged children and
+// assigned annotation with the function, file and line of its caller, e.g.:
+//
+//	main.newProcessor (processor.go:42)
+//
+// It helps to find the constructor of similarly annotated Backgrounds.
+func WithCallerAnnotation(children ...Background) Background {
+	return withAnnotation(caller(2), children...)
+}
+
+// caller returns the description of the caller skip frames above caller's
+// own caller.
+func caller(skip int) string {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown caller"
+	}
+
+	name := "unknown function"
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+	}
+
+	return fmt.Sprintf("%s (%s:%d)", name, filepath.Base(file), line)
+}
+
 func withAnnotation(message string, children ...Background) *annotationBackground {
-	return &annotationBackground{
+	a := &annotationBackground{
 		group:      merge(children...),
 		annotation: message,
 	}
+
+	strictAnnotate(a)
+
+	return a
 }
 
 // Err returns the first encountered error in Background's children annotated
 // with background's annotation.
 // Returns nil if no errors found.
 func (a *annotationBackground) Err() error {
-	for _, m := range a.backgrounds {
-		if err := m.Err(); err != nil {
-			return fmt.Errorf("%s: %w", a.annotation, err)
-		}
+	if err := a.group.Err(); err != nil {
+		return fmt.Errorf("%s: %w", a.annotation, err)
 	}
 
 	return nil
@@ -39,19 +69,60 @@ func (a *annotationBackground) Err() error {
 // Shutdown shuts down Background's children and returns annotated shutdown error.
 // Returns nil no errors occurred.
 func (a *annotationBackground) Shutdown(ctx context.Context) error {
-	if err := a.group.Shutdown(ctx); err != nil {
+	if err := a.group.Shutdown(withInitiator(ctx, a)); err != nil {
+		return fmt.Errorf("%s: %w", a.annotation, err)
+	}
+
+	return nil
+}
+
+// ShutdownResult returns the stored annotated result of the first
+// Shutdown call that completed the shutdown.
+func (a *annotationBackground) ShutdownResult() (error, bool) {
+	err, ok := a.group.ShutdownResult()
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.annotation, err), ok
+	}
+
+	return nil, ok
+}
+
+// Reload reloads Background's children and returns annotated reload error.
+// Returns nil no errors occurred.
+func (a *annotationBackground) Reload(ctx context.Context) error {
+	if err := a.group.Reload(ctx); err != nil {
+		return fmt.Errorf("%s: %w", a.annotation, err)
+	}
+
+	return nil
+}
+
+// WaitContext waits for Background's children and returns annotated
+// context's error if ctx is done before the waiting is complete.
+func (a *annotationBackground) WaitContext(ctx context.Context) error {
+	if err := a.group.WaitContext(ctx); err != nil {
 		return fmt.Errorf("%s: %w", a.annotation, err)
 	}
 
 	return nil
 }
 
+// Value returns the Background itself if key refers to its annotation,
+// otherwise it returns value associated with key from its children.
+func (a *annotationBackground) Value(key interface{}) (value interface{}) {
+	if k, ok := key.(subtreeKey); ok && string(k) == a.annotation {
+		return a
+	}
+
+	return a.group.Value(key)
+}
+
 func (a *annotationBackground) DependsOn(children ...Background) Background {
 	return withDependency(a, children...)
 }
 
-func (a *annotationBackground) cause() error {
-	if err := a.group.cause(); err != nil {
+func (a *annotationBackground) cause(ctx context.Context) error {
+	if err := a.group.cause(ctx); err != nil {
 		return fmt.Errorf("%s: %w", a.annotation, err)
 	}
 
@@ -0,0 +1,184 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is the App's shutdown timeout used by default.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// App is an application skeleton for the common case: it assembles
+// components with Builder, runs until an error, a signal or the end
+// of the context, shuts the components down gracefully, and serves
+// liveness and readiness probes. The low-level API stays available
+// for advanced composition: any Background can be added as a component.
+//
+//	app := background.NewApp("api")
+//	app.Add("server", server).Add("db", db).AddDependency("db", "server")
+//
+//	go http.ListenAndServe(":8081", app.Probes())
+//
+//	if err := app.Run(context.Background()); err != nil {
+//		log.Fatal(err)
+//	}
+type App struct {
+	*Builder
+
+	// Name annotates errors of the application's tree if not empty.
+	Name string
+
+	// Signals are the OS signals that begin the shutdown,
+	// os.Interrupt and syscall.SIGTERM are used if it's nil.
+	Signals []os.Signal
+
+	// ShutdownTimeout limits the graceful shutdown,
+	// DefaultShutdownTimeout is used if it's zero.
+	ShutdownTimeout time.Duration
+
+	// Escalation exits the process if the shutdown doesn't complete within
+	// its Timeout, see ShutdownOrExit. Zero Timeout disables the escalation.
+	Escalation Escalation
+
+	// Logf receives messages about the application's lifecycle, e.g. log.Printf.
+	// Nothing is logged if it's nil.
+	Logf func(format string, a ...interface{})
+
+	bg Background
+	mu sync.Mutex
+}
+
+// NewApp returns new App with name and no components.
+func NewApp(name string) *App {
+	return &App{
+		Builder: NewBuilder(),
+		Name:    name,
+	}
+}
+
+// Run builds the application's tree from the added components and blocks
+// until one of the events described in Await happens, then shuts the tree
+// down and returns.
+//
+// Run returns the error of the tree if it caused the shutdown, or
+// the error of the shutdown prefixed with "shutdown: ". Shutdowns caused
+// by signals and ctx are not errors.
+func (a *App) Run(ctx context.Context) error {
+	bg, err := a.Build()
+	if err != nil {
+		return err
+	}
+
+	signals := a.Signals
+	if signals == nil {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	if a.Name != "" {
+		bg = WithAnnotation(a.Name, bg)
+	}
+
+	bg = WithSignals(signals, bg)
+
+	a.mu.Lock()
+	a.bg = bg
+	a.mu.Unlock()
+
+	a.logf("started")
+
+	reason, cause := Await(ctx, bg)
+
+	if cause != nil {
+		a.logf("shutting down: %s: %v", reason, cause)
+	} else {
+		a.logf("shutting down: %s", reason)
+	}
+
+	timeout := a.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(WithShutdownReason(context.Background(), cause), timeout)
+	defer cancel()
+
+	err = ShutdownOrExit(shutdownCtx, bg, a.Escalation)
+
+	if err != nil {
+		a.logf("shutdown failed: %v", err)
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	a.logf("stopped")
+
+	if reason == ReasonError {
+		return cause
+	}
+
+	return nil
+}
+
+// Probes returns http.Handler serving the application's probes:
+//
+// 1. /livez responds with 200 OK, or with 500 Internal Server Error and
+// the error if the tree's Err returns one.
+//
+// 2. /readyz responds with 200 OK if the tree is ready and is not closing,
+// or with 503 Service Unavailable otherwise, including before Run.
+// Degraded components, see WithHealth, don't make the tree unready:
+// they are listed in the body of 200 OK response instead.
+func (a *App) Probes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		if bg := a.background(); bg != nil {
+			if err := bg.Err(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		bg := a.background()
+		if bg == nil || bg.IsClosing() || !isClosed(bg.Ready()) {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		if degradations := Degraded(bg); len(degradations) > 0 {
+			fmt.Fprintln(w, "degraded")
+
+			for _, d := range degradations {
+				fmt.Fprintln(w, d)
+			}
+
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	return mux
+}
+
+// background returns the application's tree, nil before Run.
+func (a *App) background() Background {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.bg
+}
+
+func (a *App) logf(format string, args ...interface{}) {
+	if a.Logf != nil {
+		a.Logf(format, args...)
+	}
+}
@@ -0,0 +1,76 @@
+package background
+
+import (
+	"context"
+	"os"
+)
+
+// Reason is the reason Await returned for.
+type Reason int
+
+const (
+	// ReasonError means that an error occurred in the tree.
+	ReasonError Reason = iota + 1
+	// ReasonSignal means that an OS signal was received.
+	ReasonSignal
+	// ReasonContext means that the ctx passed to Await is done.
+	ReasonContext
+	// ReasonShutdown means that the tree began closing.
+	ReasonShutdown
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonError:
+		return "error"
+	case ReasonSignal:
+		return "signal"
+	case ReasonContext:
+		return "context"
+	case ReasonShutdown:
+		return "shutdown"
+	default:
+		return "unknown"
+	}
+}
+
+// Await blocks until one of the following happens and reports which:
+//
+// 1. bg's Err returns an error - Await returns ReasonError and the error.
+//
+// 2. An OS signal is received by Background created with WithSignals
+// in bg's tree - Await returns ReasonSignal and *SignalError.
+//
+// 3. ctx is done - Await returns ReasonContext and ctx's error.
+//
+// 4. bg began closing - Await returns ReasonShutdown and nil.
+//
+// Await is supposed to be used in main functions, the returned error can be
+// passed to the shutdown as its reason:
+//
+//	_, err := background.Await(ctx, bg)
+//	bg.Shutdown(background.WithShutdownReason(shutdownCtx, err))
+func Await(ctx context.Context, bg Background) (Reason, error) {
+	var signals <-chan os.Signal
+	if s, ok := bg.Value(signalsKey{}).(*signalBackground); ok {
+		signals = s.c
+	}
+
+	for {
+		changed := errorsChangedSig()
+
+		if err := bg.Err(); err != nil {
+			return ReasonError, err
+		}
+
+		select {
+		case <-changed:
+		case sig := <-signals:
+			return ReasonSignal, &SignalError{Signal: sig}
+		case <-ctx.Done():
+			return ReasonContext, ctx.Err()
+		case <-bg.Closing():
+			return ReasonShutdown, nil
+		}
+	}
+}
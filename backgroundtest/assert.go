@@ -0,0 +1,89 @@
+package backgroundtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+// AssertShutsDownWithin shuts down bg and fails the test if the shutdown
+// is not complete within d or returns an error.
+// Returns true if the shutdown is complete.
+func AssertShutsDownWithin(t testing.TB, bg background.Background, d time.Duration) bool {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Errorf("Background didn't shut down within %v: %v", d, err)
+		return false
+	}
+
+	return true
+}
+
+// AssertReadyWithin fails the test if bg is not ready within d.
+// Returns true if bg is ready.
+func AssertReadyWithin(t testing.TB, bg background.Background, d time.Duration) bool {
+	t.Helper()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-bg.Ready():
+		return true
+	case <-timer.C:
+		t.Errorf("Background isn't ready within %v", d)
+		return false
+	}
+}
+
+// shutdownOrderTimeout limits the shutdown of AssertShutdownOrder.
+const shutdownOrderTimeout = 10 * time.Second
+
+// AssertShutdownOrder shuts down bg and fails the test if the named
+// Backgrounds in want don't finish closing in the order they are listed.
+// The names are the annotations on the paths to the Backgrounds joined
+// with ": ", the same way as in background.Snapshot, e.g. "api: db".
+// The named Backgrounds not listed in want are ignored, so only
+// the Backgrounds with defined order should be listed.
+//
+// The shutdown fails the test if it doesn't complete within 10 seconds.
+// Returns true if the order matches.
+func AssertShutdownOrder(t testing.TB, bg background.Background, want []string) bool {
+	t.Helper()
+
+	var (
+		size   = 3*len(background.Snapshot(bg).Nodes) + 3
+		logged = background.WithEventLog(size, bg)
+		listed = make(map[string]struct{}, len(want))
+	)
+
+	for _, name := range want {
+		listed[name] = struct{}{}
+	}
+
+	if !AssertShutsDownWithin(t, logged, shutdownOrderTimeout) {
+		return false
+	}
+
+	var have []string
+
+	for _, e := range background.Events(logged) {
+		if _, ok := listed[e.Name]; ok && e.Kind == background.EventFinished {
+			have = append(have, e.Name)
+		}
+	}
+
+	if strings.Join(have, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("wrong shutdown order, want %q, have %q", want, have)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+// Package backgroundtest provides utilities for testing code that uses
+// package background.
+package backgroundtest
+
+import (
+	"testing"
+
+	"github.com/lefelys/background"
+)
+
+// VerifyNone starts tracking shutdown Backgrounds and returns a function that
+// fails the test if any shutdown Background created after the VerifyNone
+// call was never shut down. The failure message includes creation stack
+// traces of the leaked Backgrounds.
+//
+// VerifyNone is supposed to be used with defer at the beginning of a test:
+//
+//	func TestServer(t *testing.T) {
+//		defer backgroundtest.VerifyNone(t)()
+//
+//		/*...*/
+//	}
+//
+// As all Backgrounds created during the tracking are verified, tests using
+// VerifyNone should not run in parallel with other tests creating Backgrounds.
+func VerifyNone(t testing.TB) func() {
+	stop := background.TrackLeaks()
+
+	return func() {
+		t.Helper()
+
+		for _, leak := range stop() {
+			t.Errorf("shutdown Background was never shut down, created at:\n%s", leak.Stack)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package backgroundtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+const failTimeout = 100 * time.Millisecond
+
+// recorder records test failures instead of failing the test.
+type recorder struct {
+	testing.TB
+
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestVerifyNone(t *testing.T) {
+	r := &recorder{TB: t}
+	verify := VerifyNone(r)
+
+	bg, tail := background.WithShutdown()
+
+	go func() {
+		<-tail.End()
+		tail.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	verify()
+
+	if r.failed {
+		t.Errorf("shut down Background reported as leaked")
+	}
+}
+
+func TestVerifyNoneLeak(t *testing.T) {
+	r := &recorder{TB: t}
+	verify := VerifyNone(r)
+
+	_, tail := background.WithShutdown()
+
+	verify()
+
+	// ended only after the check not to be reported as dropped in strict mode
+	tail.End()
+
+	if !r.failed {
+		t.Errorf("leaked Background wasn't reported")
+	}
+}
+
+func TestShutdownTail(t *testing.T) {
+	tail := NewShutdownTail()
+	tail.Add(1)
+
+	go func() {
+		<-tail.End()
+		tail.Done()
+		tail.Done()
+	}()
+
+	tail.Shutdown(context.Background())
+	tail.Shutdown(context.Background())
+
+	select {
+	case <-tail.Finished():
+	case <-time.After(failTimeout):
+		t.Errorf("shutdown tail didn't finish")
+	}
+}
+
+func TestReadinessTail(t *testing.T) {
+	tail := NewReadinessTail()
+
+	select {
+	case <-tail.Ready():
+		t.Errorf("readiness tail initialized as ready")
+	default:
+	}
+
+	tail.Ok()
+	tail.Ok()
+
+	select {
+	case <-tail.Ready():
+	default:
+		t.Errorf("readiness tail isn't ready")
+	}
+}
+
+func TestTailClose(t *testing.T) {
+	var (
+		shutdownTail  = NewShutdownTail()
+		readinessTail = NewReadinessTail()
+	)
+
+	shutdownTail.Add(1)
+	shutdownTail.Close()
+	readinessTail.Close()
+
+	select {
+	case <-shutdownTail.Finished():
+	default:
+		t.Errorf("closed shutdown tail isn't finished")
+	}
+
+	select {
+	case <-readinessTail.Ready():
+	default:
+		t.Errorf("closed readiness tail isn't ready")
+	}
+}
+
+func TestErrTail(t *testing.T) {
+	tail := NewErrTail()
+
+	tail.Errorf("error %d", 1)
+	tail.Errorf("error %d", 2)
+
+	if err := tail.Err(); err == nil || err.Error() != "error 1" {
+		t.Errorf("wrong error, want 'error 1', have '%v'", err)
+	}
+}
+
+func TestAssertWithin(t *testing.T) {
+	r := &recorder{TB: t}
+
+	bg, tail := background.WithReadiness()
+
+	if AssertReadyWithin(r, bg, failTimeout) || !r.failed {
+		t.Errorf("not ready Background passed the assertion")
+	}
+
+	tail.Ok()
+
+	r.failed = false
+
+	if !AssertReadyWithin(r, bg, failTimeout) || r.failed {
+		t.Errorf("ready Background failed the assertion")
+	}
+
+	bg, _ = background.WithShutdown()
+
+	if AssertShutsDownWithin(r, bg, failTimeout) || !r.failed {
+		t.Errorf("blocked Background passed the assertion")
+	}
+
+	r.failed = false
+
+	if !AssertShutsDownWithin(r, background.Empty(), failTimeout) || r.failed {
+		t.Errorf("empty Background failed the assertion")
+	}
+}
+
+func TestClockDeadline(t *testing.T) {
+	clock := NewClock(time.Now())
+
+	bg := background.WithTimeout(time.Hour, background.WithClock(clock))
+
+	clock.Advance(time.Hour - time.Nanosecond)
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error before deadline: %v", err)
+	}
+
+	clock.Advance(time.Nanosecond)
+
+	if err := bg.Err(); !errors.Is(err, background.ErrDeadlineExceeded) {
+		t.Errorf("wrong error, want '%v', have '%v'", background.ErrDeadlineExceeded, err)
+	}
+}
+
+func TestClockTimer(t *testing.T) {
+	clock := NewClock(time.Now())
+
+	timer1 := clock.NewTimer(time.Second)
+	timer2 := clock.NewTimer(time.Second)
+	after := clock.After(2 * time.Second)
+
+	if !timer2.Stop() {
+		t.Errorf("active timer wasn't stopped")
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case <-timer1.C():
+	default:
+		t.Errorf("timer didn't fire")
+	}
+
+	select {
+	case <-timer2.C():
+		t.Errorf("stopped timer fired")
+	case <-after:
+		t.Errorf("timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case <-after:
+	default:
+		t.Errorf("timer didn't fire")
+	}
+}
+
+// countRuns returns a trigger function that sends on runs after every run.
+func countRuns(runs chan struct{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}
+}
+
+// assertRuns checks that exactly n runs happen.
+func assertRuns(t *testing.T, runs chan struct{}, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-runs:
+		case <-time.After(failTimeout):
+			t.Fatalf("want %d runs, have %d", n, i)
+		}
+	}
+
+	select {
+	case <-runs:
+		t.Fatalf("want %d runs, have more", n)
+	case <-time.After(failTimeout / 10):
+	}
+}
+
+func TestClockTriggerEvery(t *testing.T) {
+	var (
+		clock = NewClock(time.Now())
+		runs  = make(chan struct{}, 10)
+	)
+
+	bg, tail := background.WithTriggerPolicy(background.TriggerEvery(time.Minute), countRuns(runs), background.WithClock(clock))
+	defer bg.Shutdown(context.Background())
+
+	tail.Trigger()
+	assertRuns(t, runs, 1)
+
+	tail.Trigger()
+	tail.Trigger()
+	assertRuns(t, runs, 0)
+
+	clock.Advance(time.Minute)
+	assertRuns(t, runs, 1)
+}
+
+func TestClockDebounceFor(t *testing.T) {
+	var (
+		clock = NewClock(time.Now())
+		runs  = make(chan struct{}, 10)
+	)
+
+	bg, tail := background.WithTriggerPolicy(background.DebounceFor(time.Second), countRuns(runs), background.WithClock(clock))
+	defer bg.Shutdown(context.Background())
+
+	tail.Trigger()
+	clock.Advance(time.Second / 2)
+	tail.Trigger()
+	clock.Advance(time.Second / 2)
+	assertRuns(t, runs, 0)
+
+	clock.Advance(time.Second / 2)
+	assertRuns(t, runs, 1)
+}
+
+func TestClockStartupRetry(t *testing.T) {
+	var (
+		clock    = NewClock(time.Now())
+		attempts int32
+	)
+
+	bg := background.WithStartupRetry(background.RetryPolicy{InitialBackoff: time.Minute, Clock: clock},
+		func(ctx context.Context) (background.Background, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return nil, errors.New("test")
+			}
+
+			return background.WithValue("key", "value"), nil
+		})
+	defer bg.Shutdown(context.Background())
+
+	parent := background.Merge(bg)
+
+	// the value isn't there before the start, and the lookup
+	// mustn't hide it from the parent after the start
+	if value := parent.Value("key"); value != nil {
+		t.Errorf("unexpected value: %v", value)
+	}
+
+	deadline := time.After(failTimeout)
+
+	// the timer of the backoff may be not set yet, so the clock
+	// is advanced until the retry
+	for atomic.LoadInt32(&attempts) < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("start is not retried after the backoff")
+		case <-time.After(time.Millisecond):
+			clock.Advance(time.Minute)
+		}
+	}
+
+	AssertReadyWithin(t, parent, failTimeout)
+
+	if value := parent.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
+}
+
+func TestMock(t *testing.T) {
+	var (
+		err  = errors.New("test")
+		mock = NewMock()
+		bg   = background.WithAnnotation("mock", mock)
+	)
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	mock.SetErr(err)
+
+	if haveErr := bg.Err(); !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	mock.SetValue("key", "value")
+
+	if value := bg.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
+
+	mock.SetReady()
+	AssertReadyWithin(t, bg, failTimeout)
+
+	mock.BlockShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); !errors.Is(err, background.ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+
+	select {
+	case <-mock.ShutdownRequested():
+	default:
+		t.Errorf("shutdown wasn't requested")
+	}
+
+	mock.UnblockShutdown()
+
+	AssertShutsDownWithin(t, mock, failTimeout)
+}
+
+func TestDrainTail(t *testing.T) {
+	tail := NewDrainTail()
+
+	tail.StartDrain()
+
+	select {
+	case <-tail.Drain():
+	default:
+		t.Errorf("drain tail didn't drain")
+	}
+
+	select {
+	case <-tail.End():
+		t.Errorf("drain tail ended before shutdown")
+	default:
+	}
+
+	tail.Shutdown(context.Background())
+	tail.Done()
+
+	select {
+	case <-tail.Finished():
+	case <-time.After(failTimeout):
+		t.Errorf("drain tail didn't finish")
+	}
+}
+
+func TestAssertShutdownOrder(t *testing.T) {
+	newTree := func() background.Background {
+		bg1, tail1 := background.WithShutdown()
+		bg2, tail2 := background.WithShutdown()
+
+		for _, tail := range []background.ShutdownTail{tail1, tail2} {
+			go func(tail background.ShutdownTail) {
+				<-tail.End()
+				tail.Done()
+			}(tail)
+		}
+
+		return background.WithAnnotation("server", bg1).DependsOn(background.WithAnnotation("db", bg2))
+	}
+
+	r := &recorder{TB: t}
+
+	if !AssertShutdownOrder(r, newTree(), []string{"db", "server"}) || r.failed {
+		t.Errorf("right shutdown order failed the assertion")
+	}
+
+	if AssertShutdownOrder(r, newTree(), []string{"server", "db"}) || !r.failed {
+		t.Errorf("wrong shutdown order passed the assertion")
+	}
+}
+
+func TestChaos(t *testing.T) {
+	err := errors.New("test")
+
+	r := &recorder{TB: t}
+	if AssertShutsDownWithin(r, StuckShutdown(), failTimeout/10) || !r.failed {
+		t.Errorf("stuck Background shut down")
+	}
+
+	start := time.Now()
+	if !AssertShutsDownWithin(t, SlowShutdown(failTimeout/10), failTimeout) || time.Since(start) < failTimeout/10 {
+		t.Errorf("slow Background shut down too fast")
+	}
+
+	bg := FailsShutdown(err)
+	if bg.Err() != nil {
+		t.Errorf("Background failed before shutdown")
+	}
+
+	AssertShutsDownWithin(t, bg, failTimeout)
+
+	if haveErr := bg.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	bg = FailsReadiness(err)
+
+	r.failed = false
+	if AssertReadyWithin(r, bg, failTimeout/10) || !r.failed {
+		t.Errorf("failing Background is ready")
+	}
+
+	if haveErr := bg.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	AssertShutsDownWithin(t, bg, failTimeout)
+}
+
+func TestSoak(t *testing.T) {
+	Soak(t, time.Now().UnixNano(), 50)
+}
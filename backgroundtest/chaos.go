@@ -0,0 +1,55 @@
+package backgroundtest
+
+import (
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+// StuckShutdown returns a ready Background whose shutdown never completes,
+// for testing shutdown timeouts and escalation. Its shutdown is reported
+// as leaked by VerifyNone.
+func StuckShutdown() background.Background {
+	bg, _ := background.WithShutdown()
+	return bg
+}
+
+// SlowShutdown returns a ready Background whose shutdown completes d after
+// it's requested, for testing shutdown budgets.
+func SlowShutdown(d time.Duration) background.Background {
+	bg, tail := background.WithShutdown()
+
+	go func() {
+		<-tail.End()
+		time.Sleep(d)
+		tail.Done()
+	}()
+
+	return bg
+}
+
+// FailsShutdown returns a ready Background whose shutdown completes
+// with err returned by its Err, for testing shutdown error paths.
+func FailsShutdown(err error) background.Background {
+	errBg, errs := background.WithErrorGroup()
+	bg, tail := background.WithShutdown(errBg)
+
+	go func() {
+		<-tail.End()
+		errs.Error(err)
+		tail.Done()
+	}()
+
+	return bg
+}
+
+// FailsReadiness returns a Background that never becomes ready and returns
+// err from its Err right away, for testing startup error paths. Its shutdown
+// completes as soon as it's requested.
+func FailsReadiness(err error) background.Background {
+	readyBg, _ := background.WithReadiness()
+	bg, errs := background.WithErrorGroup(readyBg)
+	errs.Error(err)
+
+	return bg
+}
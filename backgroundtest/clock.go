@@ -0,0 +1,148 @@
+package backgroundtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+var _ background.Clock = (*Clock)(nil)
+
+// Clock is a deterministic background.Clock for tests. Its time changes only
+// with Advance calls.
+//
+// Assign it to a tree with background.WithClock.
+type Clock struct {
+	now    time.Time
+	timers []*timer
+
+	mu sync.Mutex
+}
+
+// NewClock returns new Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once it is advanced
+// by at least d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer that fires once the clock is advanced by at least d.
+func (c *Clock) NewTimer(d time.Duration) background.Timer {
+	return c.addTimer(d, nil)
+}
+
+// AfterFunc returns a Timer that calls f once the clock is advanced by
+// at least d. Unlike time.AfterFunc, f is called synchronously by Advance.
+func (c *Clock) AfterFunc(d time.Duration, f func()) background.Timer {
+	return c.addTimer(d, f)
+}
+
+// Advance moves the clock forward by d and fires all timers that expire
+// within the new time in the order of their expiration.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+
+	c.now = c.now.Add(d)
+
+	var due []*timer
+
+	active := c.timers[:0]
+
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			active = append(active, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+
+	c.timers = active
+	now := c.now
+
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+
+	for _, t := range due {
+		t.fire(now)
+	}
+}
+
+func (c *Clock) addTimer(d time.Duration, f func()) *timer {
+	t := &timer{
+		clock: c,
+		c:     make(chan time.Time, 1),
+		f:     f,
+	}
+
+	t.Reset(d)
+
+	return t
+}
+
+// remove removes t from active timers and returns true if t was active.
+func (c *Clock) remove(t *timer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, active := range c.timers {
+		if active == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+type timer struct {
+	clock *Clock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	active := t.clock.remove(t)
+
+	t.clock.mu.Lock()
+	t.when = t.clock.now.Add(d)
+	t.clock.timers = append(t.clock.timers, t)
+	t.clock.mu.Unlock()
+
+	return active
+}
+
+func (t *timer) fire(now time.Time) {
+	if t.f != nil {
+		t.f()
+		return
+	}
+
+	select {
+	case t.c <- now:
+	default:
+	}
+}
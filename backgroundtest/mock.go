@@ -0,0 +1,128 @@
+package backgroundtest
+
+import (
+	"sync"
+
+	"github.com/lefelys/background"
+)
+
+// Mock is a Background with programmable behavior for testing code that
+// consumes Backgrounds.
+//
+// Mock is built from real Backgrounds, so it behaves consistently when merged
+// into trees, set as dependency or annotated.
+//
+// New Mock has no error and values, is not ready and completes its shutdown
+// as soon as it is requested.
+type Mock struct {
+	background.Background
+
+	readiness background.ReadinessTail
+	shutdown  background.ShutdownTail
+	errs      background.ErrTail
+
+	end       <-chan struct{}
+	unblocked chan struct{}
+	blocked   bool
+	values    map[interface{}]interface{}
+
+	mu sync.RWMutex
+}
+
+// NewMock returns new Mock.
+func NewMock() *Mock {
+	var (
+		rbg, readiness = background.WithReadiness()
+		sbg, shutdown  = background.WithShutdown()
+		bg, errs       = background.WithErrorGroup(rbg, sbg)
+	)
+
+	m := &Mock{
+		Background: bg,
+		readiness:  readiness,
+		shutdown:   shutdown,
+		errs:       errs,
+		end:        shutdown.End(),
+		unblocked:  make(chan struct{}),
+		values:     make(map[interface{}]interface{}),
+	}
+
+	close(m.unblocked)
+
+	go func() {
+		<-m.end
+
+		m.mu.RLock()
+		unblocked := m.unblocked
+		m.mu.RUnlock()
+
+		<-unblocked
+		m.shutdown.Done()
+	}()
+
+	return m
+}
+
+// SetErr sets the error returned by Mock's Err.
+// If the Mock already has an error - does nothing.
+func (m *Mock) SetErr(err error) {
+	m.errs.Error(err)
+}
+
+// SetReady makes Mock ready.
+func (m *Mock) SetReady() {
+	m.readiness.Ok()
+}
+
+// SetValue associates value with key in Mock.
+// As Backgrounds cache values, it should be called before the Mock's values
+// are looked up by its parents.
+func (m *Mock) SetValue(key, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = value
+}
+
+// Value returns value associated with key by SetValue, or nil.
+func (m *Mock) Value(key interface{}) interface{} {
+	m.mu.RLock()
+	value, ok := m.values[key]
+	m.mu.RUnlock()
+
+	if ok {
+		return value
+	}
+
+	return m.Background.Value(key)
+}
+
+// BlockShutdown makes the requested shutdown of Mock block until
+// UnblockShutdown is called. It must be called before the shutdown
+// is requested.
+func (m *Mock) BlockShutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.blocked {
+		m.blocked = true
+		m.unblocked = make(chan struct{})
+	}
+}
+
+// UnblockShutdown completes the shutdown blocked by BlockShutdown.
+func (m *Mock) UnblockShutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.blocked {
+		m.blocked = false
+		close(m.unblocked)
+	}
+}
+
+// ShutdownRequested returns a channel that's closed when the shutdown of
+// Mock is requested by its Shutdown method or by its parent.
+func (m *Mock) ShutdownRequested() <-chan struct{} {
+	return m.end
+}
@@ -0,0 +1,200 @@
+package backgroundtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+// soakTimeout limits every operation of Soak, exceeding it means
+// a deadlock.
+const soakTimeout = 10 * time.Second
+
+// RandomTree returns a random tree of about size Backgrounds of various kinds
+// built with r: shutdown, readiness, wait and error group Backgrounds with
+// jobs that complete after random short delays, combined with annotations,
+// values, Merge functions and dependencies. Backgrounds are shared between
+// multiple parents occasionally. All jobs complete when the tree is shut
+// down, so the shutdown of the tree is expected to complete.
+func RandomTree(r *rand.Rand, size int) background.Background {
+	// the jobs draw their delays concurrently, so they get their own source
+	t := &treeBuilder{r: rand.New(rand.NewSource(r.Int63()))}
+
+	var nodes []background.Background
+
+	for i := 0; i < size; i++ {
+		var children []background.Background
+
+		// take up to 3 of the existing nodes as children,
+		// occasionally sharing them with the existing parents
+		for n := t.intn(4); n > 0 && len(nodes) > 0; n-- {
+			j := t.intn(len(nodes))
+			children = append(children, nodes[j])
+
+			if t.intn(5) != 0 {
+				nodes = append(nodes[:j], nodes[j+1:]...)
+			}
+		}
+
+		nodes = append(nodes, t.node(i, children))
+	}
+
+	return background.Merge(nodes...)
+}
+
+type treeBuilder struct {
+	// r is guarded by mu, as rand.Rand is not safe for concurrent use.
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// intn returns a random number in [0, n).
+func (t *treeBuilder) intn(n int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.r.Intn(n)
+}
+
+// delay returns a random delay up to a millisecond.
+func (t *treeBuilder) delay() time.Duration {
+	return time.Duration(t.intn(1000)) * time.Microsecond
+}
+
+// node returns a random Background with children.
+func (t *treeBuilder) node(i int, children []background.Background) background.Background {
+	switch t.intn(10) {
+	case 0:
+		bg, tail := background.WithShutdown(children...)
+
+		go func() {
+			<-tail.End()
+			time.Sleep(t.delay())
+			tail.Done()
+		}()
+
+		return bg
+	case 1:
+		bg, tail := background.WithReadiness(children...)
+
+		// the tree may be shut down before the job is ready, and unlike Ok,
+		// Close can be called after that in strict mode
+		go func() {
+			time.Sleep(t.delay())
+			tail.Close()
+		}()
+
+		return bg
+	case 2:
+		bg, tail := background.WithWait(children...)
+		tail.Add(1)
+
+		go func() {
+			time.Sleep(t.delay())
+			tail.Done()
+		}()
+
+		return bg
+	case 3:
+		bg, tail := background.WithErrorGroup(children...)
+
+		if t.intn(4) == 0 {
+			tail.Errorf("node %d", i)
+		}
+
+		return bg
+	case 4:
+		return background.WithAnnotation(fmt.Sprintf("node %d", i), children...)
+	case 5:
+		return background.WithValue(i, i, children...)
+	case 6:
+		return background.MergeSequential(children...)
+	case 7:
+		return background.MergeReverse(children...)
+	case 8:
+		return background.MergeWithLimit(1, children...)
+	default:
+		if len(children) == 0 {
+			return background.Empty()
+		}
+
+		return children[0].DependsOn(children[1:]...)
+	}
+}
+
+// Soak builds n random trees with RandomTree seeded by seed and exercises
+// every tree with concurrent Shutdown, Wait, Ready, Err, Value and Snapshot
+// calls, including the shutdowns of its subtrees, to shake out deadlocks
+// and races, preferably under the race detector. It fails the test with
+// the seed of the failed tree if any operation doesn't complete in time.
+func Soak(t testing.TB, seed int64, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		treeSeed := seed + int64(i)
+
+		if !soak(rand.New(rand.NewSource(treeSeed))) {
+			t.Errorf("tree with seed %d deadlocked", treeSeed)
+			return
+		}
+	}
+}
+
+// soak exercises a random tree built with r and returns false if
+// the operations didn't complete in time.
+func soak(r *rand.Rand) bool {
+	var (
+		bg    = RandomTree(r, 1+r.Intn(30))
+		nodes = background.Snapshot(bg).Nodes
+		subs  = make([]background.Background, 0, 3)
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < 3 && len(nodes) > 0; i++ {
+		if node, ok := background.FindByID(bg, nodes[r.Intn(len(nodes))].ID); ok {
+			subs = append(subs, node.Background)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), soakTimeout)
+	defer cancel()
+
+	ops := []func(){
+		func() { _ = bg.Shutdown(ctx) },
+		func() { _ = bg.Shutdown(ctx) },
+		func() { _ = bg.WaitContext(ctx) },
+		func() {
+			select {
+			case <-bg.Ready():
+			case <-bg.Finished():
+			case <-ctx.Done():
+			}
+		},
+		func() { _ = bg.Err() },
+		func() { _ = bg.Value(0) },
+		func() { _ = background.Snapshot(bg) },
+	}
+
+	for _, sub := range subs {
+		sub := sub
+		ops = append(ops, func() { _ = sub.Shutdown(ctx) })
+	}
+
+	wg.Add(len(ops))
+
+	for _, op := range ops {
+		go func(op func()) {
+			defer wg.Done()
+			op()
+		}(op)
+	}
+
+	wg.Wait()
+
+	return ctx.Err() == nil
+}
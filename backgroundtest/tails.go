@@ -0,0 +1,261 @@
+package backgroundtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lefelys/background"
+)
+
+var (
+	_ background.ShutdownTail  = (*ShutdownTail)(nil)
+	_ background.ReadinessTail = (*ReadinessTail)(nil)
+	_ background.ErrTryTail    = (*ErrTail)(nil)
+	_ background.DrainTail     = (*DrainTail)(nil)
+)
+
+// ShutdownTail is a controllable fake background.ShutdownTail for testing
+// background jobs without building a Background.
+type ShutdownTail struct {
+	end      chan struct{}
+	finished chan struct{}
+	pending  int
+	ctx      context.Context
+
+	mu sync.Mutex
+}
+
+// NewShutdownTail returns new ShutdownTail.
+func NewShutdownTail() *ShutdownTail {
+	return &ShutdownTail{
+		end:      make(chan struct{}),
+		finished: make(chan struct{}),
+		pending:  1,
+	}
+}
+
+// End returns a channel that's closed when Shutdown is called.
+func (s *ShutdownTail) End() <-chan struct{} {
+	return s.end
+}
+
+// Done sends a signal that a shutdown is complete.
+// It follows the same counting rules as background.ShutdownTail's Done.
+func (s *ShutdownTail) Done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.finished:
+		// Already finished
+	default:
+		s.pending--
+		if s.pending <= 0 {
+			close(s.finished)
+		}
+	}
+}
+
+// Close completes the shutdown regardless of pending Done calls.
+func (s *ShutdownTail) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.finished:
+		// Already finished
+	default:
+		s.pending = 0
+		close(s.finished)
+	}
+}
+
+// Add adds delta to the number of Done calls required to complete the shutdown.
+func (s *ShutdownTail) Add(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.finished:
+		// Already finished
+	default:
+		s.pending += delta
+	}
+}
+
+// Context returns the context passed to Shutdown or context.Background()
+// before the Shutdown call.
+func (s *ShutdownTail) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
+// CloseInfo returns CloseInfo of the context passed to Shutdown or zero
+// CloseInfo before the Shutdown call.
+func (s *ShutdownTail) CloseInfo() background.CloseInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return background.CloseInfo{}
+	}
+
+	return background.CloseInfoFrom(s.ctx)
+}
+
+// Shutdown closes the End channel and sets the tail's Context to ctx.
+// After the first call, subsequent calls do nothing.
+func (s *ShutdownTail) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.end:
+		// Already closed
+	default:
+		s.ctx = ctx
+		close(s.end)
+	}
+}
+
+// Finished returns a channel that's closed when the shutdown is complete.
+func (s *ShutdownTail) Finished() <-chan struct{} {
+	return s.finished
+}
+
+// DrainTail is a controllable fake background.DrainTail for testing
+// two-phase background jobs without building a Background.
+type DrainTail struct {
+	*ShutdownTail
+
+	drain chan struct{}
+	once  sync.Once
+}
+
+// NewDrainTail returns new DrainTail.
+func NewDrainTail() *DrainTail {
+	return &DrainTail{
+		ShutdownTail: NewShutdownTail(),
+		drain:        make(chan struct{}),
+	}
+}
+
+// Drain returns a channel that's closed when StartDrain or Shutdown is called.
+func (d *DrainTail) Drain() <-chan struct{} {
+	return d.drain
+}
+
+// StartDrain closes the Drain channel.
+// After the first call, subsequent calls do nothing.
+func (d *DrainTail) StartDrain() {
+	d.once.Do(func() { close(d.drain) })
+}
+
+// Shutdown closes the Drain channel if it's not closed yet, then closes
+// the End channel and sets the tail's Context to ctx.
+// After the first call, subsequent calls do nothing.
+func (d *DrainTail) Shutdown(ctx context.Context) {
+	d.StartDrain()
+	d.ShutdownTail.Shutdown(ctx)
+}
+
+// ReadinessTail is a controllable fake background.ReadinessTail for testing
+// background jobs without building a Background.
+type ReadinessTail struct {
+	ready chan struct{}
+
+	mu sync.Mutex
+}
+
+// NewReadinessTail returns new ReadinessTail.
+func NewReadinessTail() *ReadinessTail {
+	return &ReadinessTail{
+		ready: make(chan struct{}),
+	}
+}
+
+// Ok sends a signal that background job is ready.
+// After the first call, subsequent calls do nothing.
+func (r *ReadinessTail) Ok() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.ready:
+		// Already ready
+	default:
+		close(r.ready)
+	}
+}
+
+// Close sends a signal that background job is ready, the same as Ok.
+func (r *ReadinessTail) Close() {
+	r.Ok()
+}
+
+// Ready returns a channel that's closed when Ok or Close is called.
+func (r *ReadinessTail) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// ErrTail is a fake background.ErrTryTail for testing background jobs
+// without building a Background.
+type ErrTail struct {
+	err error
+
+	mu sync.RWMutex
+}
+
+// NewErrTail returns new ErrTail.
+func NewErrTail() *ErrTail {
+	return &ErrTail{}
+}
+
+// Error assigns err to the tail.
+// If the tail already has an error - does nothing.
+func (e *ErrTail) Error(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// TryError assigns err to the tail and reports whether it was assigned.
+// If the tail already has an error or err is nil - does nothing
+// and returns false.
+func (e *ErrTail) TryError(err error) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.err != nil || err == nil {
+		return false
+	}
+
+	e.err = err
+
+	return true
+}
+
+// Errorf formats according to a format specifier and assigns
+// the string to the tail as a value that satisfies error.
+// If the tail already has an error - does nothing.
+func (e *ErrTail) Errorf(format string, a ...interface{}) {
+	e.Error(fmt.Errorf(format, a...))
+}
+
+// Err returns the error assigned to the tail.
+func (e *ErrTail) Err() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.err
+}
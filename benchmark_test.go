@@ -0,0 +1,99 @@
+package background
+
+import (
+	"context"
+	"runtime"
+	"testing"
+)
+
+const benchmarkWidth = 1000
+
+func BenchmarkMerge(b *testing.B) {
+	children := make([]Background, benchmarkWidth)
+	for i := range children {
+		children[i], _ = WithShutdown()
+	}
+
+	before := runtime.NumGoroutine()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = merge(children...)
+	}
+
+	b.StopTimer()
+
+	b.ReportMetric(float64(runtime.NumGoroutine()-before)/float64(b.N), "goroutines/op")
+}
+
+func BenchmarkGroupClose(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		children := make([]Background, benchmarkWidth)
+		for j := range children {
+			bg := withShutdown()
+			close(runShutdownable(bg))
+			children[j] = bg
+		}
+
+		g := merge(children...)
+
+		b.StartTimer()
+
+		g.close(context.Background())
+	}
+}
+
+// deepTree returns a tree of depth levels with width error Backgrounds
+// merged on every level.
+func deepTree(depth, width int) Background {
+	bg := Empty()
+
+	for i := 0; i < depth; i++ {
+		children := make([]Background, 0, width+1)
+		children = append(children, bg)
+
+		for j := 0; j < width; j++ {
+			children = append(children, WithError(nil))
+		}
+
+		bg = Merge(children...)
+	}
+
+	return bg
+}
+
+func BenchmarkDeepMergeErr(b *testing.B) {
+	bg := deepTree(100, 10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = bg.Err()
+	}
+}
+
+func BenchmarkDeepMergeValue(b *testing.B) {
+	bg := deepTree(100, 10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = bg.Value("key")
+	}
+}
+
+func BenchmarkDeepMergeReady(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		<-deepTree(100, 10).Ready()
+	}
+}
@@ -0,0 +1,185 @@
+package background
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDependencyCycle is the error returned by Builder's Build when
+// dependencies between components form a cycle.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// Builder assembles a tree of Backgrounds from named components and
+// dependencies between them. It validates the graph before building,
+// which keeps assembling of applications with many components readable.
+//
+// Builder's methods return the Builder to allow chaining. Errors are
+// accumulated and returned by Build.
+//
+// Builder is not safe for concurrent use.
+type Builder struct {
+	names       []string
+	components  map[string]Background
+	deps        map[string][]string
+	annotations map[string]string
+	errs        []error
+}
+
+// NewBuilder returns new empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		components:  make(map[string]Background),
+		deps:        make(map[string][]string),
+		annotations: make(map[string]string),
+	}
+}
+
+// Add adds component bg with unique name.
+func (b *Builder) Add(name string, bg Background) *Builder {
+	switch {
+	case bg == nil:
+		b.errs = append(b.errs, fmt.Errorf("component %q is nil", name))
+	case b.components[name] != nil:
+		b.errs = append(b.errs, fmt.Errorf("duplicate component %q", name))
+	default:
+		b.names = append(b.names, name)
+		b.components[name] = bg
+	}
+
+	return b
+}
+
+// AddDependency sets dependency of component parent on components children:
+// during shutdown the children are shut down first and then the parent.
+func (b *Builder) AddDependency(parent string, children ...string) *Builder {
+	b.deps[parent] = append(b.deps[parent], children...)
+	return b
+}
+
+// Annotate assigns annotation message to component name.
+// Errors of the component are annotated with the message.
+func (b *Builder) Annotate(name, message string) *Builder {
+	b.annotations[name] = message
+	return b
+}
+
+// Build validates added components and dependencies and returns
+// the Background with all components merged.
+//
+// Components that no other component depends on are the roots of the tree.
+// The same component can be a dependency of multiple components.
+func (b *Builder) Build() (Background, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		nodes    = make(map[string]Background, len(b.names))
+		children = make(map[string]struct{})
+		roots    []Background
+	)
+
+	for _, deps := range b.deps {
+		for _, dep := range deps {
+			children[dep] = struct{}{}
+		}
+	}
+
+	for _, name := range b.names {
+		if _, ok := children[name]; !ok {
+			roots = append(roots, b.node(name, nodes))
+		}
+	}
+
+	return Merge(roots...), nil
+}
+
+// node builds the Background of component name with its dependencies.
+// Built nodes are stored in nodes to share them between parents.
+func (b *Builder) node(name string, nodes map[string]Background) Background {
+	if bg, ok := nodes[name]; ok {
+		return bg
+	}
+
+	bg := b.components[name]
+
+	if message, ok := b.annotations[name]; ok {
+		bg = WithAnnotation(message, bg)
+	}
+
+	if deps := b.deps[name]; len(deps) != 0 {
+		children := make([]Background, len(deps))
+		for i, dep := range deps {
+			children[i] = b.node(dep, nodes)
+		}
+
+		bg = bg.DependsOn(children...)
+	}
+
+	nodes[name] = bg
+
+	return bg
+}
+
+func (b *Builder) validate() error {
+	if len(b.errs) != 0 {
+		return b.errs[0]
+	}
+
+	for parent, deps := range b.deps {
+		if _, ok := b.components[parent]; !ok {
+			return fmt.Errorf("unknown component %q", parent)
+		}
+
+		for _, dep := range deps {
+			if _, ok := b.components[dep]; !ok {
+				return fmt.Errorf("component %q depends on unknown component %q", parent, dep)
+			}
+		}
+	}
+
+	for name := range b.annotations {
+		if _, ok := b.components[name]; !ok {
+			return fmt.Errorf("unknown annotated component %q", name)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(b.names))
+
+	var visit func(name string) error
+
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%w: %q", ErrDependencyCycle, name)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+
+		for _, dep := range b.deps[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, name := range b.names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
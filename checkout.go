@@ -0,0 +1,161 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosing is the error returned by Checkout's Acquire once the closing
+// of its Background has begun.
+var ErrClosing = errors.New("closing")
+
+type checkoutBackground struct {
+	*waitBackground
+
+	// closedAt is the time when the closing began, zero before.
+	closedAt time.Time
+	finished chan struct{}
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
+
+	mu sync.RWMutex
+}
+
+// Checkout detaches after checkout Background initialization.
+// It is supposed to be used by request handlers and RPC servers.
+type Checkout interface {
+	// Acquire registers in-flight work, so the Background's Wait and
+	// closing wait for it, and returns the function that must be called
+	// once the work is complete. Successive calls to release do nothing.
+	//
+	// Acquire fails with ErrClosing once the closing of the Background
+	// has begun, or with ctx's error if ctx is done.
+	Acquire(ctx context.Context) (release func(), err error)
+}
+
+// WithCheckout returns new waitable Background with merged children
+// and Checkout, giving request handlers a one-call way to both refuse
+// the work during the shutdown and be counted for draining:
+//
+//	release, err := checkout.Acquire(r.Context())
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//		return
+//	}
+//	defer release()
+//
+// When the closing begins, the Background stops accepting new work, waits
+// for the in-flight work to complete and only then closes its children,
+// so the work can use them until the end.
+func WithCheckout(children ...Background) (Background, Checkout) {
+	c := &checkoutBackground{
+		waitBackground: withWait(children...),
+		finished:       make(chan struct{}),
+	}
+
+	return c, c
+}
+
+func (c *checkoutBackground) Acquire(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.closedAt.IsZero() {
+		return nil, ErrClosing
+	}
+
+	c.waitBackground.Add(1)
+
+	var once sync.Once
+
+	return func() { once.Do(c.waitBackground.Done) }, nil
+}
+
+func (c *checkoutBackground) Shutdown(ctx context.Context) error {
+	return c.result.do(ctx, c, func() error { return shutdown(ctx, c) })
+}
+
+func (c *checkoutBackground) close(ctx context.Context) {
+	c.closeOnce.Do(func() {
+		c.prepare()
+
+		c.mu.Lock()
+		c.closedAt = time.Now()
+		c.mu.Unlock()
+
+		if !c.awaitInFlight(ctx) {
+			return
+		}
+
+		c.group.close(ctx)
+		if !awaitFinish(ctx, c.group, c.group) {
+			return
+		}
+
+		close(c.finished)
+	})
+}
+
+func (c *checkoutBackground) Finished() <-chan struct{} {
+	return c.finished
+}
+
+func (c *checkoutBackground) IsFinished() bool {
+	return isClosed(c.finished)
+}
+
+func (c *checkoutBackground) finishSig() <-chan struct{} {
+	return c.finished
+}
+
+// awaitInFlight blocks until the in-flight work is complete and returns
+// true. If Options' Abandon is enabled and ctx is done before that,
+// it records that the Background gave up waiting and returns false.
+func (c *checkoutBackground) awaitInFlight(ctx context.Context) bool {
+	if !optionsFrom(ctx).Abandon {
+		c.WaitGroup.Wait()
+		return true
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		c.WaitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		c.abandon()
+		return false
+	}
+}
+
+// cause reports the in-flight work the closing waits for, otherwise
+// it walks down the children.
+func (c *checkoutBackground) cause(ctx context.Context) error {
+	c.mu.RLock()
+	closedAt := c.closedAt
+	c.mu.RUnlock()
+
+	if n := c.Pending(); n > 0 && !closedAt.IsZero() && !c.IsFinished() {
+		return fmt.Errorf("%d in-flight: %w", n, newTimeoutError(ctx, closedAt))
+	}
+
+	return c.group.cause(ctx)
+}
+
+func (c *checkoutBackground) DependsOn(children ...Background) Background {
+	return withDependency(c, children...)
+}
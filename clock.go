@@ -0,0 +1,84 @@
+package background
+
+import "time"
+
+// Clock provides time to time-dependent Backgrounds.
+// The system clock is used by default.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a new Timer that will send the current time on its
+	// channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc waits for the duration to elapse and then calls f.
+	// It returns a Timer that can be used to cancel the call using its
+	// Stop method.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer represents a single event, same as time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing. It returns true if the call
+	// stops the timer, false if the timer has already expired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to expire after duration d. It returns true if
+	// the timer had been active, false if the timer had expired or been stopped.
+	Reset(d time.Duration) bool
+}
+
+// clockKey is a private Value key for Clock.
+type clockKey struct{}
+
+// WithClock returns new Background with merged children and assigned clock c
+// to it.
+//
+// Time-dependent Backgrounds, such as created with WithDeadline or WithTimeout,
+// use the clock found in their children the same way as Background.Value
+// does, so the clock is supposed to be assigned at the bottom of the tree:
+//
+//	bg := background.WithTimeout(time.Minute, background.WithClock(clock, job))
+//
+// Replacing the system clock is useful in tests to advance time
+// deterministically instead of sleeping.
+func WithClock(c Clock, children ...Background) Background {
+	return withValue(clockKey{}, c, children...)
+}
+
+// clockOf returns the clock assigned in bg's tree or the system clock.
+func clockOf(bg Background) Clock {
+	if c, ok := bg.Value(clockKey{}).(Clock); ok {
+		return c
+	}
+
+	return systemClock{}
+}
+
+// SystemClock returns the Clock backed by package time.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTimer(d time.Duration) Timer         { return systemTimer{time.NewTimer(d)} }
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
@@ -0,0 +1,67 @@
+package background
+
+import (
+	"context"
+	"time"
+)
+
+// CloseInfo describes the shutdown that closed a Background.
+type CloseInfo struct {
+	// Initiator is the Background whose Shutdown call initiated the shutdown,
+	// or the Background with exceeded deadline.
+	Initiator Background
+
+	// Reason is the reason of the shutdown set with WithShutdownReason,
+	// nil if it is not set.
+	Reason error
+
+	// Deadline is the deadline of the shutdown,
+	// zero if the shutdown has no deadline.
+	Deadline time.Time
+
+	// DuringStartup is true if the tree being shut down was not ready yet
+	// when the shutdown began, so the jobs can skip the work that makes
+	// sense only after the startup, e.g. flushing never filled caches.
+	// The warmups are canceled and the jobs started with ReadyThen
+	// are skipped in this case.
+	DuringStartup bool
+}
+
+type (
+	initiatorKey struct{}
+	reasonKey    struct{}
+	startupKey   struct{}
+)
+
+// WithShutdownReason returns a copy of ctx that carries reason. Passing the
+// returned context to Background's Shutdown makes reason available to all
+// background jobs in the tree via ShutdownTail's CloseInfo, e.g.:
+//
+//	bg.Shutdown(background.WithShutdownReason(ctx, errors.New("SIGTERM")))
+func WithShutdownReason(ctx context.Context, reason error) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// CloseInfoFrom returns CloseInfo of the shutdown with context ctx,
+// e.g. the context returned by ShutdownTail's Context.
+func CloseInfoFrom(ctx context.Context) CloseInfo {
+	info := CloseInfo{}
+
+	info.Initiator, _ = ctx.Value(initiatorKey{}).(Background)
+	info.Reason, _ = ctx.Value(reasonKey{}).(error)
+	info.Deadline, _ = ctx.Deadline()
+	info.DuringStartup, _ = ctx.Value(startupKey{}).(bool)
+
+	return info
+}
+
+// withInitiator returns a copy of ctx that carries bg as the initiator
+// of the shutdown. If ctx already carries an initiator, it is returned as is,
+// so the initiator is the Background closest to the Shutdown call.
+func withInitiator(ctx context.Context, bg Background) context.Context {
+	if _, ok := ctx.Value(initiatorKey{}).(Background); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, initiatorKey{}, bg)
+}
@@ -0,0 +1,38 @@
+package background
+
+// disabledBackground is the Background of a disabled component, see If.
+// It behaves like Empty, but is marked as disabled in snapshots.
+type disabledBackground struct {
+	emptyBackground
+
+	// the field makes the pointers to disabled Backgrounds distinct,
+	// so they are visited separately in the tree
+	_ byte
+}
+
+// If returns the Background built with mk if enabled is true, otherwise
+// mk isn't called and the returned Background behaves like Empty, but
+// is marked as disabled in Snapshot. It keeps the topology of trees
+// with feature-flagged components visible:
+//
+//	bg := background.Merge(
+//		background.WithAnnotation("server", serverBg),
+//		background.WithAnnotation("tracing", background.If(cfg.Tracing, newTracer)),
+//	)
+//
+// If mk returns nil, If returns Empty.
+func If(enabled bool, mk func() Background) Background {
+	if !enabled {
+		return &disabledBackground{}
+	}
+
+	if bg := mk(); bg != nil {
+		return bg
+	}
+
+	return Empty()
+}
+
+func (d *disabledBackground) DependsOn(children ...Background) Background {
+	return withDependency(d, children...)
+}
@@ -0,0 +1,107 @@
+package background
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithMessageLoop returns new Background running a message consumer loop:
+// it fetches messages with fetch and handles them one by one with handle.
+// The loop guarantees at-least-once semantics during the shutdown:
+//
+// 1. When the shutdown of the tree begins, the ctx passed to fetch is
+// canceled, so no new messages are taken, see DrainTail.
+//
+// 2. The message being handled is handled to the end. The ctx passed
+// to handle is canceled only when the ctx of the Shutdown call is done.
+//
+// 3. commit is called with the ctx of the Shutdown call to commit
+// the offsets of the handled messages, then the shutdown is complete.
+//
+// Committing the offsets while the loop runs is up to fetch and handle.
+// If fetch or handle return an error, the loop stops and the error is
+// returned by the Background's Err. commit isn't called after a failed
+// handle, so the failed message is delivered again.
+func WithMessageLoop(
+	fetch func(ctx context.Context) (interface{}, error),
+	handle func(ctx context.Context, msg interface{}) error,
+	commit func(ctx context.Context) error,
+) Background {
+	var (
+		errs = withErrorGroup()
+		d    = withDrain(errs)
+	)
+
+	goLabeled(caller(2), func() {
+		defer d.Done()
+
+		fetchCtx, cancelFetch := context.WithCancel(context.Background())
+		defer cancelFetch()
+
+		handleCtx, cancelHandle := context.WithCancel(context.Background())
+		defer cancelHandle()
+
+		go func() {
+			select {
+			case <-d.Drain():
+				cancelFetch()
+			case <-handleCtx.Done():
+				return
+			}
+
+			select {
+			case <-d.End():
+			case <-handleCtx.Done():
+				return
+			}
+
+			select {
+			case <-d.Context().Done():
+				cancelHandle()
+			case <-handleCtx.Done():
+			}
+		}()
+
+		if !consume(fetchCtx, handleCtx, fetch, handle, errs) {
+			<-d.End()
+			return
+		}
+
+		<-d.End()
+
+		if err := commit(d.Context()); err != nil {
+			errs.Error(fmt.Errorf("commit: %w", err))
+		}
+	})
+
+	return d
+}
+
+// consume fetches and handles messages until fetchCtx is canceled or an
+// error occurs. It returns false if handle failed.
+func consume(
+	fetchCtx, handleCtx context.Context,
+	fetch func(ctx context.Context) (interface{}, error),
+	handle func(ctx context.Context, msg interface{}) error,
+	errs ErrTail,
+) bool {
+	for fetchCtx.Err() == nil {
+		msg, err := fetch(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() == nil {
+				errs.Error(fmt.Errorf("fetch: %w", err))
+			}
+
+			return true
+		}
+
+		// the fetched message is handled even if the drain began,
+		// as it may be committed otherwise
+		if err := handle(handleCtx, msg); err != nil {
+			errs.Error(fmt.Errorf("handle: %w", err))
+			return false
+		}
+	}
+
+	return true
+}
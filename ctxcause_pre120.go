@@ -0,0 +1,12 @@
+//go:build !go1.20
+// +build !go1.20
+
+package background
+
+import "context"
+
+// contextCause returns the cause of ctx's cancellation. Before Go 1.20
+// contexts have no causes, so it is ctx.Err().
+func contextCause(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,111 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDeadlineExceeded is the error returned by Background.Err when
+// the deadline of Background created with WithDeadline or WithTimeout
+// is exceeded.
+var ErrDeadlineExceeded = errors.New("deadline exceeded")
+
+type deadlineBackground struct {
+	*group
+
+	timer Timer
+	err   error
+
+	sync.RWMutex
+}
+
+// WithDeadline returns new Background with merged children that begins
+// closing its children when the deadline d is exceeded.
+//
+// After the deadline is exceeded Background's Err returns ErrDeadlineExceeded.
+// Shutting the Background down before the deadline stops the timer.
+//
+// The deadline is tracked by the clock assigned to children with WithClock
+// or by the system clock.
+func WithDeadline(d time.Time, children ...Background) Background {
+	return withDeadline(d, children...)
+}
+
+// WithTimeout returns WithDeadline(clock.Now().Add(timeout), children...),
+// where clock is the clock assigned to children with WithClock or
+// the system clock.
+func WithTimeout(timeout time.Duration, children ...Background) Background {
+	return withTimeout(timeout, children...)
+}
+
+func withTimeout(timeout time.Duration, children ...Background) *deadlineBackground {
+	g := merge(children...)
+	return newDeadline(g, clockOf(g).Now().Add(timeout))
+}
+
+func withDeadline(d time.Time, children ...Background) *deadlineBackground {
+	return newDeadline(merge(children...), d)
+}
+
+func newDeadline(g *group, d time.Time) *deadlineBackground {
+	b := &deadlineBackground{
+		group: g,
+	}
+
+	clock := clockOf(g)
+
+	b.Lock()
+	b.timer = clock.AfterFunc(d.Sub(clock.Now()), b.expire)
+	b.Unlock()
+
+	return b
+}
+
+func (d *deadlineBackground) expire() {
+	d.Lock()
+	d.err = ErrDeadlineExceeded
+	d.Unlock()
+
+	errorsChanged()
+
+	ctx := withInitiator(context.Background(), d)
+	ctx = WithShutdownReason(ctx, ErrDeadlineExceeded)
+
+	go func() {
+		announce(ctx, d)
+		closePhases(ctx, d)
+		d.group.close(ctx)
+	}()
+}
+
+// Err returns ErrDeadlineExceeded if Background's deadline is exceeded,
+// otherwise it returns the first encountered error in Background's children.
+func (d *deadlineBackground) Err() error {
+	d.RLock()
+	err := d.err
+	d.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return d.group.Err()
+}
+
+func (d *deadlineBackground) Shutdown(ctx context.Context) error {
+	return d.result.do(ctx, d, func() error { return shutdown(ctx, d) })
+}
+
+func (d *deadlineBackground) close(ctx context.Context) {
+	d.Lock()
+	d.timer.Stop()
+	d.Unlock()
+
+	d.group.close(ctx)
+}
+
+func (d *deadlineBackground) DependsOn(children ...Background) Background {
+	return withDependency(d, children...)
+}
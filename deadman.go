@@ -0,0 +1,140 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDeadmanExpired is the error returned by Background.Err when the switch
+// of Background created with WithDeadman isn't reset in time.
+var ErrDeadmanExpired = errors.New("deadman switch expired")
+
+type deadmanBackground struct {
+	*group
+
+	d        time.Duration
+	timer    Timer
+	onExpire func()
+	err      error
+
+	sync.RWMutex
+}
+
+// DeadmanTail detaches after deadman Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background, e.g. an event loop that resets it on every iteration.
+type DeadmanTail interface {
+	// Reset resets the switch, so it expires after the full duration
+	// from now. After the switch expired or the Background is shut down,
+	// Reset does nothing.
+	Reset()
+}
+
+// WithDeadman returns new Background with merged children and a switch
+// that must be reset with DeadmanTail's Reset at least every d, protecting
+// against jobs that silently stop making progress, e.g. event loops that stop
+// consuming. If the switch isn't reset in time, Background's Err returns
+// ErrDeadmanExpired, the Background begins closing its children and
+// onExpire is called if it's not nil.
+//
+// The switch closes only the Background's own children, as it can't reach
+// the rest of the tree. The whole tree is shut down by its Await or App,
+// which treat ErrDeadmanExpired as fatal, or by onExpire, e.g.:
+//
+//	var root background.Background
+//
+//	bg, tail := background.WithDeadman(time.Minute, func() {
+//		go root.Shutdown(context.Background())
+//	}, consumer)
+//
+//	root = background.Merge(bg, server)
+//
+// Shutting the Background down stops the switch.
+//
+// The switch is tracked by the clock assigned to children with WithClock
+// or by the system clock.
+func WithDeadman(d time.Duration, onExpire func(), children ...Background) (Background, DeadmanTail) {
+	g := merge(children...)
+
+	b := &deadmanBackground{
+		group:    g,
+		d:        d,
+		onExpire: onExpire,
+	}
+
+	b.Lock()
+	b.timer = clockOf(g).AfterFunc(d, b.expire)
+	b.Unlock()
+
+	return b, b
+}
+
+func (b *deadmanBackground) Reset() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.err != nil || b.IsClosing() {
+		return
+	}
+
+	b.timer.Reset(b.d)
+}
+
+func (b *deadmanBackground) expire() {
+	b.Lock()
+
+	if b.err != nil || b.IsClosing() {
+		b.Unlock()
+		return
+	}
+
+	b.err = ErrDeadmanExpired
+	b.Unlock()
+
+	errorsChanged()
+
+	ctx := withInitiator(context.Background(), b)
+	ctx = WithShutdownReason(ctx, ErrDeadmanExpired)
+
+	go func() {
+		announce(ctx, b)
+		closePhases(ctx, b)
+		b.group.close(ctx)
+	}()
+
+	if b.onExpire != nil {
+		b.onExpire()
+	}
+}
+
+// Err returns ErrDeadmanExpired if the switch expired, otherwise it returns
+// the first encountered error in Background's children.
+func (b *deadmanBackground) Err() error {
+	b.RLock()
+	err := b.err
+	b.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return b.group.Err()
+}
+
+func (b *deadmanBackground) Shutdown(ctx context.Context) error {
+	return b.result.do(ctx, b, func() error { return shutdown(ctx, b) })
+}
+
+func (b *deadmanBackground) close(ctx context.Context) {
+	b.Lock()
+	b.timer.Stop()
+	b.Unlock()
+
+	b.group.close(ctx)
+}
+
+func (b *deadmanBackground) DependsOn(children ...Background) Background {
+	return withDependency(b, children...)
+}
@@ -0,0 +1,120 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type delayBackground struct {
+	*group
+
+	delay time.Duration
+	clock Clock
+
+	// delayed is closed when the delay is over.
+	delayed  chan struct{}
+	finished chan struct{}
+
+	// closedAt is the time when the closing began, zero before.
+	closedAt time.Time
+
+	// cut is true if the shutdown's ctx was done before the delay was over.
+	cut bool
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
+
+	sync.RWMutex
+}
+
+// WithShutdownDelay returns new Background with merged children that waits
+// for d after its closing begins and only then begins closing its children,
+// so they keep serving while load balancers deprogram the instance, e.g.
+// while Kubernetes removes the pod from the endpoints:
+//
+//	bg := background.WithShutdownDelay(5*time.Second, serverBg)
+//
+// The Background's Closing channel is closed when the delay begins, so
+// the readiness probes can fail during it. If the shutdown's ctx is done
+// before the delay is over, the children are closed right away.
+//
+// The delay is a step of the shutdown in Plan and Report, and the timeout
+// during the delay is reported in the shutdown error.
+//
+// The delay is tracked by the clock assigned to children with WithClock
+// or by the system clock.
+func WithShutdownDelay(d time.Duration, children ...Background) Background {
+	g := merge(children...)
+
+	return &delayBackground{
+		group:    g,
+		delay:    d,
+		clock:    clockOf(g),
+		delayed:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+func (d *delayBackground) Shutdown(ctx context.Context) error {
+	return d.result.do(ctx, d, func() error { return shutdown(ctx, d) })
+}
+
+func (d *delayBackground) close(ctx context.Context) {
+	d.closeOnce.Do(func() {
+		d.prepare()
+
+		d.Lock()
+		d.closedAt = time.Now()
+		d.Unlock()
+
+		select {
+		case <-d.clock.After(d.delay):
+		case <-ctx.Done():
+			d.Lock()
+			d.cut = true
+			d.Unlock()
+		}
+
+		close(d.delayed)
+
+		d.group.close(ctx)
+		if !awaitFinish(ctx, d.group, d.group) {
+			return
+		}
+
+		close(d.finished)
+	})
+}
+
+func (d *delayBackground) Finished() <-chan struct{} {
+	return d.finished
+}
+
+func (d *delayBackground) IsFinished() bool {
+	return isClosed(d.finished)
+}
+
+func (d *delayBackground) finishSig() <-chan struct{} {
+	return d.finished
+}
+
+// cause reports the delay if it isn't over or was cut short by the shutdown's
+// ctx, otherwise it walks down the children.
+func (d *delayBackground) cause(ctx context.Context) error {
+	d.RLock()
+	closedAt, cut := d.closedAt, d.cut
+	d.RUnlock()
+
+	if cut || !closedAt.IsZero() && !isClosed(d.delayed) {
+		return fmt.Errorf("shutdown delay %s: %w", d.delay, newTimeoutError(ctx, closedAt))
+	}
+
+	return d.group.cause(ctx)
+}
+
+func (d *delayBackground) DependsOn(children ...Background) Background {
+	return withDependency(d, children...)
+}
@@ -2,15 +2,33 @@ package background
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 )
 
 type dependBackground struct {
+	id NodeID
+
 	children *group
 	parent   Background
 
+	// abandoned is 1 if the Background gave up waiting for its children,
+	// see Options' Abandon. It is accessed atomically.
+	abandoned int32
+
 	finished chan struct{}
-	ready    chan struct{}
+	ready    readySignal
+	closing  readySignal
+	result   shutdownResult
+
+	// interleave is true if the parent's closing signal is fired
+	// when the children's closing begins, see LevelOptions.
+	interleave bool
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
 
 	sync.RWMutex
 }
@@ -19,23 +37,118 @@ type dependBackground struct {
 // with parent's dependency set on children.
 func withDependency(parent Background, children ...Background) *dependBackground {
 	return &dependBackground{
-		children: merge(children...),
+		id:       nextID(),
+		children: withSource(SourceDependency, merge(children...)),
 		parent:   parent,
 		finished: make(chan struct{}),
 	}
 }
 
+// LevelOptions configures the closing of a dependency level, see DependsOnWith.
+type LevelOptions struct {
+	// Limit is the maximum number of the level's children closed
+	// simultaneously, see MergeWithLimit. Zero means no limit.
+	Limit int
+
+	// Sequential closes the level's children one by one in the order
+	// they were passed, see MergeSequential. Limit is ignored.
+	Sequential bool
+
+	// Interleave fires the parent's Closing signal when the closing of
+	// the level's children begins, so the parent's jobs watching Closing
+	// can prepare to drain, e.g. stop accepting new work, while
+	// the children close. The parent is still closed after the children.
+	Interleave bool
+}
+
+// DependsOnWith is like parent.DependsOn(children...), but closes
+// the children according to o instead of closing all of them
+// simultaneously.
+func DependsOnWith(parent Background, o LevelOptions, children ...Background) Background {
+	order, limit := closeConcurrent, o.Limit
+	if o.Sequential {
+		order, limit = closeSequential, 0
+	}
+
+	if limit < 0 {
+		limit = 0
+	}
+
+	return &dependBackground{
+		id:         nextID(),
+		children:   withSource(SourceDependency, mergeGroup(order, limit, children...)),
+		parent:     parent,
+		finished:   make(chan struct{}),
+		interleave: o.Interleave,
+	}
+}
+
 func (d *dependBackground) Shutdown(ctx context.Context) error {
-	return shutdown(ctx, d)
+	return d.result.do(ctx, d, func() error { return shutdown(ctx, d) })
+}
+
+func (d *dependBackground) ShutdownResult() (error, bool) {
+	return d.result.get()
+}
+
+func (d *dependBackground) Reload(ctx context.Context) error {
+	if err := d.children.Reload(ctx); err != nil {
+		return err
+	}
+
+	return d.parent.Reload(ctx)
+}
+
+func (d *dependBackground) Pause() {
+	d.children.Pause()
+	d.parent.Pause()
+}
+
+func (d *dependBackground) Resume() {
+	d.parent.Resume()
+	d.children.Resume()
+}
+
+func (d *dependBackground) close(ctx context.Context) {
+	d.closeOnce.Do(func() {
+		d.prepare()
+
+		if d.interleave {
+			if p, ok := d.parent.(preparer); ok {
+				p.prepare()
+			}
+		}
+
+		d.children.close(ctx)
+		if !awaitFinish(ctx, d, d.children) {
+			return
+		}
+
+		d.parent.close(ctx)
+		if !awaitFinish(ctx, d, d.parent) {
+			return
+		}
+
+		d.Done()
+	})
 }
 
-func (d *dependBackground) close() {
-	d.children.close()
-	<-d.children.finishSig()
+// preparer is implemented by Backgrounds that can fire their closing
+// signal before the closing begins.
+type preparer interface {
+	prepare()
+}
 
-	d.parent.close()
-	<-d.parent.finishSig()
-	d.Done()
+func (g *group) prepare() {
+	g.Lock()
+	g.closing.fire()
+	g.Unlock()
+}
+
+func (d *dependBackground) prepare() {
+	d.Lock()
+	d.closing.fire()
+	d.Unlock()
 }
 
 func (d *dependBackground) Done() {
@@ -54,24 +167,22 @@ func (d *dependBackground) Wait() {
 	d.parent.Wait()
 }
 
-func (d *dependBackground) Ready() <-chan struct{} {
-	d.Lock()
-	defer d.Unlock()
-
-	if d.ready != nil {
-		// To avoid memory leaks - ready channel is created only once
-		return d.ready
+func (d *dependBackground) WaitContext(ctx context.Context) error {
+	if err := d.children.WaitContext(ctx); err != nil {
+		return err
 	}
 
-	d.ready = make(chan struct{})
+	return d.parent.WaitContext(ctx)
+}
 
-	go func() {
-		<-d.children.Ready()
-		<-d.parent.Ready()
-		close(d.ready)
-	}()
+func (d *dependBackground) Ready() <-chan struct{} {
+	d.ready.follow(d.children.onReady, d.parent.onReady)
+	return d.ready.channel()
+}
 
-	return d.ready
+func (d *dependBackground) onReady(f func()) {
+	d.ready.follow(d.children.onReady, d.parent.onReady)
+	d.ready.subscribe(f)
 }
 
 func (d *dependBackground) Err() (err error) {
@@ -79,13 +190,7 @@ func (d *dependBackground) Err() (err error) {
 		return err
 	}
 
-	for _, backgrounds := range d.children.backgrounds {
-		if err = backgrounds.Err(); err != nil {
-			return err
-		}
-	}
-
-	return
+	return d.children.Err()
 }
 
 func (d *dependBackground) Value(key interface{}) (value interface{}) {
@@ -93,13 +198,7 @@ func (d *dependBackground) Value(key interface{}) (value interface{}) {
 		return value
 	}
 
-	for _, backgrounds := range d.children.backgrounds {
-		if value = backgrounds.Value(key); value != nil {
-			return value
-		}
-	}
-
-	return
+	return d.children.Value(key)
 }
 
 func (d *dependBackground) DependsOn(children ...Background) Background {
@@ -110,20 +209,90 @@ func (d *dependBackground) dependsOn(children ...Background) *dependBackground {
 	return withDependency(d, children...)
 }
 
+func (d *dependBackground) Finished() <-chan struct{} {
+	return d.finished
+}
+
+func (d *dependBackground) Closing() <-chan struct{} {
+	return d.closing.channel()
+}
+
+func (d *dependBackground) IsFinished() bool {
+	return isClosed(d.finished)
+}
+
+func (d *dependBackground) IsClosing() bool {
+	return d.closing.isFired()
+}
+
 func (d *dependBackground) finishSig() <-chan struct{} {
 	return d.finished
 }
 
-func (d *dependBackground) cause() error {
-	err := d.children.cause()
+func (d *dependBackground) cause(ctx context.Context) error {
+	err := d.children.cause(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = d.parent.cause()
+	err = d.parent.cause(ctx)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ShutdownLevels gracefully shuts down bg the same way as Background's
+// Shutdown does, but splits the time left until ctx's deadline between
+// bg's dependency levels, so one slow level can't starve the rest.
+//
+// A dependency level is a set of children passed to a single DependsOn call;
+// the Background DependsOn was called on first is the last level.
+// Each level gets an equal share of the time left when its shutdown begins,
+// so the time saved by fast levels is passed to the next ones.
+//
+// If a level doesn't shut down in time, ShutdownLevels returns its shutdown
+// error annotated with the level number, starting from 1 for the level that
+// is shut down first. If ctx has no deadline, ShutdownLevels is equivalent
+// to bg.Shutdown(ctx).
+func ShutdownLevels(ctx context.Context, bg Background) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return bg.Shutdown(ctx)
+	}
+
+	announce(ctx, bg)
+
+	levels := dependencyLevels(bg)
+
+	for i, level := range levels {
+		budget := time.Until(deadline) / time.Duration(len(levels)-i)
+
+		levelCtx, cancel := context.WithTimeout(ctx, budget)
+		err := level.Shutdown(levelCtx)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("level %d: %w", i+1, err)
+		}
+	}
+
+	return bg.Shutdown(ctx)
+}
+
+// dependencyLevels returns bg's dependency levels in shutdown order.
+// Annotations of a single Background are looked through, so annotating
+// a dependency chain doesn't collapse its levels into one.
+func dependencyLevels(bg Background) []Background {
+	if a, ok := bg.(*annotationBackground); ok && len(a.backgrounds) == 1 {
+		return dependencyLevels(a.backgrounds[0])
+	}
+
+	d, ok := bg.(*dependBackground)
+	if !ok {
+		return []Background{bg}
+	}
+
+	return append([]Background{d.children}, dependencyLevels(d.parent)...)
+}
@@ -0,0 +1,66 @@
+package background
+
+import "context"
+
+type drainBackground struct {
+	*shutdownBackground
+
+	// drain is fired when the shutdown of the tree begins.
+	drain readySignal
+}
+
+// DrainTail detaches after drainable Background initialization.
+// It extends ShutdownTail with the first phase of a two-phase shutdown,
+// for jobs such as servers and queue consumers that stop taking new work
+// before finishing the work they already have.
+type DrainTail interface {
+	ShutdownTail
+
+	// Drain returns a channel that's closed when the job should stop
+	// taking new work, while still finishing the work it has: as soon as
+	// the shutdown of any tree the Background belongs to begins, or when
+	// its own closing begins, whichever happens first. The End channel
+	// is closed later, after the Background's children are shut down,
+	// and demands the completion of the remaining work.
+	// Successive calls to Drain return the same value.
+	Drain() <-chan struct{}
+}
+
+// WithDrain returns a new shutdownable Background that depends on children,
+// with DrainTail signaling both phases of its shutdown:
+//
+//	bg, tail := background.WithDrain()
+//
+//	go func() {
+//		<-tail.Drain()
+//		listener.Close() // stop accepting new connections
+//
+//		<-tail.End()
+//		server.Shutdown(tail.Context()) // finish the accepted ones
+//		tail.Done()
+//	}()
+func WithDrain(children ...Background) (Background, DrainTail) {
+	d := withDrain(children...)
+	return d, d
+}
+
+func withDrain(children ...Background) *drainBackground {
+	return &drainBackground{shutdownBackground: withShutdown(children...)}
+}
+
+func (d *drainBackground) Drain() <-chan struct{} {
+	return d.drain.channel()
+}
+
+func (d *drainBackground) Shutdown(ctx context.Context) error {
+	return d.result.do(ctx, d, func() error { return shutdown(ctx, d) })
+}
+
+func (d *drainBackground) close(ctx context.Context) {
+	d.drain.fire()
+	d.shutdownBackground.close(ctx)
+}
+
+func (d *drainBackground) DependsOn(children ...Background) Background {
+	return withDependency(d, children...)
+}
@@ -5,15 +5,25 @@ import "context"
 type emptyBackground struct{}
 
 // Empty returns new empty Background
-func Empty() Background                                    { return emptyBackground{} }
-func (e emptyBackground) Err() error                       { return nil }
-func (e emptyBackground) Shutdown(_ context.Context) error { return nil }
-func (e emptyBackground) Wait()                            {}
-func (e emptyBackground) Ready() <-chan struct{}           { return closedchan }
-func (e emptyBackground) Value(_ interface{}) interface{}  { return nil }
+func Empty() Background                                       { return emptyBackground{} }
+func (e emptyBackground) Err() error                          { return nil }
+func (e emptyBackground) Shutdown(_ context.Context) error    { return nil }
+func (e emptyBackground) ShutdownResult() (error, bool)       { return nil, true }
+func (e emptyBackground) Reload(_ context.Context) error      { return nil }
+func (e emptyBackground) Pause()                              {}
+func (e emptyBackground) Resume()                             {}
+func (e emptyBackground) Wait()                               {}
+func (e emptyBackground) WaitContext(_ context.Context) error { return nil }
+func (e emptyBackground) Ready() <-chan struct{}              { return closedchan }
+func (e emptyBackground) Finished() <-chan struct{}           { return closedchan }
+func (e emptyBackground) Closing() <-chan struct{}            { return neverchan }
+func (e emptyBackground) IsFinished() bool                    { return true }
+func (e emptyBackground) IsClosing() bool                     { return false }
+func (e emptyBackground) Value(_ interface{}) interface{}     { return nil }
 func (e emptyBackground) DependsOn(children ...Background) Background {
 	return withDependency(e, children...)
 }
-func (e emptyBackground) close()                     {}
-func (e emptyBackground) finishSig() <-chan struct{} { return closedchan }
-func (e emptyBackground) cause() error               { return nil }
+func (e emptyBackground) close(_ context.Context)       {}
+func (e emptyBackground) finishSig() <-chan struct{}    { return closedchan }
+func (e emptyBackground) cause(_ context.Context) error { return nil }
+func (e emptyBackground) onReady(f func())              { f() }
@@ -0,0 +1,118 @@
+package background
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// errorType is the type of error interface.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// treeErrors returns the errors of the Backgrounds in bg's tree that fail
+// on their own rather than because of their children, annotated with
+// the annotations on the path to them, in the same order as Background.Value
+// searches the tree.
+func treeErrors(bg Background) []error {
+	var errs []error
+
+	walkPath(bg, func(bg Background, path []string) {
+		err := bg.Err()
+		if err == nil {
+			return
+		}
+
+		// the error of a child passed up, possibly wrapped,
+		// is reported for the child
+		if b, ok := brancherOf(bg); ok {
+			for _, child := range b.branches() {
+				if passedUp(err, child.Err()) {
+					return
+				}
+			}
+		}
+
+		if len(path) > 0 {
+			err = fmt.Errorf("%s: %w", strings.Join(path, ": "), err)
+		}
+
+		errs = append(errs, err)
+	})
+
+	return errs
+}
+
+// passedUp reports whether err is childErr passed up by its parent,
+// possibly wrapped. The parents that wrap the errors of their children,
+// e.g. annotations, wrap them anew on every Err call, so the errors
+// in the chain of childErr are matched as well.
+func passedUp(err, childErr error) bool {
+	for ; childErr != nil; childErr = errors.Unwrap(childErr) {
+		if errors.Is(err, childErr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrorsAs finds all errors in bg's tree that match the element type
+// of the slice target points to, see errors.As, and appends them to that
+// slice. It returns true if any errors are found. Unlike Background.Err,
+// which returns the first encountered error, it digs out the errors
+// of all failed components, e.g.:
+//
+//	var timeouts []*background.TimeoutError
+//	if background.ErrorsAs(bg, &timeouts) {
+//		...
+//	}
+//
+// ErrorsAs panics if target is not a non-nil pointer to a slice of a type
+// implementing error or of an interface type.
+func ErrorsAs(bg Background, target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if target == nil || val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Slice {
+		panic("background errors target must be a non-nil pointer to a slice")
+	}
+
+	var (
+		slice = val.Elem()
+		elem  = slice.Type().Elem()
+		found bool
+	)
+
+	if elem.Kind() != reflect.Interface && !elem.Implements(errorType) {
+		panic("background errors target slice element must be an interface or implement error")
+	}
+
+	for _, err := range treeErrors(bg) {
+		match := reflect.New(elem)
+		if !errors.As(err, match.Interface()) {
+			continue
+		}
+
+		slice.Set(reflect.Append(slice, match.Elem()))
+		found = true
+	}
+
+	return found
+}
+
+// FirstError returns the first error in bg's tree for which match returns
+// true, or nil if there is none. The errors of all failed components are
+// matched in the same order as Background.Value searches the tree,
+// annotated the same way as they annotate Background.Err, e.g.:
+//
+//	err := background.FirstError(bg, func(err error) bool {
+//		return errors.Is(err, sql.ErrConnDone)
+//	})
+func FirstError(bg Background, match func(error) bool) error {
+	for _, err := range treeErrors(bg) {
+		if match(err) {
+			return err
+		}
+	}
+
+	return nil
+}
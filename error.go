@@ -2,8 +2,40 @@ package background
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
+// errVersion is incremented every time an error is assigned to a Background
+// after its creation. It is accessed atomically and used to invalidate
+// cached results of Err calls.
+var errVersion int64
+
+var (
+	// errChanged is closed and replaced every time errVersion is incremented.
+	errChanged   = make(chan struct{})
+	errChangedMu sync.Mutex
+)
+
+// errorsChanged must be called after an error is assigned to a Background
+// after its creation.
+func errorsChanged() {
+	atomic.AddInt64(&errVersion, 1)
+
+	errChangedMu.Lock()
+	close(errChanged)
+	errChanged = make(chan struct{})
+	errChangedMu.Unlock()
+}
+
+// errorsChangedSig returns a channel that's closed on the next
+// errorsChanged call.
+func errorsChangedSig() <-chan struct{} {
+	errChangedMu.Lock()
+	defer errChangedMu.Unlock()
+
+	return errChanged
+}
+
 type errBackground struct {
 	*group
 	err error
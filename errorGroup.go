@@ -16,6 +16,17 @@ type ErrTail interface {
 	Errorf(format string, a ...interface{})
 }
 
+// ErrTryTail is an ErrTail that reports whether errors are assigned.
+// The ErrTails returned by WithErrorGroup and WithErrorIntake implement it.
+type ErrTryTail interface {
+	ErrTail
+
+	// TryError assigns err to associated background and reports whether
+	// it was assigned. If the background already has an error or err is nil
+	// - does nothing and returns false, so the job can handle err itself.
+	TryError(err error) bool
+}
+
 type errGroupBackground struct {
 	*errBackground
 }
@@ -23,7 +34,8 @@ type errGroupBackground struct {
 // WithErrorGroup returns new background with merged children that can
 // store an error.
 //
-// The returned ErrTail is used to assign error to the background.
+// The returned ErrTail is used to assign error to the background,
+// it implements ErrTryTail.
 func WithErrorGroup(children ...Background) (Background, ErrTail) {
 	b := withErrorGroup(children...)
 	return b, b
@@ -35,25 +47,71 @@ func withErrorGroup(children ...Background) *errGroupBackground {
 
 // Error assigns err to the Background.
 //
-// If the Background already has an error - does nothing.
+// If the Background already has an error - does nothing, but passes err
+// to Options' OnDroppedError of the package defaults, if set.
+//
+// If capturing stack traces is enabled with SetStackTraces, err is wrapped
+// in StackError.
 func (e *errGroupBackground) Error(err error) {
-	if err != nil {
-		e.Lock()
-		if e.err == nil {
-			e.err = err
-		}
-		e.Unlock()
+	if !e.setError(err, callers(1)) {
+		dropped(err)
+	}
+}
+
+// TryError assigns err to the Background and reports whether it was assigned.
+//
+// If the Background already has an error or err is nil - does nothing and
+// returns false. Unlike Error, it doesn't pass err to OnDroppedError.
+func (e *errGroupBackground) TryError(err error) bool {
+	return e.setError(err, callers(1))
+}
+
+// setError assigns err to the Background if it has no error yet
+// and reports whether err was assigned.
+func (e *errGroupBackground) setError(err error, stack Stack) bool {
+	if err == nil {
+		return false
+	}
+
+	if stack != nil {
+		err = &StackError{Err: err, Stack: stack}
+	}
+
+	e.Lock()
+	ok := e.err == nil
+	if ok {
+		e.err = err
+	}
+	e.Unlock()
+
+	if ok {
+		errorsChanged()
+	}
+
+	return ok
+}
+
+// dropped passes err, which wasn't assigned to the Background, to Options'
+// OnDroppedError of the package defaults.
+func dropped(err error) {
+	if f := Defaults().OnDroppedError; err != nil && f != nil {
+		f(err)
 	}
 }
 
 // Errorf formats according to a format specifier and assigns
 // the string to the Background as a value that satisfies error.
 //
-// If the Background already has an error - does nothing.
+// If the Background already has an error - does nothing, but passes
+// the error to Options' OnDroppedError of the package defaults, if set.
 //
 // Uses fmt.Errorf thus supports error wrapping with %w verb.
 func (e *errGroupBackground) Errorf(format string, a ...interface{}) {
-	e.Error(fmt.Errorf(format, a...))
+	err := fmt.Errorf(format, a...)
+
+	if !e.setError(err, callers(1)) {
+		dropped(err)
+	}
 }
 
 func (e *errGroupBackground) DependsOn(children ...Background) Background {
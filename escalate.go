@@ -0,0 +1,130 @@
+package background
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// exit is os.Exit, replaced in tests.
+var exit = os.Exit
+
+// Escalation configures the crash-only escalation of stuck shutdowns,
+// see ShutdownOrExit.
+type Escalation struct {
+	// Timeout is the hard limit of the shutdown. Zero or negative Timeout
+	// means no escalation.
+	Timeout time.Duration
+
+	// Output receives the diagnostics, os.Stderr is used if it is nil.
+	Output io.Writer
+
+	// ExitCode is the exit code of the process.
+	ExitCode int
+}
+
+// ShutdownOrExit gracefully shuts down bg with ctx and returns the result
+// of the shutdown. If the shutdown doesn't complete within e's Timeout,
+// ShutdownOrExit writes diagnostics to e's Output: the state of bg's
+// unfinished Backgrounds and stack traces of all goroutines, and exits
+// the process with e's ExitCode.
+//
+// It gives operators evidence of what blocked the shutdown before
+// the process is killed by a supervisor.
+//
+// If e's Timeout is not positive, ShutdownOrExit is the same as bg's Shutdown.
+func ShutdownOrExit(ctx context.Context, bg Background, e Escalation) error {
+	if e.Timeout <= 0 {
+		return bg.Shutdown(ctx)
+	}
+
+	result := make(chan error, 1)
+
+	go func() { result <- bg.Shutdown(ctx) }()
+
+	timer := time.NewTimer(e.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+	}
+
+	w := e.Output
+	if w == nil {
+		w = os.Stderr
+	}
+
+	fmt.Fprintf(w, "background: shutdown didn't complete within %s\n\n", e.Timeout)
+	dumpTree(w, bg)
+	fmt.Fprintf(w, "\ngoroutines:\n\n%s", goroutines())
+
+	exit(e.ExitCode)
+
+	return nil
+}
+
+// dumpTree writes the state of unfinished Backgrounds in bg's tree to w.
+func dumpTree(w io.Writer, bg Background) {
+	fmt.Fprintln(w, "unfinished backgrounds:")
+
+	for _, n := range Snapshot(bg).Nodes {
+		if n.Finished {
+			continue
+		}
+
+		state := "not closing"
+		if n.Closing {
+			state = "closing"
+		}
+
+		name := n.Name
+		if name == "" {
+			name = "(no annotation)"
+		}
+
+		fmt.Fprintf(w, "\t%s: %s, %s\n", name, n.Type, state)
+	}
+}
+
+// goroutinesCreatedBy returns stack traces of goroutines created by
+// the function of stack's first frame, or of all goroutines if stack is nil.
+func goroutinesCreatedBy(stack Stack) []byte {
+	dump := goroutines()
+	if len(stack) == 0 {
+		return dump
+	}
+
+	frame, _ := runtime.CallersFrames(stack).Next()
+	creator := []byte("created by " + frame.Function)
+
+	var filtered []byte
+
+	for _, g := range bytes.Split(dump, []byte("\n\n")) {
+		if bytes.Contains(g, append(creator, ' ')) || bytes.Contains(g, append(creator, '\n')) {
+			filtered = append(filtered, g...)
+			filtered = append(filtered, "\n\n"...)
+		}
+	}
+
+	return filtered
+}
+
+// goroutines returns stack traces of all goroutines.
+func goroutines() []byte {
+	buf := make([]byte, 1<<16)
+
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,190 @@
+package background
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of a lifecycle Event.
+type EventKind int
+
+const (
+	// EventReady means that the Background became ready.
+	EventReady EventKind = iota
+	// EventClosing means that the Background's closing began.
+	EventClosing
+	// EventFinished means that the Background's closing is complete.
+	EventFinished
+	// EventShutdown means that the Shutdown of the Background
+	// created with WithEventLog was called.
+	EventShutdown
+	// EventShutdownResult means that the Shutdown of the Background
+	// created with WithEventLog returned, with its error if any.
+	EventShutdownResult
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventReady:
+		return "ready"
+	case EventClosing:
+		return "closing"
+	case EventFinished:
+		return "finished"
+	case EventShutdown:
+		return "shutdown"
+	case EventShutdownResult:
+		return "shutdown result"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a lifecycle event recorded by WithEventLog.
+type Event struct {
+	Time time.Time
+	Kind EventKind
+
+	// Name is the annotations on the path to the Background joined with ": ",
+	// the same way as in NodeSnapshot, empty for the Background created
+	// with WithEventLog.
+	Name string
+	ID   NodeID
+
+	// Err is the error returned by Shutdown for EventShutdownResult,
+	// or the Background's Err at the moment of EventFinished.
+	Err error
+}
+
+func (e Event) String() string {
+	s := e.Time.Format(time.RFC3339Nano) + " " + e.Kind.String()
+	if e.Name != "" {
+		s += " " + e.Name
+	}
+
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+
+	return s
+}
+
+// eventLogKey is a private Value key for eventLog.
+type eventLogKey struct{}
+
+// eventLog is a ring buffer of the last Events.
+type eventLog struct {
+	clock  Clock
+	events []Event
+	next   int
+	full   bool
+
+	sync.Mutex
+}
+
+func (l *eventLog) record(e Event) {
+	e.Time = l.clock.Now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.events[l.next] = e
+	l.next = (l.next + 1) % len(l.events)
+
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list returns the recorded Events from the oldest to the newest.
+func (l *eventLog) list() []Event {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.full {
+		return append([]Event(nil), l.events[:l.next]...)
+	}
+
+	return append(append([]Event(nil), l.events[l.next:]...), l.events[:l.next]...)
+}
+
+type eventLogBackground struct {
+	*group
+
+	log *eventLog
+}
+
+// WithEventLog returns new Background with merged children that keeps
+// the last size lifecycle events of the tree in memory, retrievable with
+// Events, e.g. to let a debug handler answer what happened during the last
+// shutdown attempt after the fact. If size is less than 1, 100 events
+// are kept.
+//
+// The events are recorded for the returned Background itself and for
+// the annotated Backgrounds among the children, so every event has
+// a name. Recording doesn't require any goroutines.
+func WithEventLog(size int, children ...Background) Background {
+	if size < 1 {
+		size = 100
+	}
+
+	e := &eventLogBackground{group: merge(children...)}
+	e.log = &eventLog{clock: clockOf(e.group), events: make([]Event, size)}
+
+	e.watch(e, e.group, "")
+
+	walkPath(e.group, func(bg Background, path []string) {
+		if a, ok := bg.(*annotationBackground); ok {
+			e.watch(a, a.group, strings.Join(path, ": "))
+		}
+	})
+
+	return e
+}
+
+// watch records the lifecycle events of bg with name, g is bg's group.
+func (e *eventLogBackground) watch(bg Background, g *group, name string) {
+	id := ID(bg)
+
+	bg.onReady(func() { e.log.record(Event{Kind: EventReady, Name: name, ID: id}) })
+	g.closing.subscribe(func() { e.log.record(Event{Kind: EventClosing, Name: name, ID: id}) })
+	g.finishing.subscribe(func() {
+		e.log.record(Event{Kind: EventFinished, Name: name, ID: id, Err: bg.Err()})
+	})
+}
+
+// Events returns the lifecycle events recorded by the first Background
+// created with WithEventLog in bg's tree, from the oldest to the newest,
+// or nil if there is none.
+func Events(bg Background) []Event {
+	if l, ok := bg.Value(eventLogKey{}).(*eventLog); ok {
+		return l.list()
+	}
+
+	return nil
+}
+
+func (e *eventLogBackground) Value(key interface{}) interface{} {
+	if key == (eventLogKey{}) {
+		return e.log
+	}
+
+	return e.group.Value(key)
+}
+
+func (e *eventLogBackground) Shutdown(ctx context.Context) error {
+	return e.result.do(ctx, e, func() error {
+		e.log.record(Event{Kind: EventShutdown, ID: ID(e)})
+
+		err := shutdown(ctx, e)
+		e.log.record(Event{Kind: EventShutdownResult, ID: ID(e), Err: err})
+
+		return err
+	})
+}
+
+func (e *eventLogBackground) DependsOn(children ...Background) Background {
+	return withDependency(e, children...)
+}
@@ -0,0 +1,37 @@
+//go:build !background_strict
+// +build !background_strict
+
+package background
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The strict mode adds the timeout cause to the error, so the output
+// of the example holds only without it.
+
+func ExampleWithAnnotation_shutdown() {
+	bg := func() Background {
+		bg, tail := WithShutdown()
+
+		go func() {
+			<-tail.End()
+			<-time.After(1 * time.Second)
+			tail.Done()
+		}()
+
+		return WithAnnotation("my job", bg)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	err := bg.Shutdown(ctx)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output: my job: timeout expired
+}
@@ -189,30 +189,6 @@ func ExampleWithAnnotation() {
 	// Output: my job: error
 }
 
-func ExampleWithAnnotation_shutdown() {
-	bg := func() Background {
-		bg, tail := WithShutdown()
-
-		go func() {
-			<-tail.End()
-			<-time.After(1 * time.Second)
-			tail.Done()
-		}()
-
-		return WithAnnotation("my job", bg)
-	}()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 0)
-	defer cancel()
-
-	err := bg.Shutdown(ctx)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	// Output: my job: timeout expired
-}
-
 func ExampleMerge() {
 	runJob := func(name string, duration time.Duration) Background {
 		bg, tail := WithShutdown()
@@ -89,24 +89,23 @@ func NewServer() background.Background {
 	return background.WithAnnotation("http server", bg)
 }
 
-type key int
+// ServerFatal is a channel of server's fatal errors provided in server's
+// Background.
+type ServerFatal chan error
 
-var fatalKey key
+func getServerFatalCh(bg background.Background) ServerFatal {
+	var fatal ServerFatal
 
-func getServerFatalCh(bg background.Background) chan error {
-	value := bg.Value(fatalKey)
-	if value != nil {
-		return value.(chan error)
-	}
+	background.Resolve(bg, &fatal)
 
-	return nil
+	return fatal
 }
 
 func (s *Server) Start() background.Background {
 	shutdownBg, shutdownTail := background.WithShutdown()
 	errBg, errTail := background.WithErrorGroup()
-	fatal := make(chan error)
-	serverFatalBg := background.WithValue(fatalKey, fatal)
+	fatal := make(ServerFatal)
+	serverFatalBg := background.Provide(fatal)
 
 	go func() {
 		err := s.Server.ListenAndServe()
@@ -0,0 +1,237 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JobErrors is the error returned by Err of Backgrounds created with GoN,
+// GoNLimit and GoEach when jobs fail.
+type JobErrors struct {
+	// Errs maps indexes of the failed jobs to their errors.
+	Errs map[int]error
+}
+
+// indexes returns the indexes of the failed jobs in ascending order.
+func (e *JobErrors) indexes() []int {
+	indexes := make([]int, 0, len(e.Errs))
+	for i := range e.Errs {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+func (e *JobErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, i := range e.indexes() {
+		msgs = append(msgs, fmt.Sprintf("job %d: %s", i, e.Errs[i]))
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the jobs' errors matches target.
+func (e *JobErrors) Is(target error) bool {
+	for _, i := range e.indexes() {
+		if errors.Is(e.Errs[i], target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the jobs' errors in the order of their indexes
+// that matches target, see errors.As.
+func (e *JobErrors) As(target interface{}) bool {
+	for _, i := range e.indexes() {
+		if errors.As(e.Errs[i], target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type fanOutBackground struct {
+	*shutdownBackground
+
+	errs map[int]error
+	mu   sync.RWMutex
+}
+
+// GoN returns new Background running n jobs simultaneously, each calls
+// fn with the job's index. It suits fan-out workers that would be
+// built with WithWait otherwise.
+//
+// The ctx passed to fn is canceled when the Background's closing begins,
+// and the closing waits for all jobs to return. The Background's Wait
+// blocks until all jobs return. Errors returned from fn are collected
+// in JobErrors returned by the Background's Err.
+func GoN(n int, fn func(ctx context.Context, i int) error) Background {
+	return goN(caller(2), n, 0, fn)
+}
+
+// GoNLimit is like GoN, but runs at most limit jobs simultaneously.
+// The jobs that haven't started when the closing begins are skipped.
+// If limit is less than 1, the number of jobs running simultaneously
+// is not limited.
+//
+// To run a job for every item of a slice, index the slice in fn:
+//
+//	background.GoNLimit(len(urls), 8, func(ctx context.Context, i int) error {
+//		return fetch(ctx, urls[i])
+//	})
+func GoNLimit(n, limit int, fn func(ctx context.Context, i int) error) Background {
+	return goN(caller(2), n, limit, fn)
+}
+
+// GoEach is like GoNLimit, but runs a job for every item of the slice items,
+// each calls fn with the item. The fn must be a function with the signature
+// func(ctx context.Context, item T) error, where the items are assignable
+// to T:
+//
+//	background.GoEach(urls, func(ctx context.Context, url string) error {
+//		return fetch(ctx, url)
+//	}, 8)
+//
+// The indexes of the failed jobs in JobErrors are the indexes of their items.
+//
+// GoEach takes items and fn of any types, as the package supports Go versions
+// without type parameters, and panics if items is not a slice or an array
+// or fn doesn't have the signature above.
+func GoEach(items interface{}, fn interface{}, limit int) Background {
+	n, each := eachFunc(items, fn)
+	return goN(caller(2), n, limit, each)
+}
+
+// contextType is the type of context.Context interface.
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// eachFunc returns the number of items and the function calling fn with
+// the item of the given index, see GoEach.
+func eachFunc(items interface{}, fn interface{}) (int, func(ctx context.Context, i int) error) {
+	list := reflect.ValueOf(items)
+	if kind := list.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		panic("background GoEach items must be a slice or an array")
+	}
+
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() || !eachSignature(f.Type(), list.Type().Elem()) {
+		panic("background GoEach fn must be a func(context.Context, T) error accepting the items")
+	}
+
+	return list.Len(), func(ctx context.Context, i int) error {
+		out := f.Call([]reflect.Value{reflect.ValueOf(&ctx).Elem(), list.Index(i)})
+
+		err, _ := out[0].Interface().(error)
+
+		return err
+	}
+}
+
+// eachSignature reports whether t is func(context.Context, T) error
+// where item is assignable to T.
+func eachSignature(t, item reflect.Type) bool {
+	return t.NumIn() == 2 && t.NumOut() == 1 && !t.IsVariadic() &&
+		t.In(0) == contextType && item.AssignableTo(t.In(1)) &&
+		t.Out(0) == errorType
+}
+
+func goN(component string, n, limit int, fn func(ctx context.Context, i int) error) *fanOutBackground {
+	if n < 0 {
+		n = 0
+	}
+
+	var (
+		w = withWait()
+		f = &fanOutBackground{
+			shutdownBackground: withShutdown(w),
+			errs:               make(map[int]error),
+		}
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w.Add(n)
+
+	goLabeled(component, func() {
+		go f.run(ctx, w, n, limit, fn)
+
+		<-f.End()
+		cancel()
+		w.Wait()
+		f.Done()
+	})
+
+	return f
+}
+
+// run starts the jobs and skips the ones that haven't started
+// when ctx is done.
+func (f *fanOutBackground) run(ctx context.Context, w *waitBackground, n, limit int, fn func(ctx context.Context, i int) error) {
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	for i := 0; i < n; i++ {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			w.Add(i - n)
+			return
+		}
+
+		go func(i int) {
+			defer w.Done()
+
+			if err := fn(ctx, i); err != nil {
+				f.mu.Lock()
+				f.errs[i] = err
+				f.mu.Unlock()
+
+				errorsChanged()
+			}
+
+			if sem != nil {
+				<-sem
+			}
+		}(i)
+	}
+}
+
+// Err returns JobErrors if any job failed, otherwise it returns the first
+// encountered error in Background's children.
+func (f *fanOutBackground) Err() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.errs) == 0 {
+		return f.shutdownBackground.Err()
+	}
+
+	errs := make(map[int]error, len(f.errs))
+	for i, err := range f.errs {
+		errs[i] = err
+	}
+
+	return &JobErrors{Errs: errs}
+}
+
+func (f *fanOutBackground) DependsOn(children ...Background) Background {
+	return withDependency(f, children...)
+}
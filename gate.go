@@ -0,0 +1,21 @@
+package background
+
+import "net/http"
+
+// RequireReady returns http.Handler that responds with 503 Service
+// Unavailable until bg is ready and once bg begins closing, and passes
+// the requests to next otherwise. It keeps application handlers from serving
+// before their dependencies are warmed up and stops the traffic during
+// the shutdown:
+//
+//	http.Handle("/api/", background.RequireReady(bg, api))
+func RequireReady(bg Background, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bg.IsClosing() || !isClosed(bg.Ready()) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,3 +1,5 @@
 module github.com/lefelys/background
 
 go 1.13
+
+require go.uber.org/goleak v1.1.10
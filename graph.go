@@ -0,0 +1,97 @@
+package background
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Factory creates a component's Background.
+// Following the package's rules, initialization errors should be returned
+// as Background using WithError.
+type Factory func() Background
+
+// Registry maps factory names to factories.
+type Registry map[string]Factory
+
+// Graph is a declarative description of a tree of Backgrounds: components
+// and dependencies between them. It allows keeping the shutdown ordering
+// next to the deployment configuration.
+type Graph struct {
+	Components []GraphComponent `json:"components"`
+}
+
+// GraphComponent describes a component of Graph.
+type GraphComponent struct {
+	// Name is a unique name of the component.
+	Name string `json:"name"`
+
+	// Factory is the name of the factory in Registry that creates
+	// the component. Defaults to Name.
+	Factory string `json:"factory,omitempty"`
+
+	// Annotation is the annotation assigned to the component, if not empty.
+	Annotation string `json:"annotation,omitempty"`
+
+	// DependsOn lists names of components that are shut down before
+	// the component.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// ParseGraph parses the JSON-encoded Graph.
+func ParseGraph(data []byte) (Graph, error) {
+	var g Graph
+
+	if err := json.Unmarshal(data, &g); err != nil {
+		return Graph{}, fmt.Errorf("parse graph: %w", err)
+	}
+
+	return g, nil
+}
+
+// Build validates the graph and builds the Background using factories from r.
+// Factories are called only after the whole graph is validated, so no
+// components are initialized if the graph is invalid.
+//
+// The returned Background is built the same way as in Builder's Build.
+func (g Graph) Build(r Registry) (Background, error) {
+	factories := make([]Factory, len(g.Components))
+
+	for i, c := range g.Components {
+		name := c.Factory
+		if name == "" {
+			name = c.Name
+		}
+
+		f, ok := r[name]
+		if !ok || f == nil {
+			return nil, fmt.Errorf("component %q: unknown factory %q", c.Name, name)
+		}
+
+		factories[i] = f
+	}
+
+	if err := g.builder(func(int) Background { return Empty() }).validate(); err != nil {
+		return nil, err
+	}
+
+	return g.builder(func(i int) Background { return factories[i]() }).Build()
+}
+
+// builder returns Builder with graph's components created by create.
+func (g Graph) builder(create func(i int) Background) *Builder {
+	b := NewBuilder()
+
+	for i, c := range g.Components {
+		b.Add(c.Name, create(i))
+
+		if c.Annotation != "" {
+			b.Annotate(c.Name, c.Annotation)
+		}
+
+		if len(c.DependsOn) != 0 {
+			b.AddDependency(c.Name, c.DependsOn...)
+		}
+	}
+
+	return b
+}
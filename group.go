@@ -2,73 +2,293 @@ package background
 
 import (
 	"context"
+	"reflect"
 	"sync"
+	"sync/atomic"
+)
+
+// closeOrder defines the order in which group closes its children.
+type closeOrder int
+
+const (
+	// closeConcurrent closes all children simultaneously.
+	closeConcurrent closeOrder = iota
+	// closeSequential closes children one by one in the order they were merged.
+	closeSequential
+	// closeReverse closes children one by one in the reverse order.
+	closeReverse
 )
 
 type group struct {
+	// noErrVersion is errVersion+1 at the moment when group's Err returned nil
+	// or zero if there is no cached result. It is accessed atomically and
+	// must stay the first field to be 64-bit aligned on 32-bit platforms.
+	noErrVersion int64
+
+	// abandoned is 1 if the group gave up waiting for its children,
+	// see Options' Abandon. It is accessed atomically.
+	abandoned int32
+
+	// values caches results of Value calls if cached is true.
+	values sync.Map
+
+	// cached is true if the results of Err and Value calls are cached,
+	// see cacheable.
+	cached bool
+
+	id NodeID
+
 	backgrounds []Background
 	toClose     map[int]struct{}
+	order       closeOrder
+
+	// source describes how the children were passed to the group.
+	source Source
+
+	// shared is true if the group is returned by an exported function,
+	// so it may be held outside of the trees it's merged into and must
+	// not be flattened, see flatten.
+	shared bool
 
+	// preFinished holds indexes of the children that were already finished
+	// when merged. They are not awaited, but still take part in Err, Value
+	// and cause calls.
+	preFinished []int
+
+	// limit is the maximum number of children closed simultaneously,
+	// zero means no limit.
+	limit int
+
+	// finished is closed when there is nothing left to close: it is closed
+	// from the start for groups without children. finishedOut is fired when
+	// the closing of the group is complete and backs the public Finished.
 	done, finished chan struct{}
-	ready          chan struct{}
+	finishedOut    readySignal
+	ready          readySignal
+	result         shutdownResult
+
+	// closing is fired when the closing begins and finishing is fired
+	// right before it is complete.
+	closing, finishing readySignal
 
 	sync.RWMutex
 }
 
 // Merge returns new Background with merged children.
+// During shutdown the children are closed simultaneously.
 func Merge(bgs ...Background) Background {
-	return merge(bgs...)
+	return share(withSource(SourceMerge, merge(bgs...)))
+}
+
+// MergeSequential returns new Background with merged children.
+// During shutdown the children are closed strictly in the order they were
+// passed: every child is closed only after the previous one is
+// successfully closed.
+func MergeSequential(bgs ...Background) Background {
+	return share(withSource(SourceMerge, mergeOrdered(closeSequential, bgs...)))
+}
+
+// MergeReverse returns new Background with merged children.
+// During shutdown the children are closed strictly in the reverse order:
+// every child is closed only after the next one is successfully closed.
+func MergeReverse(bgs ...Background) Background {
+	return share(withSource(SourceMerge, mergeOrdered(closeReverse, bgs...)))
+}
+
+// MergeWithLimit returns new Background with merged children.
+// During shutdown at most n children are closed simultaneously.
+// If n is less than 1, the number of simultaneously closed children
+// is not limited.
+func MergeWithLimit(n int, bgs ...Background) Background {
+	return share(withSource(SourceMerge, mergeWithLimit(n, bgs...)))
+}
+
+func mergeWithLimit(n int, bgs ...Background) *group {
+	if n < 0 {
+		n = 0
+	}
+
+	return mergeGroup(closeConcurrent, n, bgs...)
 }
 
 func merge(bgs ...Background) *group {
+	return mergeGroup(closeConcurrent, 0, bgs...)
+}
+
+func mergeOrdered(order closeOrder, bgs ...Background) *group {
+	return mergeGroup(order, 0, bgs...)
+}
+
+func mergeGroup(order closeOrder, limit int, bgs ...Background) *group {
+	if order == closeConcurrent && limit == 0 {
+		bgs = flatten(bgs)
+	}
+
 	if len(bgs) == 0 {
 		return &group{
+			id:       nextID(),
+			order:    order,
+			limit:    limit,
+			source:   SourceChildren,
+			cached:   true,
 			done:     closedchan,
 			finished: closedchan,
 		}
 	}
 
 	var (
-		ss       = make([]Background, 0, len(bgs))
-		done     = make(chan struct{})
-		finished = make(chan struct{})
-		toClose  = make(map[int]struct{})
+		ss          = make([]Background, 0, len(bgs))
+		done        = make(chan struct{})
+		finished    = make(chan struct{})
+		toClose     = make(map[int]struct{})
+		seen        = make(map[Background]struct{}, len(bgs))
+		preFinished []int
+		cached      = true
 	)
 
-	for i, s := range bgs {
+	for _, s := range bgs {
 		if s == nil {
 			continue
 		}
 
+		// the same Background merged multiple times is kept only once,
+		// so it's closed and reported once
+		if hashable(s) {
+			if _, ok := seen[s]; ok {
+				continue
+			}
+
+			seen[s] = struct{}{}
+		}
 		ss = append(ss, s)
+		cached = cached && cacheable(s)
 
 		select {
 		case <-s.finishSig():
 			// already closed
+			preFinished = append(preFinished, len(ss)-1)
 		default:
-			toClose[i] = struct{}{}
-
-			addToCloseStream(done, s)
+			toClose[len(ss)-1] = struct{}{}
 		}
 	}
 
 	return &group{
+		id:          nextID(),
 		backgrounds: ss,
 		toClose:     toClose,
+		preFinished: preFinished,
+		source:      SourceChildren,
+		cached:      cached,
+		order:       order,
+		limit:       limit,
 		done:        done,
 		finished:    finished,
 	}
 }
 
-func addToCloseStream(done <-chan struct{}, c Background) {
-	go func() {
-		<-done
-		c.close()
-	}()
+// share marks g as held by the caller of an exported function and returns g.
+func share(g *group) *group {
+	g.shared = true
+	return g
+}
+
+// withSource sets the source of g's children and returns g.
+func withSource(source Source, g *group) *group {
+	g.source = source
+	return g
+}
+
+// flatten replaces internal plain groups in bgs with their children to keep
+// the trees shallow. Only groups that close their children simultaneously
+// without limit are flattened, as inlining their children into such a group
+// doesn't change shutdown semantics. The groups returned by Merge and
+// similar functions are kept, as they are closed and reported on their own.
+func flatten(bgs []Background) []Background {
+	var flat []Background
+
+	for i, bg := range bgs {
+		g, ok := bg.(*group)
+		if !ok || g.shared || g.order != closeConcurrent || g.limit != 0 {
+			if flat != nil {
+				flat = append(flat, bg)
+			}
+
+			continue
+		}
+
+		if flat == nil {
+			flat = append(make([]Background, 0, len(bgs)+len(g.backgrounds)), bgs[:i]...)
+		}
+
+		flat = append(flat, g.backgrounds...)
+	}
+
+	if flat == nil {
+		return bgs
+	}
+
+	return flat
 }
 
 func (g *group) Shutdown(ctx context.Context) error {
-	return shutdown(ctx, g)
+	return g.result.do(ctx, g, func() error { return shutdown(ctx, g) })
+}
+
+func (g *group) ShutdownResult() (error, bool) {
+	return g.result.get()
+}
+
+func (g *group) Reload(ctx context.Context) error {
+	errs := make([]error, len(g.backgrounds))
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(g.backgrounds))
+
+	for i, bg := range g.backgrounds {
+		go func(i int, bg Background) {
+			errs[i] = bg.Reload(ctx)
+			wg.Done()
+		}(i, bg)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *group) Pause() {
+	for _, bg := range g.backgrounds {
+		bg.Pause()
+	}
+}
+
+func (g *group) Resume() {
+	for _, bg := range g.backgrounds {
+		bg.Resume()
+	}
+}
+
+func (g *group) Finished() <-chan struct{} {
+	return g.finishedOut.channel()
+}
+
+func (g *group) Closing() <-chan struct{} {
+	return g.closing.channel()
+}
+
+func (g *group) IsFinished() bool {
+	return g.finishedOut.isFired()
+}
+
+func (g *group) IsClosing() bool {
+	return g.closing.isFired()
 }
 
 func (g *group) finishSig() <-chan struct{} {
@@ -81,79 +301,256 @@ func (g *group) Wait() {
 	}
 }
 
-func (g *group) Ready() <-chan struct{} {
-	g.Lock()
-	defer g.Unlock()
-
-	if g.ready != nil {
-		// To avoid memory leaks - ready channel is created only once
-		return g.ready
+func (g *group) WaitContext(ctx context.Context) error {
+	for _, m := range g.backgrounds {
+		if err := m.WaitContext(ctx); err != nil {
+			return err
+		}
 	}
 
-	g.ready = make(chan struct{})
+	return nil
+}
 
-	go func() {
-		for _, m := range g.backgrounds {
-			<-m.Ready()
-		}
+func (g *group) Ready() <-chan struct{} {
+	g.followReady()
+	return g.ready.channel()
+}
 
-		close(g.ready)
-	}()
+func (g *group) onReady(f func()) {
+	g.followReady()
+	g.ready.subscribe(f)
+}
+
+func (g *group) followReady() {
+	sources := make([]func(func()), len(g.backgrounds))
+	for i, m := range g.backgrounds {
+		sources[i] = m.onReady
+	}
 
-	return g.ready
+	g.ready.follow(sources...)
 }
 
-func (g *group) close() {
+func (g *group) close(ctx context.Context) {
+	g.prepare()
+
 	g.Lock()
 	select {
 	case <-g.done:
 		g.Unlock()
+
+		// the group without children has nothing to close
+		if isClosed(g.finished) {
+			g.finishing.fire()
+			g.finishedOut.fire()
+		}
+
 		return // already closed
 	default:
 		close(g.done)
 	}
 	g.Unlock()
 
+	// the pre-finished children have nothing to close, but their closing
+	// begins with the group's one, so they report it
+	for _, i := range g.preFinished {
+		g.backgrounds[i].close(ctx)
+	}
+
+	if g.order == closeConcurrent {
+		g.closeConcurrently(ctx)
+	} else {
+		g.closeInOrder(ctx)
+	}
+
 	for i := range g.toClose {
-		<-g.backgrounds[i].finishSig()
+		if !awaitFinish(ctx, g, g.backgrounds[i]) {
+			return
+		}
+
 		g.Lock()
 		delete(g.toClose, i)
 		g.Unlock()
 	}
 
+	g.finishing.fire()
+
+	g.Lock()
 	close(g.finished)
+	g.Unlock()
+
+	g.finishedOut.fire()
+}
+
+// closeInOrder closes group's children one by one in group's order.
+func (g *group) closeInOrder(ctx context.Context) {
+	for j := range g.backgrounds {
+		i := j
+		if g.order == closeReverse {
+			i = len(g.backgrounds) - 1 - j
+		}
+
+		g.RLock()
+		_, ok := g.toClose[i]
+		g.RUnlock()
+
+		if ok {
+			g.backgrounds[i].close(ctx)
+
+			if !awaitFinish(ctx, g, g.backgrounds[i]) {
+				return
+			}
+		}
+	}
+}
+
+// closeConcurrently closes group's children simultaneously keeping at most
+// g.limit of them closing at the same time if the limit is set, or Options'
+// CloseLimit otherwise.
+//
+// Goroutines closing the children are started only when the closing begins,
+// so idle groups do not hold any goroutines.
+func (g *group) closeConcurrently(ctx context.Context) {
+	limit := g.limit
+	if limit == 0 {
+		limit = optionsFrom(ctx).CloseLimit
+	}
+
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	for i, bg := range g.backgrounds {
+		g.RLock()
+		_, ok := g.toClose[i]
+		g.RUnlock()
+
+		if !ok {
+			continue
+		}
+
+		if sem == nil {
+			go bg.close(ctx)
+			continue
+		}
+
+		sem <- struct{}{}
+
+		go func(bg Background) {
+			bg.close(ctx)
+			awaitFinish(ctx, g, bg)
+			<-sem
+		}(bg)
+	}
+}
+
+// cacheable reports whether the results of bg's Err and Value calls can be
+// cached: bg is one of the package's Backgrounds whose children never change,
+// whose values are immutable and whose errors are assigned with errorsChanged,
+// and so are all Backgrounds in its tree. The Backgrounds of other packages,
+// e.g. the types embedding Background, and the ones that start their children
+// later, e.g. Lazy, are never cached.
+func cacheable(bg Background) bool {
+	switch bg := bg.(type) {
+	case emptyBackground:
+		return true
+	case *group:
+		return bg.cached
+	case *annotationBackground:
+		return bg.cached
+	case *valueBackground:
+		return bg.cached
+	case *errBackground:
+		return bg.cached
+	case *errGroupBackground:
+		return bg.cached
+	case *keyedErrorBackground:
+		return bg.cached
+	case *readinessBackground:
+		return bg.cached
+	case *shutdownBackground:
+		return bg.cached
+	case *waitBackground:
+		return bg.cached
+	case *dependBackground:
+		return bg.children.cached && cacheable(bg.parent)
+	}
+
+	return false
 }
 
 func (g *group) Err() error {
+	if !g.cached {
+		for _, bg := range g.backgrounds {
+			if err := bg.Err(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	version := atomic.LoadInt64(&errVersion)
+
+	// fast path: no errors were assigned since the last check
+	if atomic.LoadInt64(&g.noErrVersion) == version+1 {
+		return nil
+	}
+
 	for _, bg := range g.backgrounds {
 		if err := bg.Err(); err != nil {
 			return err
 		}
 	}
 
+	atomic.StoreInt64(&g.noErrVersion, version+1)
+
 	return nil
 }
 
+// noValue is stored in group's values cache for keys without value.
+type noValue struct{}
+
 func (g *group) Value(key interface{}) (value interface{}) {
+	cacheable := g.cached && key != nil && reflect.TypeOf(key).Comparable()
+
+	if cacheable {
+		if value, ok := g.values.Load(key); ok {
+			if value == (noValue{}) {
+				return nil
+			}
+
+			return value
+		}
+	}
+
 	for _, bg := range g.backgrounds {
 		if value = bg.Value(key); value != nil {
-			return value
+			break
 		}
 	}
 
-	return nil
+	if cacheable {
+		if value == nil {
+			g.values.Store(key, noValue{})
+		} else {
+			g.values.Store(key, value)
+		}
+	}
+
+	return value
 }
 
 func (g *group) DependsOn(children ...Background) Background {
 	return withDependency(g, children...)
 }
 
-func (g *group) cause() error {
+func (g *group) cause(ctx context.Context) error {
 	g.RLock()
 	defer g.RUnlock()
 
 	for _, bg := range g.backgrounds {
-		if err := bg.cause(); err != nil {
+		if err := bg.cause(ctx); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,112 @@
+package background
+
+import (
+	"strings"
+	"sync"
+)
+
+type healthBackground struct {
+	*group
+
+	// reason is the reason of the degradation, empty if the Background
+	// is healthy.
+	reason string
+
+	mu sync.RWMutex
+}
+
+// HealthTail detaches after health Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background, e.g. in a connection pool that tracks its brokers.
+type HealthTail interface {
+	// Degrade marks the Background as degraded with reason, replacing
+	// the previous reason if it is already degraded. Unlike errors,
+	// the degradation doesn't affect the tree's readiness nor Err:
+	// the Background keeps working with reduced capacity.
+	Degrade(reason string)
+
+	// Recover marks the Background as healthy again.
+	// If the Background isn't degraded - does nothing.
+	Recover()
+}
+
+// WithHealth returns new Background with merged children and HealthTail
+// that reports partial outages of a component, e.g. one of three brokers
+// being down. The degradation is visible with Degraded and App's probes,
+// but doesn't make the tree unready.
+func WithHealth(children ...Background) (Background, HealthTail) {
+	h := withHealth(children...)
+	return h, h
+}
+
+func withHealth(children ...Background) *healthBackground {
+	return &healthBackground{group: merge(children...)}
+}
+
+func (h *healthBackground) Degrade(reason string) {
+	if reason == "" {
+		reason = "degraded"
+	}
+
+	h.mu.Lock()
+	h.reason = reason
+	h.mu.Unlock()
+}
+
+func (h *healthBackground) Recover() {
+	h.mu.Lock()
+	h.reason = ""
+	h.mu.Unlock()
+}
+
+func (h *healthBackground) degradation() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.reason
+}
+
+func (h *healthBackground) DependsOn(children ...Background) Background {
+	return withDependency(h, children...)
+}
+
+// Degradation describes a degraded component.
+type Degradation struct {
+	// Name is the annotations on the path to the component joined with ": ",
+	// the same way as they annotate errors, or empty if there are none.
+	Name string
+
+	// Reason is the reason passed to HealthTail's Degrade.
+	Reason string
+}
+
+func (d Degradation) String() string {
+	if d.Name == "" {
+		return d.Reason
+	}
+
+	return d.Name + ": " + d.Reason
+}
+
+// Degraded returns the components created with WithHealth in bg's tree
+// that are currently degraded, in the same order as Background.Value
+// searches the tree. It returns nil if all of them are healthy.
+func Degraded(bg Background) []Degradation {
+	var degradations []Degradation
+
+	walkPath(bg, func(bg Background, path []string) {
+		h, ok := bg.(*healthBackground)
+		if !ok {
+			return
+		}
+
+		if reason := h.degradation(); reason != "" {
+			degradations = append(degradations, Degradation{
+				Name:   strings.Join(path, ": "),
+				Reason: reason,
+			})
+		}
+	})
+
+	return degradations
+}
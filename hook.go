@@ -0,0 +1,209 @@
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hookPhase defines when hookBackground runs its hook.
+type hookPhase int
+
+const (
+	// hookOnShutdown runs the hook when the closing begins,
+	// before the children are closed.
+	hookOnShutdown hookPhase = iota
+	// hookOnFinished runs the hook after the children are closed.
+	hookOnFinished
+	// hookOnLeave runs the hook tree-wide before any Background in the tree
+	// begins closing.
+	hookOnLeave
+)
+
+type hookBackground struct {
+	*group
+
+	fn    func(ctx context.Context) error
+	phase hookPhase
+
+	// runOnce ensures that the hook runs once, ran is closed after the run.
+	runOnce sync.Once
+	ran     chan struct{}
+
+	err      error
+	finished chan struct{}
+	closedAt time.Time
+
+	// closeOnce ensures that the Background is closed once.
+	closeOnce sync.Once
+
+	sync.RWMutex
+}
+
+// WithShutdownHook returns new Background with merged children that runs fn
+// when its closing begins, before the children are closed. It suits simple
+// cleanup steps that don't require a background job, e.g. deregistration
+// from service discovery.
+//
+// The ctx passed to fn is the context of the Shutdown call that initiated
+// the closing. An error returned from fn is returned by the Background's Err
+// and Shutdown.
+func WithShutdownHook(fn func(ctx context.Context) error, children ...Background) Background {
+	return withHook(fn, hookOnShutdown, children...)
+}
+
+// WithFinishedHook is like WithShutdownHook, but fn runs after the children
+// are closed, e.g. to flush a buffer the children were writing to.
+func WithFinishedHook(fn func(ctx context.Context) error, children ...Background) Background {
+	return withHook(fn, hookOnFinished, children...)
+}
+
+// WithLeaveHook is like WithShutdownHook, but fn announces leaving:
+// the leave hooks of the whole tree being shut down run simultaneously before
+// any Background in the tree begins closing, and the closing begins after
+// all of them return. It suits the steps that must precede draining,
+// e.g. removal from a load balancer or service discovery.
+//
+// Leave hooks run on Shutdown of any Background in the tree containing them,
+// on ShutdownLevels and on deadline expiration, and are run by the closing
+// of their Background otherwise.
+func WithLeaveHook(fn func(ctx context.Context) error, children ...Background) Background {
+	return withHook(fn, hookOnLeave, children...)
+}
+
+func withHook(fn func(ctx context.Context) error, phase hookPhase, children ...Background) *hookBackground {
+	return &hookBackground{
+		group:    merge(children...),
+		fn:       fn,
+		phase:    phase,
+		ran:      make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+// announce signals the drainable Backgrounds of bg's tree to drain,
+// runs the leave hooks of the tree simultaneously and waits for them.
+func announce(ctx context.Context, bg Background) {
+	var wg sync.WaitGroup
+
+	walk(bg, func(bg Background) {
+		if d, ok := bg.(*drainBackground); ok {
+			d.drain.fire()
+		}
+
+		if h, ok := bg.(*hookBackground); ok && h.phase == hookOnLeave {
+			wg.Add(1)
+
+			go func() {
+				h.run(ctx)
+				wg.Done()
+			}()
+		}
+	})
+
+	wg.Wait()
+}
+
+// Err returns the error returned from the hook, otherwise it returns
+// the first encountered error in Background's children.
+func (h *hookBackground) Err() error {
+	h.RLock()
+	err := h.err
+	h.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return h.group.Err()
+}
+
+func (h *hookBackground) Shutdown(ctx context.Context) error {
+	return h.result.do(ctx, h, func() error {
+		if err := shutdown(ctx, h); err != nil {
+			return err
+		}
+
+		h.RLock()
+		defer h.RUnlock()
+
+		return h.err
+	})
+}
+
+func (h *hookBackground) close(ctx context.Context) {
+	h.closeOnce.Do(func() {
+		h.closing.fire()
+
+		h.Lock()
+		h.closedAt = time.Now()
+		h.Unlock()
+
+		if h.phase != hookOnFinished {
+			h.run(ctx)
+		}
+
+		h.group.close(ctx)
+		if !awaitFinish(ctx, h.group, h.group) {
+			return
+		}
+
+		if h.phase == hookOnFinished {
+			h.run(ctx)
+		}
+
+		close(h.finished)
+	})
+}
+
+// run runs the hook once and records its error. Simultaneous calls block
+// until the hook returns.
+func (h *hookBackground) run(ctx context.Context) {
+	h.runOnce.Do(func() {
+		defer close(h.ran)
+
+		err := h.fn(ctx)
+		if err == nil {
+			return
+		}
+
+		h.Lock()
+		h.err = err
+		h.Unlock()
+
+		errorsChanged()
+	})
+}
+
+func (h *hookBackground) Finished() <-chan struct{} {
+	return h.finished
+}
+
+func (h *hookBackground) IsFinished() bool {
+	return isClosed(h.finished)
+}
+
+func (h *hookBackground) finishSig() <-chan struct{} {
+	return h.finished
+}
+
+func (h *hookBackground) DependsOn(children ...Background) Background {
+	return withDependency(h, children...)
+}
+
+func (h *hookBackground) cause(ctx context.Context) error {
+	if err := h.group.cause(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-h.finished:
+		return nil
+	default:
+		h.RLock()
+		closedAt := h.closedAt
+		h.RUnlock()
+
+		return newTimeoutError(ctx, closedAt)
+	}
+}
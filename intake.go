@@ -0,0 +1,178 @@
+package background
+
+import (
+	"fmt"
+	"sync"
+)
+
+// intakeKey is a private Value key for intakeBackground.
+type intakeKey struct{}
+
+type intakeBackground struct {
+	*errGroupBackground
+
+	policy IntakePolicy
+	errs   chan error
+
+	// received is the number of errors passed to the tail,
+	// last is the last error put into errs.
+	received int
+	last     error
+
+	mu sync.Mutex
+}
+
+// IntakePolicy defines which errors an error intake keeps, see
+// WithErrorIntake.
+type IntakePolicy struct {
+	// dropOldest makes room for new errors in the full intake by dropping
+	// the oldest ones, otherwise the new errors are dropped.
+	dropOldest bool
+
+	// sample is N to keep every Nth error, values below 2 keep all errors.
+	sample int
+
+	// coalesce drops errors identical to the last kept one while it is
+	// still in the intake.
+	coalesce bool
+}
+
+// DropNewest returns IntakePolicy that drops new errors while the intake
+// is full. It is the policy of the zero IntakePolicy.
+func DropNewest() IntakePolicy {
+	return IntakePolicy{}
+}
+
+// DropOldest returns IntakePolicy that drops the oldest errors to make room
+// for new ones while the intake is full.
+func DropOldest() IntakePolicy {
+	return IntakePolicy{dropOldest: true}
+}
+
+// SampleEvery returns IntakePolicy that keeps only every nth error,
+// starting from the first one, and drops new errors while the intake is full.
+func SampleEvery(n int) IntakePolicy {
+	return IntakePolicy{sample: n}
+}
+
+// CoalesceIdentical returns IntakePolicy that drops errors with the same
+// message as the last kept error while it's not received from the intake,
+// and drops new errors while the intake is full.
+func CoalesceIdentical() IntakePolicy {
+	return IntakePolicy{coalesce: true}
+}
+
+// WithErrorIntake returns new error group Background with merged children
+// and a bounded intake of errors for jobs that can produce many errors per
+// second. Errors are received from the intake with Errors.
+//
+// The returned ErrTail never blocks: errors passed to it are put into
+// the intake of size errors according to the policy. It implements
+// ErrTryTail: TryError reports whether the error was kept. The first error
+// is also assigned to the Background the same way as in WithErrorGroup.
+// If size is less than 1, the size of 1 is used.
+func WithErrorIntake(size int, policy IntakePolicy, children ...Background) (Background, ErrTail) {
+	i := withErrorIntake(size, policy, children...)
+	return i, i
+}
+
+func withErrorIntake(size int, policy IntakePolicy, children ...Background) *intakeBackground {
+	if size < 1 {
+		size = 1
+	}
+
+	return &intakeBackground{
+		errGroupBackground: withErrorGroup(children...),
+		policy:             policy,
+		errs:               make(chan error, size),
+	}
+}
+
+// Errors returns the channel of the first error intake in bg's tree,
+// see WithErrorIntake, or nil if there is none. The tree is searched
+// the same way as in Background.Value.
+func Errors(bg Background) <-chan error {
+	if i, ok := bg.Value(intakeKey{}).(*intakeBackground); ok {
+		return i.errs
+	}
+
+	return nil
+}
+
+// Error puts err into the intake according to the policy.
+// If the Background has no error - assigns err to it.
+func (i *intakeBackground) Error(err error) {
+	i.setError(err, callers(1))
+	i.push(err)
+}
+
+// TryError puts err into the intake according to the policy and reports
+// whether err was kept. If the Background has no error - assigns err to it.
+func (i *intakeBackground) TryError(err error) bool {
+	i.setError(err, callers(1))
+	return i.push(err)
+}
+
+// Errorf formats according to a format specifier and puts the string
+// into the intake as a value that satisfies error. If the Background has
+// no error - assigns the error to it.
+func (i *intakeBackground) Errorf(format string, a ...interface{}) {
+	err := fmt.Errorf(format, a...)
+
+	i.setError(err, callers(1))
+	i.push(err)
+}
+
+// push puts err into the intake according to the policy
+// and reports whether err was kept.
+func (i *intakeBackground) push(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.received++
+
+	if i.policy.sample > 1 && (i.received-1)%i.policy.sample != 0 {
+		return false
+	}
+
+	// while the intake isn't empty, the last kept error is still in it
+	if i.policy.coalesce && i.last != nil && len(i.errs) > 0 && i.last.Error() == err.Error() {
+		return false
+	}
+
+	for {
+		select {
+		case i.errs <- err:
+			i.last = err
+			return true
+		default:
+		}
+
+		if !i.policy.dropOldest {
+			return false
+		}
+
+		select {
+		case <-i.errs:
+		default:
+		}
+	}
+}
+
+// Value returns the Background itself if key is intakeKey, otherwise
+// it returns value associated with key from its children.
+func (i *intakeBackground) Value(key interface{}) interface{} {
+	if key == (intakeKey{}) {
+		return i
+	}
+
+	return i.errGroupBackground.Value(key)
+}
+
+func (i *intakeBackground) DependsOn(children ...Background) Background {
+	return withDependency(i, children...)
+}
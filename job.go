@@ -0,0 +1,64 @@
+package background
+
+import "context"
+
+// Go returns new Background running fn as a background job. It covers
+// small jobs that need no tails.
+//
+// The ctx passed to fn is canceled when the Background's closing begins,
+// and the closing waits for fn to return. The error returned from fn is
+// returned by the Background's Err.
+func Go(fn func(ctx context.Context) error) Background {
+	return goJob(caller(2), closedchan, fn)
+}
+
+// ReadyThen returns new Background running fn as a background job once bg
+// is ready. It allows jobs such as cache preloads to depend on the readiness
+// of other components.
+//
+// The ctx passed to fn is canceled when the returned Background's closing
+// begins, and the closing waits for fn to return. If the closing begins
+// before bg is ready, fn is not run. The error returned from fn is returned
+// by the Background's Err.
+//
+// bg is not a part of the returned Background's tree. To shut down the job
+// before bg, make bg depend on it:
+//
+//	bg = bg.DependsOn(background.ReadyThen(bg, preload))
+func ReadyThen(bg Background, fn func(ctx context.Context) error) Background {
+	return goJob(caller(2), bg.Ready(), fn)
+}
+
+// goJob returns new Background running fn once start is closed in
+// goroutines labeled with component.
+func goJob(component string, start <-chan struct{}, fn func(ctx context.Context) error) Background {
+	errs := withErrorGroup()
+	s := withShutdown(errs)
+
+	goLabeled(component, func() {
+		defer s.Done()
+
+		select {
+		case <-start:
+		case <-s.End():
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			if err := fn(ctx); err != nil {
+				errs.Error(err)
+			}
+
+			close(done)
+		}()
+
+		<-s.End()
+		cancel()
+		<-done
+	})
+
+	return s
+}
@@ -0,0 +1,147 @@
+// Package k8s provides graceful termination of Backgrounds in Kubernetes pods.
+//
+// On pod deletion Kubernetes sends SIGTERM to the container and at the same
+// time starts removing the pod from Service endpoints. The removal takes some
+// time, so the process keeps receiving new connections for a while after
+// SIGTERM. Once the termination grace period passes, the container is killed.
+//
+// Terminate codifies the standard graceful termination: it waits for
+// the preStop delay to let the endpoints deprogram, and then shuts down
+// the Background with the rest of the termination grace period as the budget.
+package k8s
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+const (
+	// EnvGracePeriod is the environment variable with the pod's
+	// terminationGracePeriodSeconds, e.g. set from the pod spec.
+	EnvGracePeriod = "TERMINATION_GRACE_PERIOD_SECONDS"
+
+	// EnvPreStopDelay is the environment variable with the preStop delay
+	// in seconds.
+	EnvPreStopDelay = "PRE_STOP_DELAY_SECONDS"
+
+	// DefaultGracePeriod is the Kubernetes default terminationGracePeriodSeconds.
+	DefaultGracePeriod = 30 * time.Second
+
+	// DefaultPreStopDelay is the default delay before the shutdown starts.
+	DefaultPreStopDelay = 5 * time.Second
+
+	// DefaultMargin is the default part of the grace period reserved for
+	// the process to exit after the shutdown.
+	DefaultMargin = time.Second
+)
+
+// ErrTerminated is the reason of the shutdown started by Terminate,
+// available to background jobs via ShutdownTail's CloseInfo.
+var ErrTerminated = errors.New("pod termination")
+
+// Config configures the graceful termination.
+type Config struct {
+	// GracePeriod is the pod's termination grace period.
+	GracePeriod time.Duration
+
+	// PreStopDelay is the delay between the termination signal and the start
+	// of the shutdown, during which endpoints stop routing traffic to the pod.
+	PreStopDelay time.Duration
+
+	// Margin is the part of the grace period reserved for the process to exit
+	// after the shutdown.
+	Margin time.Duration
+}
+
+// DefaultConfig returns Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		GracePeriod:  DefaultGracePeriod,
+		PreStopDelay: DefaultPreStopDelay,
+		Margin:       DefaultMargin,
+	}
+}
+
+// FromEnv returns Config with GracePeriod and PreStopDelay read from
+// EnvGracePeriod and EnvPreStopDelay. Unset variables are replaced with
+// default values.
+func FromEnv() (Config, error) {
+	c := DefaultConfig()
+
+	if err := lookupSeconds(EnvGracePeriod, &c.GracePeriod); err != nil {
+		return c, err
+	}
+
+	if err := lookupSeconds(EnvPreStopDelay, &c.PreStopDelay); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// lookupSeconds sets d to the number of seconds from environment variable key
+// if it is set.
+func lookupSeconds(key string, d *time.Duration) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("%s: invalid number of seconds %q", key, v)
+	}
+
+	*d = time.Duration(seconds * float64(time.Second))
+
+	return nil
+}
+
+// RegisterFlags registers flags for c's fields in fs using c's current
+// values as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.GracePeriod, "termination-grace-period", c.GracePeriod, "pod's termination grace period")
+	fs.DurationVar(&c.PreStopDelay, "pre-stop-delay", c.PreStopDelay, "delay before the shutdown to let endpoints deprogram")
+	fs.DurationVar(&c.Margin, "termination-margin", c.Margin, "part of the grace period reserved for the process exit")
+}
+
+// Budget returns the time available for the shutdown after the preStop delay.
+// It is never negative.
+func (c Config) Budget() time.Duration {
+	budget := c.GracePeriod - c.PreStopDelay - c.Margin
+	if budget < 0 {
+		return 0
+	}
+
+	return budget
+}
+
+// Terminate waits for c's PreStopDelay and then shuts down bg with the rest
+// of c's GracePeriod minus c's Margin as the budget. It is supposed to be
+// called right after receiving the termination signal.
+//
+// The ctx cuts the preStop delay short: if ctx is done during the delay,
+// the shutdown starts immediately. The shutdown's reason is ErrTerminated.
+func Terminate(ctx context.Context, bg background.Background, c Config) error {
+	deadline := time.Now().Add(c.GracePeriod - c.Margin)
+
+	delay := time.NewTimer(c.PreStopDelay)
+	defer delay.Stop()
+
+	select {
+	case <-delay.C:
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	return bg.Shutdown(background.WithShutdownReason(ctx, ErrTerminated))
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+const failTimeout = 100 * time.Millisecond
+
+func TestFromEnv(t *testing.T) {
+	os.Setenv(EnvGracePeriod, "60")
+	os.Setenv(EnvPreStopDelay, "2.5")
+
+	defer os.Unsetenv(EnvGracePeriod)
+	defer os.Unsetenv(EnvPreStopDelay)
+
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{GracePeriod: time.Minute, PreStopDelay: 2500 * time.Millisecond, Margin: DefaultMargin}
+	if c != want {
+		t.Errorf("wrong config, want %+v, have %+v", want, c)
+	}
+
+	if budget := c.Budget(); budget != 56500*time.Millisecond {
+		t.Errorf("wrong budget, want 56.5s, have %v", budget)
+	}
+
+	os.Setenv(EnvGracePeriod, "-1")
+
+	if _, err := FromEnv(); err == nil {
+		t.Errorf("expected error for negative grace period")
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	c := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+
+	if err := fs.Parse([]string{"-pre-stop-delay=1s", "-termination-grace-period=10s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.PreStopDelay != time.Second || c.GracePeriod != 10*time.Second || c.Margin != DefaultMargin {
+		t.Errorf("wrong config after parsing flags: %+v", c)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	bg, tail := background.WithShutdown()
+
+	var (
+		started = time.Now()
+		closed  = make(chan time.Time, 1)
+		reason  = make(chan error, 1)
+	)
+
+	go func() {
+		<-tail.End()
+		closed <- time.Now()
+		reason <- tail.CloseInfo().Reason
+		tail.Done()
+	}()
+
+	c := Config{GracePeriod: time.Second, PreStopDelay: 20 * time.Millisecond}
+
+	if err := Terminate(context.Background(), bg, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if delay := (<-closed).Sub(started); delay < c.PreStopDelay {
+		t.Errorf("shutdown started before the preStop delay: %v", delay)
+	}
+
+	if err := <-reason; !errors.Is(err, ErrTerminated) {
+		t.Errorf("wrong shutdown reason, want '%v', have '%v'", ErrTerminated, err)
+	}
+}
+
+func TestTerminateBudget(t *testing.T) {
+	bg, _ := background.WithShutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := Config{GracePeriod: 20 * time.Millisecond, PreStopDelay: time.Hour}
+
+	done := make(chan error, 1)
+	go func() { done <- Terminate(ctx, bg, c) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, background.ErrTimeout) {
+			t.Errorf("want ErrTimeout, have '%v'", err)
+		}
+	case <-time.After(failTimeout):
+		t.Errorf("Terminate didn't respect the grace period")
+	}
+}
@@ -0,0 +1,123 @@
+package background
+
+import (
+	"fmt"
+	"sync"
+)
+
+// KeyedErrTail detaches after keyed error group Background initialization.
+// The tail is supposed to be shared by a pool of similar background jobs,
+// each of which assigns errors to its own named slot.
+type KeyedErrTail interface {
+	// ErrorFor assigns err to the slot name of associated background.
+	// If the slot already has an error or err is nil - does nothing.
+	ErrorFor(name string, err error)
+}
+
+type keyedErrorBackground struct {
+	*group
+
+	// names are the names of the failed slots in the order of failures.
+	names []string
+	errs  map[string]error
+
+	sync.RWMutex
+}
+
+// WithKeyedErrorGroup returns new background with merged children that can
+// store an error per named slot, so a pool of identical workers doesn't
+// collapse into a single anonymous error:
+//
+//	bg, tail := background.WithKeyedErrorGroup()
+//
+//	for i := 0; i < n; i++ {
+//		name := fmt.Sprintf("consumer-%d", i)
+//		go func() { tail.ErrorFor(name, consume()) }()
+//	}
+//
+// The Background's Err returns MergedErrors with the errors of all failed
+// slots annotated with their names in the order of failures, or the first
+// encountered error in Background's children if no slots failed. The error
+// of a single slot is returned by ErrFor.
+func WithKeyedErrorGroup(children ...Background) (Background, KeyedErrTail) {
+	k := &keyedErrorBackground{
+		group: merge(children...),
+		errs:  make(map[string]error),
+	}
+
+	return k, k
+}
+
+// ErrorFor assigns err to the slot name.
+//
+// If the slot already has an error - does nothing, but passes err
+// to Options' OnDroppedError of the package defaults, if set.
+//
+// If capturing stack traces is enabled with SetStackTraces, err is wrapped
+// in StackError.
+func (k *keyedErrorBackground) ErrorFor(name string, err error) {
+	if err == nil {
+		return
+	}
+
+	if stack := callers(1); stack != nil {
+		err = &StackError{Err: err, Stack: stack}
+	}
+
+	k.Lock()
+	_, failed := k.errs[name]
+	if !failed {
+		k.names = append(k.names, name)
+		k.errs[name] = err
+	}
+	k.Unlock()
+
+	if failed {
+		dropped(err)
+		return
+	}
+
+	errorsChanged()
+}
+
+// errFor returns the error of the slot name or nil.
+func (k *keyedErrorBackground) errFor(name string) error {
+	k.RLock()
+	defer k.RUnlock()
+
+	return k.errs[name]
+}
+
+// Err returns MergedErrors with the errors of the failed slots, otherwise
+// it returns the first encountered error in Background's children.
+func (k *keyedErrorBackground) Err() error {
+	k.RLock()
+	errs := make([]error, 0, len(k.names))
+	for _, name := range k.names {
+		errs = append(errs, fmt.Errorf("%s: %w", name, k.errs[name]))
+	}
+	k.RUnlock()
+
+	if len(errs) > 0 {
+		return &MergedErrors{Errs: errs}
+	}
+
+	return k.group.Err()
+}
+
+func (k *keyedErrorBackground) DependsOn(children ...Background) Background {
+	return withDependency(k, children...)
+}
+
+// ErrFor returns the error assigned to the slot name of the first keyed error
+// group in bg's tree that has one, see WithKeyedErrorGroup, or nil.
+// The tree is searched the same way as in Background.Value.
+func ErrFor(bg Background, name string) (err error) {
+	walk(bg, func(bg Background) {
+		if k, ok := bg.(*keyedErrorBackground); ok && err == nil {
+			err = k.errFor(name)
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,35 @@
+package background
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// LabelComponent is the pprof label key set on goroutines started by
+// the package's job helpers, such as WithTrigger or WithWarmup. Its value
+// is the function, file and line of the helper's caller, the same as
+// in WithCallerAnnotation, so CPU and goroutine profiles can be attributed
+// to the components of the tree:
+//
+//	go tool pprof -tagfocus component=newCache cpu.pprof
+//
+// Goroutines started by the labeled ones inherit the label.
+// Annotations are not used as the label, as the goroutines are started
+// before the Background is annotated by its parents.
+const LabelComponent = "component"
+
+// goLabeled calls f in a new goroutine labeled with component.
+func goLabeled(component string, f func()) {
+	go pprof.Do(context.Background(), pprof.Labels(LabelComponent, component), func(context.Context) {
+		f()
+	})
+}
+
+// GoLabeled calls f in a new goroutine labeled the same way as the goroutines
+// of the package's job helpers, see LabelComponent, so the helpers built
+// on top of the package in other packages are attributed the same way.
+// The label's value describes the caller skip frames above GoLabeled's
+// caller: skip 1 describes the caller of the helper that calls GoLabeled.
+func GoLabeled(skip int, f func()) {
+	goLabeled(caller(skip+2), f)
+}
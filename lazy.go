@@ -0,0 +1,256 @@
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+type lazyBackground struct {
+	*group
+
+	mk func() (Background, error)
+
+	// startMu serializes the start with the closing, so the component
+	// started during the closing is never left running.
+	startMu    sync.Mutex
+	attempted  bool
+	closeBegan bool
+
+	// started is the constructed component, err is the construction error.
+	started Background
+	err     error
+
+	readyOut readySignal
+	finished chan struct{}
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
+
+	mu sync.RWMutex
+}
+
+// LazyTail detaches after lazy Background initialization.
+// It is supposed to be used by the code that needs the lazy component.
+type LazyTail interface {
+	// Start constructs the component if it isn't constructed yet and returns
+	// the construction error. Start fails with ErrClosing if the closing
+	// of the Background began before the component was constructed.
+	Start() error
+}
+
+// Lazy returns new Background, which component is constructed with mk
+// only on first use: on the LazyTail's Start call or on the first call
+// of the Background's Ready. It suits expensive optional subsystems:
+//
+//	bg, search := background.Lazy(newSearchIndex)
+//
+//	http.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+//		if err := search.Start(); err != nil {
+//			...
+//		}
+//	})
+//
+// The constructed component becomes the child of the returned Background:
+// it is closed with it and takes part in its Err, Value, Wait, Reload,
+// Pause and Resume calls.
+// The values of the component are not found before it is constructed,
+// as the lookups, which the package does on its own as well, e.g. for
+// the clock, don't construct it.
+// If mk fails, its error is returned by the Background's Err and
+// the Background never becomes ready. The component that isn't started
+// doesn't hold the readiness of the Background's parents. The Background
+// that was never started is closed right away and isn't started after that.
+func Lazy(mk func() (Background, error)) (Background, LazyTail) {
+	l := &lazyBackground{
+		group:    merge(),
+		mk:       mk,
+		finished: make(chan struct{}),
+	}
+
+	return l, l
+}
+
+func (l *lazyBackground) Start() error {
+	l.startMu.Lock()
+	defer l.startMu.Unlock()
+
+	if !l.attempted {
+		if l.closeBegan {
+			return ErrClosing
+		}
+
+		l.attempted = true
+		l.construct()
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.err
+}
+
+// construct constructs the component with mk and records the result.
+func (l *lazyBackground) construct() {
+	bg, err := l.mk()
+	if err == nil && bg == nil {
+		bg = Empty()
+	}
+
+	l.mu.Lock()
+	if err != nil {
+		l.err = err
+	} else {
+		l.started = bg
+	}
+	l.mu.Unlock()
+
+	if err != nil {
+		errorsChanged()
+		return
+	}
+
+	bg.onReady(l.readyOut.fire)
+}
+
+// startedBackground returns the constructed component or nil.
+func (l *lazyBackground) startedBackground() Background {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.started
+}
+
+func (l *lazyBackground) Ready() <-chan struct{} {
+	_ = l.Start()
+	return l.readyOut.channel()
+}
+
+// onReady doesn't start the component: the component that isn't started
+// doesn't hold its parents' readiness.
+func (l *lazyBackground) onReady(f func()) {
+	l.startMu.Lock()
+	attempted := l.attempted
+	l.startMu.Unlock()
+
+	if !attempted {
+		f()
+		return
+	}
+
+	l.readyOut.subscribe(f)
+}
+
+func (l *lazyBackground) Value(key interface{}) interface{} {
+	if bg := l.startedBackground(); bg != nil {
+		return bg.Value(key)
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) Err() error {
+	l.mu.RLock()
+	err, bg := l.err, l.started
+	l.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if bg != nil {
+		return bg.Err()
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) Wait() {
+	if bg := l.startedBackground(); bg != nil {
+		bg.Wait()
+	}
+}
+
+func (l *lazyBackground) WaitContext(ctx context.Context) error {
+	if bg := l.startedBackground(); bg != nil {
+		return bg.WaitContext(ctx)
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) Reload(ctx context.Context) error {
+	if bg := l.startedBackground(); bg != nil {
+		return bg.Reload(ctx)
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) Pause() {
+	if bg := l.startedBackground(); bg != nil {
+		bg.Pause()
+	}
+}
+
+func (l *lazyBackground) Resume() {
+	if bg := l.startedBackground(); bg != nil {
+		bg.Resume()
+	}
+}
+
+func (l *lazyBackground) Shutdown(ctx context.Context) error {
+	return l.result.do(ctx, l, func() error { return shutdown(ctx, l) })
+}
+
+func (l *lazyBackground) close(ctx context.Context) {
+	l.closeOnce.Do(func() {
+		l.prepare()
+
+		l.startMu.Lock()
+		l.closeBegan = true
+		l.startMu.Unlock()
+
+		if bg := l.startedBackground(); bg != nil {
+			bg.close(ctx)
+			if !awaitFinish(ctx, l, bg) {
+				return
+			}
+		}
+
+		l.group.close(ctx)
+		close(l.finished)
+	})
+}
+
+func (l *lazyBackground) Finished() <-chan struct{} {
+	return l.finished
+}
+
+func (l *lazyBackground) IsFinished() bool {
+	return isClosed(l.finished)
+}
+
+func (l *lazyBackground) finishSig() <-chan struct{} {
+	return l.finished
+}
+
+func (l *lazyBackground) cause(ctx context.Context) error {
+	if bg := l.startedBackground(); bg != nil {
+		return bg.cause(ctx)
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) branches() []Background {
+	if bg := l.startedBackground(); bg != nil {
+		return []Background{bg}
+	}
+
+	return nil
+}
+
+func (l *lazyBackground) DependsOn(children ...Background) Background {
+	return withDependency(l, children...)
+}
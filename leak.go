@@ -0,0 +1,97 @@
+package background
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Leak describes a shutdown Background that was never shut down.
+type Leak struct {
+	// Stack is the stack trace of the Background's creation.
+	Stack string
+}
+
+type leakTracker struct {
+	backgrounds []*shutdownBackground
+	stacks      []string
+
+	sync.Mutex
+}
+
+var (
+	// trackersCount is the number of active trackers. It is accessed
+	// atomically to avoid locking when no trackers are active.
+	trackersCount int32
+
+	trackers   = make(map[*leakTracker]struct{})
+	trackersMu sync.Mutex
+)
+
+// TrackLeaks starts recording shutdown Backgrounds created with WithShutdown
+// and returns a function that stops the recording and returns Leaks of all
+// recorded Backgrounds that were never shut down.
+//
+// TrackLeaks is intended for tests. Recording captures stack trace of every
+// created shutdown Background, which is expensive. As all Backgrounds
+// created during the recording are tracked, tests using TrackLeaks should not
+// run in parallel with other tests creating Backgrounds.
+func TrackLeaks() (stop func() []Leak) {
+	t := &leakTracker{}
+
+	trackersMu.Lock()
+	trackers[t] = struct{}{}
+	atomic.AddInt32(&trackersCount, 1)
+	trackersMu.Unlock()
+
+	return func() []Leak {
+		trackersMu.Lock()
+		if _, ok := trackers[t]; ok {
+			delete(trackers, t)
+			atomic.AddInt32(&trackersCount, -1)
+		}
+		trackersMu.Unlock()
+
+		return t.leaks()
+	}
+}
+
+// trackShutdown records s in all active trackers.
+func trackShutdown(s *shutdownBackground) {
+	if atomic.LoadInt32(&trackersCount) == 0 {
+		return
+	}
+
+	stack := string(debug.Stack())
+
+	trackersMu.Lock()
+	defer trackersMu.Unlock()
+
+	for t := range trackers {
+		t.Lock()
+		t.backgrounds = append(t.backgrounds, s)
+		t.stacks = append(t.stacks, stack)
+		t.Unlock()
+	}
+}
+
+func (t *leakTracker) leaks() (leaks []Leak) {
+	t.Lock()
+	defer t.Unlock()
+
+	for i, s := range t.backgrounds {
+		if atomic.LoadInt32(&s.relinquished) == 1 {
+			// the tail was relinquished, no job is associated with it
+			continue
+		}
+
+		select {
+		case <-s.end:
+			// shut down
+		default:
+			leaks = append(leaks, Leak{Stack: t.stacks[i]})
+		}
+	}
+
+	return leaks
+}
@@ -0,0 +1,85 @@
+package background
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// NodeID identifies a Background in a tree. IDs are assigned when
+// Backgrounds are created and never change, so they stay valid during
+// the whole lifetime of the tree. Zero NodeID is never assigned.
+type NodeID uint64
+
+// lastID is the last assigned NodeID. It is accessed atomically.
+var lastID uint64
+
+// nextID returns new unique NodeID.
+func nextID() NodeID {
+	return NodeID(atomic.AddUint64(&lastID, 1))
+}
+
+// identifier is implemented by Backgrounds that have NodeID.
+type identifier interface {
+	nodeID() NodeID
+}
+
+func (g *group) nodeID() NodeID            { return g.id }
+func (d *dependBackground) nodeID() NodeID { return d.id }
+
+// ID returns bg's NodeID or zero if bg has none, e.g. if it's Empty.
+func ID(bg Background) NodeID {
+	if i, ok := bg.(identifier); ok {
+		return i.nodeID()
+	}
+
+	return 0
+}
+
+// Node is a handle to a Background found in a tree with FindByID
+// or FindByName, e.g. for admin APIs that operate on individual components.
+type Node struct {
+	// Background is the found Background. Shutting it down shuts down
+	// only its subtree, see Subtree.
+	Background Background
+
+	ID NodeID
+
+	// Name is the annotations on the path to the Background joined with ": ",
+	// the same way as in NodeSnapshot.
+	Name string
+}
+
+// Snapshot returns the state of the node's subtree, see Snapshot.
+func (n Node) Snapshot() TreeSnapshot {
+	return Snapshot(n.Background)
+}
+
+// FindByID returns the Background with id in bg's tree.
+// The second result is false if there is no such Background.
+func FindByID(bg Background, id NodeID) (Node, bool) {
+	return find(bg, func(bg Background, _ string) bool { return id != 0 && ID(bg) == id })
+}
+
+// FindByName returns the first Background in bg's tree with name:
+// the annotations on the path to it joined with ": ", e.g. "api: db".
+// The tree is searched the same way as in Background.Value, so the
+// annotated Background itself is found rather than its children.
+// The second result is false if there is no such Background.
+func FindByName(bg Background, name string) (Node, bool) {
+	return find(bg, func(_ Background, path string) bool { return path == name })
+}
+
+// find returns the first Background in bg's tree matching match.
+func find(bg Background, match func(bg Background, name string) bool) (node Node, ok bool) {
+	walkPath(bg, func(bg Background, path []string) {
+		if ok {
+			return
+		}
+
+		if name := strings.Join(path, ": "); match(bg, name) {
+			node, ok = Node{Background: bg, ID: ID(bg), Name: name}, true
+		}
+	})
+
+	return node, ok
+}
@@ -0,0 +1,131 @@
+package background
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures the behavior of Backgrounds that is otherwise
+// set by package defaults.
+type Options struct {
+	// ShutdownTimeout is the timeout of Shutdown calls with ctx without
+	// deadline. Zero means no timeout.
+	ShutdownTimeout time.Duration
+
+	// CloseLimit is the maximum number of children closed simultaneously
+	// by Backgrounds merged without explicit limit, see MergeWithLimit.
+	// Zero means no limit.
+	CloseLimit int
+
+	// Strict enables strict mode in addition to the background_strict build
+	// tag, see strict.go. Per-tree Strict affects only shutdown timeout errors,
+	// other checks are enabled by the package defaults.
+	Strict bool
+
+	// Abandon enables giving up waiting for children that haven't finished
+	// closing once the ctx of the shutdown is done, so no goroutines are
+	// left waiting for stuck children. Backgrounds that gave up never finish
+	// and are reported as abandoned by Snapshot.
+	Abandon bool
+
+	// ReportStartup enables reporting the shutdowns of trees that were not
+	// ready yet when the shutdown began, e.g. when SIGTERM is received during
+	// the startup: such Shutdown calls return ErrShutdownDuringStartup
+	// instead of nil, and their TimeoutErrors match it as well.
+	// The shutdown itself is the same regardless of ReportStartup: see
+	// CloseInfo's DuringStartup.
+	ReportStartup bool
+
+	// DumpGoroutines enables attaching goroutine stack traces to TimeoutError
+	// returned by Shutdown, see TimeoutError's Goroutines.
+	DumpGoroutines bool
+
+	// StackTraces enables capturing stack traces, see SetStackTraces.
+	// It is used only in the package defaults.
+	StackTraces bool
+
+	// OnDroppedError is called with errors passed to ErrTail's Error
+	// and Errorf that are dropped as the Background already has an error,
+	// e.g. to log them. It must not block.
+	// It is used only in the package defaults.
+	OnDroppedError func(err error)
+
+	// OnDroppedTail is called in strict mode with an error matching
+	// ErrTailDropped when a shutdown Background is garbage collected while
+	// its ShutdownTail's End was never called, e.g. to log it. It is called
+	// on the finalizer goroutine and must not block.
+	// It is used only in the package defaults.
+	OnDroppedTail func(err error)
+}
+
+// defaults stores package default Options.
+var defaults atomic.Value
+
+// SetDefaults sets package default Options used by all Backgrounds that
+// are not overridden with WithOptions. It is supposed to be called at
+// the start of the program.
+func SetDefaults(o Options) {
+	defaults.Store(o)
+	SetStackTraces(o.StackTraces)
+}
+
+// Defaults returns package default Options.
+func Defaults() Options {
+	o, _ := defaults.Load().(Options)
+	return o
+}
+
+// optionsKey is a private context key for Options.
+type optionsKey struct{}
+
+// optionsFrom returns Options carried by the shutdown context ctx
+// or package defaults.
+func optionsFrom(ctx context.Context) Options {
+	if o, ok := ctx.Value(optionsKey{}).(Options); ok {
+		return o
+	}
+
+	return Defaults()
+}
+
+type optionsBackground struct {
+	*group
+
+	options Options
+}
+
+// WithOptions returns new Background with merged children and Options o
+// assigned to it, overriding package defaults for the whole tree during
+// the shutdown.
+func WithOptions(o Options, children ...Background) Background {
+	return withOptions(o, children...)
+}
+
+func withOptions(o Options, children ...Background) *optionsBackground {
+	return &optionsBackground{
+		group:   merge(children...),
+		options: o,
+	}
+}
+
+// withContext returns a copy of ctx that carries o's Options.
+func (o *optionsBackground) withContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, optionsKey{}, o.options)
+}
+
+func (o *optionsBackground) Shutdown(ctx context.Context) error {
+	return o.result.do(ctx, o, func() error { return shutdown(o.withContext(ctx), o) })
+}
+
+func (o *optionsBackground) DependsOn(children ...Background) Background {
+	return withDependency(o, children...)
+}
+
+func (o *optionsBackground) close(ctx context.Context) {
+	o.group.close(o.withContext(ctx))
+}
+
+func (o *optionsBackground) cause(ctx context.Context) error {
+	return o.group.cause(o.withContext(ctx))
+}
@@ -0,0 +1,100 @@
+package background
+
+import (
+	"sync"
+)
+
+type pauseBackground struct {
+	*group
+
+	paused  chan struct{}
+	resumed chan struct{}
+
+	sync.Mutex
+}
+
+// PauseTail detaches after pausable Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background as it carries pause and resume signals.
+type PauseTail interface {
+	// Paused returns a channel that's closed when work done on behalf
+	// of tail's Background should be paused.
+	// After the Background is resumed, Paused returns a new channel.
+	Paused() <-chan struct{}
+
+	// Resumed returns a channel that's closed when work done on behalf
+	// of tail's Background should be resumed.
+	// After the Background is paused, Resumed returns a new channel.
+	Resumed() <-chan struct{}
+}
+
+// WithPause returns a new pausable Background with merged children.
+//
+// The returned PauseTail's Paused channel is closed when Background's Pause
+// method is called or by its parent during the pause, and the Resumed channel
+// is closed when Background's Resume method is called.
+//
+// The Background is initialized as resumed.
+func WithPause(children ...Background) (Background, PauseTail) {
+	p := withPause(children...)
+	return p, p
+}
+
+func withPause(children ...Background) *pauseBackground {
+	return &pauseBackground{
+		group:   merge(children...),
+		paused:  make(chan struct{}),
+		resumed: closedchan,
+	}
+}
+
+func (p *pauseBackground) Paused() <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.paused
+}
+
+func (p *pauseBackground) Resumed() <-chan struct{} {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.resumed
+}
+
+// Pause pauses the Background's children first and then pauses itself.
+func (p *pauseBackground) Pause() {
+	p.group.Pause()
+
+	p.Lock()
+	defer p.Unlock()
+
+	select {
+	case <-p.paused:
+		// Already paused
+	default:
+		close(p.paused)
+		p.resumed = make(chan struct{})
+	}
+}
+
+// Resume resumes the Background first and then resumes its children.
+func (p *pauseBackground) Resume() {
+	p.Lock()
+
+	select {
+	case <-p.resumed:
+		// Already resumed
+	default:
+		close(p.resumed)
+		p.paused = make(chan struct{})
+	}
+
+	p.Unlock()
+
+	p.group.Resume()
+}
+
+func (p *pauseBackground) DependsOn(children ...Background) Background {
+	return withDependency(p, children...)
+}
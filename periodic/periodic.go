@@ -0,0 +1,228 @@
+// Package periodic provides periodic background jobs integrated with
+// package background.
+//
+// A periodic job runs a function every interval until the Background is shut
+// down. The schedule can be randomized with jitter, so jobs in a fleet of
+// instances don't run in lockstep, and the number of simultaneous runs can be
+// limited with a policy for the ticks that find all run slots busy:
+//
+//	bg := periodic.New(time.Minute, sync,
+//		periodic.WithJitter(0.2),
+//		periodic.WithMaxConcurrent(1),
+//	)
+package periodic
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+// Func is a function run by a periodic job. Its ctx is canceled when the ctx
+// of the Background's shutdown is done.
+type Func func(ctx context.Context) error
+
+// Policy defines what happens on a tick when all run slots are busy.
+type Policy int
+
+const (
+	// Skip skips the tick. It is the default policy.
+	Skip Policy = iota
+	// Queue waits for a free run slot. Ticks that happen during the waiting
+	// are coalesced into the queued run.
+	Queue
+)
+
+type config struct {
+	jitter        float64
+	maxConcurrent int
+	policy        Policy
+	clock         background.Clock
+}
+
+// Option configures a periodic job.
+type Option func(*config)
+
+// WithJitter randomizes every interval by up to fraction of it in both
+// directions, e.g. with fraction 0.2 a one minute interval lasts from 48
+// to 72 seconds. The fraction is clamped to [0, 1].
+func WithJitter(fraction float64) Option {
+	return func(c *config) {
+		switch {
+		case fraction < 0:
+			fraction = 0
+		case fraction > 1:
+			fraction = 1
+		}
+
+		c.jitter = fraction
+	}
+}
+
+// WithMaxConcurrent limits the number of simultaneous runs to n. The default
+// limit is 1. If n is less than 1, the number of runs is not limited.
+func WithMaxConcurrent(n int) Option {
+	return func(c *config) {
+		c.maxConcurrent = n
+	}
+}
+
+// WithPolicy sets the policy for ticks that find all run slots busy.
+func WithPolicy(p Policy) Option {
+	return func(c *config) {
+		c.policy = p
+	}
+}
+
+// WithClock sets the clock used for scheduling, the system clock is used
+// by default.
+func WithClock(clock background.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+type job struct {
+	config
+
+	interval time.Duration
+	fn       Func
+	tail     background.ShutdownTail
+	errs     background.ErrTail
+
+	// slots holds a value for every run in progress, nil if the number of runs
+	// is not limited.
+	slots chan struct{}
+
+	runs sync.WaitGroup
+}
+
+// New returns new Background running fn every interval until the Background
+// is shut down. The first run happens after the first
+// interval. The shutdown waits for the runs in progress.
+//
+// The first error returned by fn is returned by the Background's Err,
+// the job keeps running after errors.
+func New(interval time.Duration, fn Func, opts ...Option) background.Background {
+	j := &job{
+		config: config{
+			maxConcurrent: 1,
+			clock:         background.SystemClock(),
+		},
+		interval: interval,
+		fn:       fn,
+	}
+
+	for _, opt := range opts {
+		opt(&j.config)
+	}
+
+	if j.maxConcurrent > 0 {
+		j.slots = make(chan struct{}, j.maxConcurrent)
+	}
+
+	errBg, errs := background.WithErrorGroup()
+	bg, tail := background.WithShutdown(errBg)
+
+	j.tail, j.errs = tail, errs
+
+	background.GoLabeled(1, j.schedule)
+
+	return bg
+}
+
+func (j *job) schedule() {
+	defer j.tail.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		timer := j.clock.NewTimer(j.next())
+
+		select {
+		case <-timer.C():
+		case <-j.tail.End():
+			timer.Stop()
+			j.drain(cancel)
+
+			return
+		}
+
+		if !j.acquire() {
+			continue
+		}
+
+		j.runs.Add(1)
+
+		go func() {
+			defer j.runs.Done()
+			defer j.release()
+
+			if err := j.fn(ctx); err != nil {
+				j.errs.Error(err)
+			}
+		}()
+	}
+}
+
+// next returns the next interval with applied jitter.
+func (j *job) next() time.Duration {
+	if j.jitter == 0 {
+		return j.interval
+	}
+
+	delta := (rand.Float64()*2 - 1) * j.jitter * float64(j.interval)
+
+	return j.interval + time.Duration(delta)
+}
+
+// acquire takes a run slot according to the policy. It returns false if
+// the tick is skipped or the job is shut down while waiting for the slot.
+func (j *job) acquire() bool {
+	if j.slots == nil {
+		return true
+	}
+
+	if j.policy == Skip {
+		select {
+		case j.slots <- struct{}{}:
+			return true
+		default:
+			return false
+		}
+	}
+
+	select {
+	case j.slots <- struct{}{}:
+		return true
+	case <-j.tail.End():
+		return false
+	}
+}
+
+func (j *job) release() {
+	if j.slots != nil {
+		<-j.slots
+	}
+}
+
+// drain waits for the runs in progress, canceling their context once
+// the context of the shutdown is done.
+func (j *job) drain(cancel context.CancelFunc) {
+	finished := make(chan struct{})
+
+	go func() {
+		select {
+		case <-j.tail.Context().Done():
+			cancel()
+		case <-finished:
+		}
+	}()
+
+	j.runs.Wait()
+	close(finished)
+}
@@ -0,0 +1,181 @@
+package periodic
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	failTimeout = 100 * time.Millisecond
+
+	interval = time.Millisecond
+)
+
+func TestRun(t *testing.T) {
+	var runs int32
+
+	bg := New(interval, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+
+	time.Sleep(failTimeout / 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	after := atomic.LoadInt32(&runs)
+	if after < 2 {
+		t.Errorf("want at least 2 runs, have %d", after)
+	}
+
+	time.Sleep(10 * interval)
+
+	if have := atomic.LoadInt32(&runs); have != after {
+		t.Errorf("job kept running after shutdown")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	var (
+		runs    int32
+		release = make(chan struct{})
+	)
+
+	bg := New(interval, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return nil
+	})
+
+	time.Sleep(10 * interval)
+
+	if have := atomic.LoadInt32(&runs); have != 1 {
+		t.Errorf("want 1 run while the slot is busy, have %d", have)
+	}
+
+	close(release)
+	time.Sleep(10 * interval)
+
+	if have := atomic.LoadInt32(&runs); have < 2 {
+		t.Errorf("job didn't run after the slot was freed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var (
+		running int32
+		overlap int32
+		runs    int32
+	)
+
+	bg := New(interval, func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 2 {
+			atomic.StoreInt32(&overlap, 1)
+		}
+
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(3 * interval)
+		atomic.AddInt32(&running, -1)
+
+		return nil
+	}, WithMaxConcurrent(2), WithPolicy(Queue))
+
+	time.Sleep(failTimeout / 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&overlap) != 0 {
+		t.Errorf("more than 2 simultaneous runs")
+	}
+
+	if atomic.LoadInt32(&runs) < 2 {
+		t.Errorf("queued runs didn't happen")
+	}
+}
+
+func TestErr(t *testing.T) {
+	errTest := errors.New("test")
+
+	bg := New(interval, func(ctx context.Context) error {
+		return errTest
+	})
+
+	time.Sleep(10 * interval)
+
+	if err := bg.Err(); err != errTest {
+		t.Errorf("want error '%v', have '%v'", errTest, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownCancelsRuns(t *testing.T) {
+	var (
+		started  = make(chan struct{}, 1)
+		canceled = make(chan struct{}, 1)
+	)
+
+	bg := New(interval, func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		canceled <- struct{}{}
+
+		return nil
+	})
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*interval)
+	defer cancel()
+
+	// the run stops only when ctx is done, so the shutdown times out
+	_ = bg.Shutdown(ctx)
+
+	select {
+	case <-canceled:
+	case <-time.After(failTimeout):
+		t.Errorf("run's context wasn't canceled")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	j := &job{config: config{}, interval: time.Second}
+	WithJitter(0.2)(&j.config)
+
+	for i := 0; i < 100; i++ {
+		if d := j.next(); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("interval %v is out of jitter bounds", d)
+		}
+	}
+
+	WithJitter(2)(&j.config)
+
+	if j.jitter != 1 {
+		t.Errorf("jitter is not clamped, have %v", j.jitter)
+	}
+}
@@ -0,0 +1,86 @@
+package background
+
+import (
+	"context"
+	"sort"
+)
+
+type phaseBackground struct {
+	*group
+
+	phase int
+}
+
+// WithPhase returns new Background with merged children assigned to
+// the shutdown phase. When a tree is shut down, the Backgrounds assigned
+// to phases are closed before the rest of the tree, phase by phase in
+// ascending order, regardless of where they are in the tree: all
+// Backgrounds of a phase are closed simultaneously, and the next phase
+// begins when all of them are closed.
+//
+// Phases express broad ordering, e.g. ingress, then workers, then stores,
+// without chaining every pair of components with DependsOn. Phases take
+// precedence over the ordering of DependsOn and ordered Merge functions.
+// Declare the phases as constants:
+//
+//	const (
+//		PhaseIngress = iota
+//		PhaseWorkers
+//		PhaseStores
+//	)
+func WithPhase(phase int, children ...Background) Background {
+	return withPhase(phase, children...)
+}
+
+func withPhase(phase int, children ...Background) *phaseBackground {
+	return &phaseBackground{
+		group: merge(children...),
+		phase: phase,
+	}
+}
+
+func (p *phaseBackground) DependsOn(children ...Background) Background {
+	return withDependency(p, children...)
+}
+
+// closePhases closes the Backgrounds assigned to phases in bg's tree
+// phase by phase and waits for them. If Options' Abandon is enabled,
+// it gives up once ctx is done.
+func closePhases(ctx context.Context, bg Background) {
+	phases := make(map[int][]*phaseBackground)
+
+	walk(bg, func(bg Background) {
+		if p, ok := bg.(*phaseBackground); ok {
+			phases[p.phase] = append(phases[p.phase], p)
+		}
+	})
+
+	if len(phases) == 0 {
+		return
+	}
+
+	order := make([]int, 0, len(phases))
+	for phase := range phases {
+		order = append(order, phase)
+	}
+
+	sort.Ints(order)
+
+	for _, phase := range order {
+		for _, p := range phases[phase] {
+			go p.close(ctx)
+		}
+
+		for _, p := range phases[phase] {
+			select {
+			case <-p.finishSig():
+			case <-ctx.Done():
+				if optionsFrom(ctx).Abandon {
+					return
+				}
+
+				<-p.finishSig()
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package background
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Step is a step of the shutdown of a tree computed by Plan.
+type Step struct {
+	// Stage is the number of the step's stage, starting from 0. The steps
+	// of the same stage run simultaneously, and every step runs after
+	// the steps of the previous stages it depends on are complete.
+	Stage int
+
+	// Name is the annotations on the path to the Background joined with ": ",
+	// the same way as in NodeSnapshot, or empty if there are none.
+	Name string
+
+	// Type is the Background's implementation type.
+	Type string
+}
+
+func (s Step) String() string {
+	if s.Name == "" {
+		return fmt.Sprintf("%d: %s", s.Stage, s.Type)
+	}
+
+	return fmt.Sprintf("%d: %s %q", s.Stage, s.Type, s.Name)
+}
+
+// Plan returns the steps of the shutdown of bg ordered by their stages,
+// without closing anything, so the shutdown order can be printed at startup
+// or asserted in tests.
+//
+// The steps are the Backgrounds that act on the shutdown: the ones with
+// ShutdownTail, whose step is closing of the End channel, the hooks,
+// the shutdown delays and the adapted components. The stages follow the phases, the dependencies
+// and the order of the ordered Merge functions. The children closed
+// simultaneously start at the same stage, even if their number is limited
+// with MergeWithLimit or Options' CloseLimit.
+func Plan(bg Background) []Step {
+	p := &planner{
+		paths:   make(map[Background]string),
+		planned: make(map[Background]int),
+	}
+
+	var (
+		start  = 0
+		phases = make(map[int][]*phaseBackground)
+	)
+
+	walkPath(bg, func(bg Background, path []string) {
+		if hashable(bg) {
+			p.paths[bg] = strings.Join(path, ": ")
+		}
+
+		switch bg := bg.(type) {
+		case *hookBackground:
+			if bg.phase == hookOnLeave {
+				p.step(bg, 0)
+				start = 1
+			}
+		case *phaseBackground:
+			phases[bg.phase] = append(phases[bg.phase], bg)
+		}
+	})
+
+	order := make([]int, 0, len(phases))
+	for phase := range phases {
+		order = append(order, phase)
+	}
+
+	sort.Ints(order)
+
+	for _, phase := range order {
+		end := start - 1
+
+		for _, ph := range phases[phase] {
+			if e := p.plan(ph, start); e > end {
+				end = e
+			}
+		}
+
+		start = end + 1
+	}
+
+	p.plan(bg, start)
+
+	sort.SliceStable(p.steps, func(i, j int) bool { return p.steps[i].Stage < p.steps[j].Stage })
+
+	return p.steps
+}
+
+type planner struct {
+	steps []Step
+	paths map[Background]string
+
+	// planned holds the last stages of the planned Backgrounds.
+	planned map[Background]int
+}
+
+func (p *planner) step(bg Background, stage int) {
+	var name string
+	if hashable(bg) {
+		name = p.paths[bg]
+	}
+
+	p.steps = append(p.steps, Step{Stage: stage, Name: name, Type: fmt.Sprintf("%T", bg)})
+}
+
+// plan plans the shutdown of bg beginning at stage start and returns
+// the last stage used by bg, or start-1 if bg has no steps.
+func (p *planner) plan(bg Background, start int) int {
+	if !hashable(bg) {
+		return p.planNode(bg, start)
+	}
+
+	if end, ok := p.planned[bg]; ok {
+		return end
+	}
+
+	end := p.planNode(bg, start)
+	p.planned[bg] = end
+
+	return end
+}
+
+func (p *planner) planNode(bg Background, start int) int {
+	switch bg := bg.(type) {
+	case *group:
+		return p.planGroup(bg, start)
+	case *dependBackground:
+		return p.plan(bg.parent, p.plan(bg.children, start)+1)
+	case *hookBackground:
+		switch bg.phase {
+		case hookOnShutdown:
+			p.step(bg, start)
+
+			if end := p.planGroup(bg.group, start+1); end > start {
+				return end
+			}
+
+			return start
+		case hookOnFinished:
+			end := p.planGroup(bg.group, start) + 1
+			p.step(bg, end)
+
+			return end
+		}
+	case *delayBackground:
+		p.step(bg, start)
+
+		if end := p.planGroup(bg.group, start+1); end > start {
+			return end
+		}
+
+		return start
+	case *adapterBackground:
+		if _, ok := bg.component.(Shutdowner); ok {
+			p.step(bg, start)
+			return start
+		}
+
+		return start - 1
+	}
+
+	end := start - 1
+
+	if b, ok := brancherOf(bg); ok {
+		for _, child := range b.branches() {
+			if e := p.plan(child, start); e > end {
+				end = e
+			}
+		}
+	}
+
+	if _, ok := bg.(ShutdownTail); ok {
+		end++
+		p.step(bg, end)
+	}
+
+	return end
+}
+
+func (p *planner) planGroup(g *group, start int) int {
+	preFinished := make(map[int]struct{}, len(g.preFinished))
+	for _, i := range g.preFinished {
+		preFinished[i] = struct{}{}
+	}
+
+	end := start - 1
+
+	for j := range g.backgrounds {
+		i := j
+		if g.order == closeReverse {
+			i = len(g.backgrounds) - 1 - j
+		}
+
+		if _, ok := preFinished[i]; ok {
+			continue
+		}
+
+		if g.order == closeConcurrent {
+			if e := p.plan(g.backgrounds[i], start); e > end {
+				end = e
+			}
+
+			continue
+		}
+
+		if e := p.plan(g.backgrounds[i], end+1); e > end {
+			end = e
+		}
+	}
+
+	return end
+}
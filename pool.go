@@ -0,0 +1,106 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConnectionsAbandoned is the error returned by Background.Err of
+// the Background created with WithPoolDrain when the pool is closed with
+// connections still in use.
+var ErrConnectionsAbandoned = errors.New("connections abandoned")
+
+// poolPollInterval is the interval of checking the pool's in-use count
+// during the drain.
+const poolPollInterval = 10 * time.Millisecond
+
+// Pool is a connection pool drained by WithPoolDrain. Most drivers' pools
+// can implement it with a tiny wrapper around their stats, e.g. for redis:
+//
+//	type redisPool struct{ *redis.Client }
+//
+//	func (p redisPool) InUse() int {
+//		s := p.PoolStats()
+//		return int(s.TotalConns - s.IdleConns)
+//	}
+type Pool interface {
+	// InUse returns the number of connections currently in use.
+	InUse() int
+
+	// Close closes the pool.
+	Close() error
+}
+
+// WithPoolDrain returns new Background with merged children that closes
+// pool on shutdown after its children are shut down. Before closing, it
+// waits for the connections in use to return to the pool, but at most
+// timeout and not after the ctx of the Shutdown call is done. Zero timeout
+// means waiting until the ctx is done.
+//
+// If the pool is closed with connections in use, the Background's Err
+// returns an error wrapping ErrConnectionsAbandoned with their number.
+// An error returned from the pool's Close is returned by Err as well;
+// if both occur, Err returns MergedErrors with both of them.
+func WithPoolDrain(pool Pool, timeout time.Duration, children ...Background) Background {
+	var (
+		errs = withErrorGroup()
+		s    = withShutdown(merge(children...), errs)
+	)
+
+	goLabeled(caller(2), func() {
+		defer s.Done()
+
+		<-s.End()
+
+		ctx := s.Context()
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		var failed []error
+
+		if n := drainPool(ctx, pool); n > 0 {
+			failed = append(failed, fmt.Errorf("pool closed with %d connections in use: %w", n, ErrConnectionsAbandoned))
+		}
+
+		if err := pool.Close(); err != nil {
+			failed = append(failed, fmt.Errorf("close pool: %w", err))
+		}
+
+		switch len(failed) {
+		case 0:
+		case 1:
+			errs.Error(failed[0])
+		default:
+			errs.Error(&MergedErrors{Errs: failed})
+		}
+	})
+
+	return s
+}
+
+// drainPool waits until pool has no connections in use or ctx is done
+// and returns the number of connections left in use.
+func drainPool(ctx context.Context, pool Pool) int {
+	ticker := time.NewTicker(poolPollInterval)
+	defer ticker.Stop()
+
+	for {
+		n := pool.InUse()
+		if n == 0 {
+			return 0
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return pool.InUse()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package background
+
+import "sync"
+
+type progressBackground struct {
+	*group
+
+	done, total int64
+
+	sync.RWMutex
+}
+
+// ProgressTail detaches after progress Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background.
+type ProgressTail interface {
+	// Set sets the progress of the background job: done units of work
+	// out of total.
+	Set(done, total int64)
+}
+
+// WithProgress returns new Background with merged children that reports
+// the progress of a background job.
+//
+// The returned ProgressTail is used to set the progress, which is aggregated
+// across the tree with Progress.
+func WithProgress(children ...Background) (Background, ProgressTail) {
+	p := withProgress(children...)
+	return p, p
+}
+
+func withProgress(children ...Background) *progressBackground {
+	return &progressBackground{
+		group: merge(children...),
+	}
+}
+
+// Set sets the progress of the background job.
+func (p *progressBackground) Set(done, total int64) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.done, p.total = done, total
+}
+
+// Progress returns the sum of progresses set by all progress Backgrounds
+// in bg's tree.
+func Progress(bg Background) (done, total int64) {
+	walk(bg, func(bg Background) {
+		p, ok := bg.(*progressBackground)
+		if !ok {
+			return
+		}
+
+		p.RLock()
+		done += p.done
+		total += p.total
+		p.RUnlock()
+	})
+
+	return done, total
+}
+
+func (p *progressBackground) DependsOn(children ...Background) Background {
+	return withDependency(p, children...)
+}
@@ -2,13 +2,21 @@ package background
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 type readinessBackground struct {
 	*group
 
-	ready    chan struct{}
-	readyOut chan struct{}
+	ready chan struct{}
+
+	// okSig is fired on Ok call and readyOut is fired when both
+	// the Background and its children are ready.
+	okSig    readySignal
+	readyOut readySignal
+
+	// path is the annotation path recorded in strict mode, see strictAnnotate.
+	path string
 
 	sync.Mutex
 }
@@ -22,18 +30,52 @@ type ReadinessTail interface {
 	// the channel from Background's Ready call to block forever.
 	// After the first call, subsequent calls do nothing.
 	Ok()
+
+	// Close relinquishes the tail when the background job it was created for
+	// is not started, e.g. when its feature is disabled: the Background
+	// is considered ready, so it doesn't block its parents' readiness.
+	// Unlike Ok, it can be called after the Background was shut down.
+	Close()
 }
 
 func (r *readinessBackground) Ok() {
+	strictCheckOk(r)
+	r.ok()
+}
+
+func (r *readinessBackground) Close() {
+	r.ok()
+}
+
+func (r *readinessBackground) setStrictPath(path string) {
+	r.Lock()
+	r.path = path
+	r.Unlock()
+}
+
+func (r *readinessBackground) strictPath() string {
 	r.Lock()
 	defer r.Unlock()
 
+	return r.path
+}
+
+// ok marks the Background as ready.
+func (r *readinessBackground) ok() {
+	r.Lock()
+
 	select {
 	case <-r.ready:
 		// Already ready
+		r.Unlock()
+		return
 	default:
 		close(r.ready)
 	}
+
+	r.Unlock()
+
+	r.okSig.fire()
 }
 
 func WithReadiness(children ...Background) (Background, ReadinessTail) {
@@ -51,25 +93,124 @@ func withReadiness(children ...Background) *readinessBackground {
 }
 
 func (r *readinessBackground) Ready() <-chan struct{} {
+	r.readyOut.follow(r.group.onReady, r.okSig.subscribe)
+	return r.readyOut.channel()
+}
+
+func (r *readinessBackground) onReady(f func()) {
+	r.readyOut.follow(r.group.onReady, r.okSig.subscribe)
+	r.readyOut.subscribe(f)
+}
+
+func (r *readinessBackground) DependsOn(children ...Background) Background {
+	return withDependency(r, children...)
+}
+
+// readier is used for readiness propagation.
+type readier interface {
+	// onReady registers f to be called once the Background is ready.
+	// If the Background is already ready, f is called immediately.
+	onReady(f func())
+}
+
+// readySignal propagates readiness from children to parents with callbacks,
+// so waiting for readiness does not require any goroutines.
+//
+// The zero value is a not fired signal.
+type readySignal struct {
+	fired     bool
+	c         chan struct{}
+	callbacks []func()
+	once      sync.Once
+
+	sync.Mutex
+}
+
+// channel returns a channel that's closed when the signal is fired.
+// To avoid memory leaks - the channel is created only once.
+func (r *readySignal) channel() <-chan struct{} {
 	r.Lock()
 	defer r.Unlock()
 
-	if r.readyOut != nil {
-		// To avoid memory leaks - readyOut channel is created only once
-		return r.readyOut
+	if r.c == nil {
+		if r.fired {
+			r.c = closedchan
+		} else {
+			r.c = make(chan struct{})
+		}
 	}
 
-	r.readyOut = make(chan struct{})
+	return r.c
+}
+
+// subscribe registers f to be called once the signal is fired.
+// If the signal is already fired, f is called immediately.
+func (r *readySignal) subscribe(f func()) {
+	r.Lock()
+
+	if !r.fired {
+		r.callbacks = append(r.callbacks, f)
+		r.Unlock()
+
+		return
+	}
 
-	go func() {
-		<-r.group.Ready()
-		<-r.ready
-		close(r.readyOut)
-	}()
+	r.Unlock()
 
-	return r.readyOut
+	f()
 }
 
-func (r *readinessBackground) DependsOn(children ...Background) Background {
-	return withDependency(r, children...)
+// isFired reports whether the signal is fired.
+func (r *readySignal) isFired() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.fired
+}
+
+// fire fires the signal. After the first call, subsequent calls do nothing.
+func (r *readySignal) fire() {
+	r.Lock()
+
+	if r.fired {
+		r.Unlock()
+		return
+	}
+
+	r.fired = true
+
+	if r.c != nil {
+		close(r.c)
+	}
+
+	callbacks := r.callbacks
+	r.callbacks = nil
+
+	r.Unlock()
+
+	for _, f := range callbacks {
+		f()
+	}
+}
+
+// follow fires the signal once all of the sources are fired. Sources are
+// subscribed only on the first call, subsequent calls do nothing.
+func (r *readySignal) follow(sources ...func(f func())) {
+	r.once.Do(func() {
+		pending := int32(len(sources)) + 1
+
+		f := func() {
+			if atomic.AddInt32(&pending, -1) == 0 {
+				r.fire()
+			}
+		}
+
+		for _, subscribe := range sources {
+			subscribe(f)
+		}
+
+		// releases the extra count that guards from firing
+		// before all sources are subscribed
+		f()
+	})
 }
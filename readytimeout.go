@@ -0,0 +1,87 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotReady is the error returned by Background.Err when Background
+// created with MustBeReadyWithin isn't ready in time.
+var ErrNotReady = errors.New("not ready")
+
+type readyTimeoutBackground struct {
+	*group
+
+	timer Timer
+	err   error
+
+	sync.RWMutex
+}
+
+// MustBeReadyWithin returns new Background with merged children whose Err
+// returns error wrapping ErrNotReady if the children aren't ready within d,
+// e.g. "not ready after 30s". Annotating it makes the holdout easy to find:
+//
+//	bg := background.WithAnnotation("cache warmup", background.MustBeReadyWithin(30*time.Second, cache))
+//
+// The error stays after the children become ready. Shutting the Background
+// down stops the timer. The time is tracked by the clock assigned to children
+// with WithClock or by the system clock.
+func MustBeReadyWithin(d time.Duration, children ...Background) Background {
+	return mustBeReadyWithin(d, children...)
+}
+
+func mustBeReadyWithin(d time.Duration, children ...Background) *readyTimeoutBackground {
+	r := &readyTimeoutBackground{
+		group: merge(children...),
+	}
+
+	r.Lock()
+	r.timer = clockOf(r.group).AfterFunc(d, func() { r.expire(d) })
+	r.Unlock()
+
+	r.group.onReady(r.stop)
+
+	return r
+}
+
+func (r *readyTimeoutBackground) expire(d time.Duration) {
+	r.Lock()
+	r.err = fmt.Errorf("%w after %s", ErrNotReady, d)
+	r.Unlock()
+
+	errorsChanged()
+}
+
+func (r *readyTimeoutBackground) stop() {
+	r.Lock()
+	r.timer.Stop()
+	r.Unlock()
+}
+
+// Err returns error wrapping ErrNotReady if Background's children weren't
+// ready in time, otherwise it returns the first encountered error
+// in Background's children.
+func (r *readyTimeoutBackground) Err() error {
+	r.RLock()
+	err := r.err
+	r.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return r.group.Err()
+}
+
+func (r *readyTimeoutBackground) close(ctx context.Context) {
+	r.stop()
+	r.group.close(ctx)
+}
+
+func (r *readyTimeoutBackground) DependsOn(children ...Background) Background {
+	return withDependency(r, children...)
+}
@@ -0,0 +1,94 @@
+package background
+
+import "reflect"
+
+// typeKey is a private Value key of values provided by Provide.
+type typeKey struct {
+	reflect.Type
+}
+
+// Provide returns new Background with merged children and v registered
+// under v's dynamic type. The value can be retrieved from the tree with
+// Resolve.
+//
+// Types are unique across packages, so unlike keys chosen for WithValue they
+// can't collide by accident. To keep handles of the same underlying type
+// apart, declare a named type for each of them:
+//
+//	type Fatal chan error
+//
+//	bg := background.Provide(Fatal(fatal), serverBg)
+//
+// Provide panics if v is nil.
+func Provide(v interface{}, children ...Background) Background {
+	if v == nil {
+		panic("nil background provided value")
+	}
+
+	return withValue(typeKey{reflect.TypeOf(v)}, v, children...)
+}
+
+// Resolve finds the first value in bg's tree provided with Provide, which
+// type is the type target points to, and if one is found, sets target
+// to that value and returns true. Otherwise, it returns false.
+// The tree is searched the same way as in Background.Value.
+//
+// If target points to an interface, the first provided value implementing
+// it is found, e.g. a *sql.DB provided with Provide is resolved to both
+// *sql.DB and io.Closer targets:
+//
+//	var closer io.Closer
+//	if background.Resolve(bg, &closer) {
+//		...
+//	}
+//
+// Resolve panics if target is not a non-nil pointer.
+func Resolve(bg Background, target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if target == nil || val.Kind() != reflect.Ptr || val.IsNil() {
+		panic("background resolve target must be a non-nil pointer")
+	}
+
+	elem := val.Elem()
+
+	var value interface{}
+	if elem.Kind() == reflect.Interface {
+		value = resolveInterface(bg, elem.Type())
+	} else {
+		value = bg.Value(typeKey{elem.Type()})
+	}
+
+	if value == nil {
+		return false
+	}
+
+	elem.Set(reflect.ValueOf(value))
+
+	return true
+}
+
+// resolveInterface returns the first value in bg's tree provided with Provide
+// that implements the interface type t, or nil. The tree is searched the same
+// way as by Background.Value, so the subtrees scoped with ScopeValues
+// are not searched.
+func resolveInterface(bg Background, t reflect.Type) interface{} {
+	if _, ok := bg.(*scopeBackground); ok {
+		return nil
+	}
+
+	if v, ok := bg.(*valueBackground); ok {
+		if key, ok := v.key.(typeKey); ok && key.Type.Implements(t) {
+			return v.value
+		}
+	}
+
+	if b, ok := brancherOf(bg); ok {
+		for _, child := range b.branches() {
+			if value := resolveInterface(child, t); value != nil {
+				return value
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+type reloadBackground struct {
+	*group
+
+	reload chan struct{}
+	result chan error
+
+	sync.Mutex
+}
+
+// ReloadTail detaches after reloadable Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background as it carries reload signals.
+type ReloadTail interface {
+	// Reloads returns a channel that receives a signal each time work done
+	// on behalf of tail's Background should be reloaded.
+	// Successive calls to Reloads return the same value.
+	Reloads() <-chan struct{}
+
+	// Done sends a signal that the requested reload is complete.
+	// A non-nil err is returned from the Background's Reload call.
+	// Not calling Done will cause the Background's Reload call to return
+	// ErrTimeout or block forever.
+	Done(err error)
+}
+
+// WithReload returns a new reloadable Background that depends on children.
+//
+// The returned ReloadTail's Reloads channel receives a signal when Background's
+// Reload method is called or by its parent during the reload.
+//
+// The ReloadTail's Done call sends a signal that the reload is complete,
+// which causes Background's Reload method to return, or allow its parent
+// to reload itself.
+func WithReload(children ...Background) (Background, ReloadTail) {
+	r := withReload(children...)
+	return r, r
+}
+
+func withReload(children ...Background) *reloadBackground {
+	return &reloadBackground{
+		group:  merge(children...),
+		reload: make(chan struct{}),
+		result: make(chan error, 1),
+	}
+}
+
+func (r *reloadBackground) Reloads() <-chan struct{} {
+	return r.reload
+}
+
+func (r *reloadBackground) Done(err error) {
+	select {
+	case r.result <- err:
+	default:
+		// Reload is not requested or its result is already sent
+	}
+}
+
+// Reload reloads the Background's children first, waits until all of them
+// are successfully reloaded and then reloads itself.
+func (r *reloadBackground) Reload(ctx context.Context) error {
+	if err := r.group.Reload(ctx); err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	// drop the result of the previous reload if it was late
+	select {
+	case <-r.result:
+	default:
+	}
+
+	select {
+	case r.reload <- struct{}{}:
+	case <-ctx.Done():
+		return ErrTimeout
+	}
+
+	select {
+	case err := <-r.result:
+		return err
+	case <-ctx.Done():
+		return ErrTimeout
+	}
+}
+
+func (r *reloadBackground) DependsOn(children ...Background) Background {
+	return withDependency(r, children...)
+}
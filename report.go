@@ -0,0 +1,93 @@
+package background
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShutdownReport describes the progress of the shutdown of a tree.
+// The Backgrounds in it are the ones that act on the shutdown, the same
+// as the steps of Plan.
+type ShutdownReport struct {
+	// Completed are the Backgrounds that finished closing.
+	Completed []Node
+
+	// TimedOut are the Backgrounds that began closing, but haven't finished
+	// it, e.g. because the shutdown deadline exceeded.
+	TimedOut []Node
+
+	// NotReached are the Backgrounds that haven't begun closing, e.g. because
+	// they wait for the timed out ones in the shutdown order.
+	NotReached []Node
+}
+
+func (r ShutdownReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "completed: %d, timed out: %d, not reached: %d",
+		len(r.Completed), len(r.TimedOut), len(r.NotReached))
+
+	for _, n := range r.TimedOut {
+		fmt.Fprintf(&b, "\ntimed out: %s", nodeString(n))
+	}
+
+	for _, n := range r.NotReached {
+		fmt.Fprintf(&b, "\nnot reached: %s", nodeString(n))
+	}
+
+	return b.String()
+}
+
+// nodeString returns n's name and type.
+func nodeString(n Node) string {
+	if n.Name == "" {
+		return fmt.Sprintf("%T", n.Background)
+	}
+
+	return fmt.Sprintf("%T %q", n.Background, n.Name)
+}
+
+// Report returns the progress of the shutdown of bg's tree in the same order
+// as Background.Value searches the tree. Unlike the error returned by
+// Shutdown, which describes the first Background that didn't finish closing,
+// it tells what succeeded, so operators and tests can assert partial
+// progress after a timed out shutdown:
+//
+//	if err := bg.Shutdown(ctx); errors.Is(err, background.ErrTimeout) {
+//		log.Println(background.Report(bg))
+//	}
+func Report(bg Background) ShutdownReport {
+	var r ShutdownReport
+
+	walkPath(bg, func(bg Background, path []string) {
+		if !actsOnShutdown(bg) {
+			return
+		}
+
+		n := Node{Background: bg, ID: ID(bg), Name: strings.Join(path, ": ")}
+
+		switch {
+		case bg.IsFinished():
+			r.Completed = append(r.Completed, n)
+		case bg.IsClosing():
+			r.TimedOut = append(r.TimedOut, n)
+		default:
+			r.NotReached = append(r.NotReached, n)
+		}
+	})
+
+	return r
+}
+
+// actsOnShutdown reports whether bg is a step of the shutdown, see Plan.
+func actsOnShutdown(bg Background) bool {
+	switch bg := bg.(type) {
+	case ShutdownTail, *hookBackground, *delayBackground:
+		return true
+	case *adapterBackground:
+		_, ok := bg.component.(Shutdowner)
+		return ok
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package background
+
+import "sync"
+
+type resultBackground struct {
+	*group
+
+	results []interface{}
+
+	sync.Mutex
+}
+
+// ResultTail detaches after result Background initialization.
+// The tail is supposed to stay in a background job associated with
+// created Background.
+type ResultTail interface {
+	// Push adds v to the results of the Background.
+	Push(v interface{})
+}
+
+// WithResult returns new Background with merged children that collects
+// results of background jobs.
+//
+// The returned ResultTail is used to push results, which are aggregated
+// across the tree with Results.
+//
+// The results are untyped, as the package supports Go versions without
+// type parameters, so WithResult can't be generic over the result type.
+func WithResult(children ...Background) (Background, ResultTail) {
+	r := withResult(children...)
+	return r, r
+}
+
+func withResult(children ...Background) *resultBackground {
+	return &resultBackground{
+		group: merge(children...),
+	}
+}
+
+// Push adds v to the results of the Background.
+func (r *resultBackground) Push(v interface{}) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.results = append(r.results, v)
+}
+
+// Results returns results pushed to all result Backgrounds in bg's tree
+// in the same order as Background.Value searches the tree, and in the order
+// they were pushed within every Background. It is supposed to be called
+// after Wait, when all background jobs pushed their results.
+//
+// Results are untyped, packages that use WithResult should provide type-safe
+// accessors for them:
+//
+//	func Sums(bg background.Background) []int {
+//		var sums []int
+//		for _, v := range background.Results(bg) {
+//			sums = append(sums, v.(int))
+//		}
+//		return sums
+//	}
+func Results(bg Background) []interface{} {
+	var results []interface{}
+
+	walk(bg, func(bg Background) {
+		r, ok := bg.(*resultBackground)
+		if !ok {
+			return
+		}
+
+		r.Lock()
+		results = append(results, r.results...)
+		r.Unlock()
+	})
+
+	return results
+}
+
+func (r *resultBackground) DependsOn(children ...Background) Background {
+	return withDependency(r, children...)
+}
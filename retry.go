@@ -0,0 +1,283 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures the retries of WithStartupRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of start attempts.
+	// Zero means retrying until the start succeeds or the closing begins.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	// Zero means 100 milliseconds.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the delay grows by after every retry.
+	// Values less than 1 mean 2.
+	Multiplier float64
+
+	// OnRetry, if set, is called with every failure that is retried,
+	// so it can be reported as a warning.
+	OnRetry func(attempt int, err error)
+
+	// Clock tracks the delays between retries, the system clock is used
+	// if it's nil. Unlike the other time-dependent Backgrounds, the one
+	// created with WithStartupRetry has no children to find the clock
+	// assigned with WithClock in, so it is set here.
+	Clock Clock
+}
+
+// clock returns the policy's clock or the system clock.
+func (p RetryPolicy) clock() Clock {
+	if p.Clock == nil {
+		return SystemClock()
+	}
+
+	return p.Clock
+}
+
+// backoff returns the delay before the retry following the given attempt,
+// starting from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d, m := p.InitialBackoff, p.Multiplier
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+
+	if m < 1 {
+		m = 2
+	}
+
+	for i := 1; i < attempt; i++ {
+		d = time.Duration(float64(d) * m)
+
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	return d
+}
+
+type retryBackground struct {
+	*readinessBackground
+
+	// started is the Background returned by the successful start.
+	started Background
+
+	mu sync.RWMutex
+}
+
+// WithStartupRetry returns new Background that calls start until it succeeds
+// or policy's MaxAttempts is exhausted, waiting between the attempts
+// according to policy. The Background returned by the successful start
+// becomes the child of the returned one: it is closed with it and takes
+// part in its Err, Value, Wait, Reload, Pause and Resume calls, and
+// the returned Background becomes ready when the started one is ready.
+//
+// The failures that are retried are passed to policy's OnRetry.
+// If all attempts fail, the last failure is returned by the Background's Err
+// and the Background never becomes ready. The ctx passed to start is canceled
+// when the Background's closing begins, no attempts are made after that.
+func WithStartupRetry(policy RetryPolicy, start func(ctx context.Context) (Background, error)) Background {
+	var (
+		errs = withErrorGroup()
+		s    = withShutdown(errs)
+		r    = &retryBackground{readinessBackground: withReadiness(s)}
+	)
+
+	goLabeled(caller(2), func() {
+		defer s.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		started := make(chan Background, 1)
+		go func() { started <- startWithRetry(ctx, policy, start, errs) }()
+
+		var bg Background
+
+		select {
+		case bg = <-started:
+		case <-s.End():
+			cancel()
+			bg = <-started
+		}
+
+		if bg != nil {
+			r.mu.Lock()
+			r.started = bg
+			r.mu.Unlock()
+
+			bg.onReady(r.readinessBackground.Ok)
+		}
+
+		<-s.End()
+
+		if bg != nil {
+			bg.close(s.Context())
+			awaitFinish(s.Context(), s, bg)
+		}
+	})
+
+	return r
+}
+
+// startWithRetry calls start until it succeeds and returns the started
+// Background. If all attempts fail or ctx is canceled, it returns nil
+// and assigns the last failure to errs.
+func startWithRetry(ctx context.Context, p RetryPolicy, start func(ctx context.Context) (Background, error), errs ErrTail) Background {
+	for attempt := 1; ; attempt++ {
+		bg, err := start(ctx)
+		if err == nil {
+			if bg == nil {
+				bg = Empty()
+			}
+
+			return bg
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			errs.Error(fmt.Errorf("startup failed after %d attempts: %w", attempt, err))
+			return nil
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err)
+		}
+
+		timer := p.clock().NewTimer(p.backoff(attempt))
+
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// startedBackground returns the Background returned by the successful start
+// or nil.
+func (r *retryBackground) startedBackground() Background {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.started
+}
+
+func (r *retryBackground) Shutdown(ctx context.Context) error {
+	return r.result.do(ctx, r, func() error { return shutdown(ctx, r) })
+}
+
+func (r *retryBackground) Err() error {
+	if err := r.readinessBackground.Err(); err != nil {
+		return err
+	}
+
+	if bg := r.startedBackground(); bg != nil {
+		return bg.Err()
+	}
+
+	return nil
+}
+
+func (r *retryBackground) Value(key interface{}) interface{} {
+	if value := r.readinessBackground.Value(key); value != nil {
+		return value
+	}
+
+	if bg := r.startedBackground(); bg != nil {
+		return bg.Value(key)
+	}
+
+	return nil
+}
+
+func (r *retryBackground) Wait() {
+	r.readinessBackground.Wait()
+
+	if bg := r.startedBackground(); bg != nil {
+		bg.Wait()
+	}
+}
+
+func (r *retryBackground) WaitContext(ctx context.Context) error {
+	if err := r.readinessBackground.WaitContext(ctx); err != nil {
+		return err
+	}
+
+	if bg := r.startedBackground(); bg != nil {
+		return bg.WaitContext(ctx)
+	}
+
+	return nil
+}
+
+func (r *retryBackground) Reload(ctx context.Context) error {
+	if bg := r.startedBackground(); bg != nil {
+		if err := bg.Reload(ctx); err != nil {
+			return err
+		}
+	}
+
+	return r.readinessBackground.Reload(ctx)
+}
+
+func (r *retryBackground) Pause() {
+	if bg := r.startedBackground(); bg != nil {
+		bg.Pause()
+	}
+
+	r.readinessBackground.Pause()
+}
+
+func (r *retryBackground) Resume() {
+	r.readinessBackground.Resume()
+
+	if bg := r.startedBackground(); bg != nil {
+		bg.Resume()
+	}
+}
+
+func (r *retryBackground) DependsOn(children ...Background) Background {
+	return withDependency(r, children...)
+}
+
+func (r *retryBackground) cause(ctx context.Context) error {
+	if err := r.readinessBackground.cause(ctx); err != nil {
+		return err
+	}
+
+	if bg := r.startedBackground(); bg != nil {
+		return bg.cause(ctx)
+	}
+
+	return nil
+}
+
+func (r *retryBackground) branches() []Background {
+	bgs := r.readinessBackground.branches()
+
+	if bg := r.startedBackground(); bg != nil {
+		bgs = append(bgs[:len(bgs):len(bgs)], bg)
+	}
+
+	return bgs
+}
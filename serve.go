@@ -0,0 +1,63 @@
+package background
+
+import (
+	"context"
+	"fmt"
+)
+
+// Serve returns new Background running a server with the ListenAndServe
+// shape: start blocks while the server runs and stop makes it return,
+// like http.Server's ListenAndServe and Shutdown:
+//
+//	bg := background.Serve(server.ListenAndServe, server.Shutdown, func(err error) bool {
+//		return errors.Is(err, http.ErrServerClosed)
+//	})
+//
+// The errors returned from start for which isExpectedErr returns true are
+// ignored, nil isExpectedErr means that only nil is expected. The other
+// errors are fatal: they are returned by the Background's Err, so Await
+// returns as soon as the server fails.
+//
+// When the Background's closing begins, stop is called with the ctx
+// of the Shutdown call, and the closing waits for start to return.
+// An error returned from stop is returned by the Background's Err.
+// If start returns before the closing, stop isn't called.
+func Serve(start func() error, stop func(ctx context.Context) error, isExpectedErr func(error) bool) Background {
+	var (
+		errs = withErrorGroup()
+		s    = withShutdown(errs)
+	)
+
+	expected := func(err error) bool {
+		return err == nil || isExpectedErr != nil && isExpectedErr(err)
+	}
+
+	goLabeled(caller(2), func() {
+		defer s.Done()
+
+		served := make(chan error, 1)
+		go func() { served <- start() }()
+
+		select {
+		case err := <-served:
+			if !expected(err) {
+				errs.Error(fmt.Errorf("serve: %w", err))
+			}
+
+			<-s.End()
+
+			return
+		case <-s.End():
+		}
+
+		if err := stop(s.Context()); err != nil {
+			errs.Error(fmt.Errorf("stop: %w", err))
+		}
+
+		if err := <-served; !expected(err) {
+			errs.Error(fmt.Errorf("serve: %w", err))
+		}
+	})
+
+	return s
+}
@@ -2,15 +2,45 @@ package background
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type shutdownBackground struct {
+	// endCalled is set to 1 on the first End call. It is accessed atomically.
+	endCalled int32
+
+	// relinquished is set to 1 on Close call. It is accessed atomically.
+	relinquished int32
+
 	*group
 
 	end  chan struct{}
 	done chan struct{}
 
+	// ctx is the context of the Shutdown call that initiated the closing.
+	ctx context.Context
+
+	// closedAt is the time when the End channel was closed.
+	closedAt time.Time
+
+	// stack is the stack trace of Background's creation,
+	// nil if capturing stack traces is disabled.
+	stack Stack
+
+	// path is the annotation path recorded in strict mode, see strictAnnotate.
+	path string
+
+	// pending is the number of Done calls left to finish the shutdown.
+	pending int
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
+
 	sync.Mutex
 }
 
@@ -26,31 +56,131 @@ type ShutdownTail interface {
 	// Done sends a signal that a shutdown is complete.
 	// Not calling Done will block all parents closing and cause
 	// the Background's Shutdown call to return ErrTimeout or block forever.
-	// After the first call, subsequent calls do nothing.
+	// If Add was called, Done must be called one more time than the sum
+	// of all deltas passed to Add to complete the shutdown.
+	// After the shutdown is complete, subsequent calls do nothing.
 	Done()
+
+	// Add adds delta to the number of Done calls required to complete the
+	// shutdown. It is useful when work done on behalf of tail's Background
+	// spans multiple goroutines, each of which calls Done when it is shut down.
+	// After the shutdown is complete, Add does nothing.
+	Add(delta int)
+
+	// Context returns the context of the shutdown once the End channel
+	// is closed. It carries the deadline and values of the ctx passed to the
+	// Shutdown call that initiated the shutdown of the tree and is canceled
+	// when that ctx is done, so it can be passed to shutdown methods of
+	// external APIs, e.g. http.Server's Shutdown.
+	// Before the End channel is closed, Context returns context.Background().
+	Context() context.Context
+
+	// CloseInfo returns the description of the shutdown once the End channel
+	// is closed: the Background that initiated it, its reason and deadline.
+	// Before the End channel is closed, CloseInfo returns zero CloseInfo.
+	CloseInfo() CloseInfo
+
+	// Close relinquishes the tail when the background job it was created for
+	// is not started, e.g. when its feature is disabled: the Done calls are
+	// not required after Close, so the shutdown of the Background is complete
+	// as soon as its children are shut down.
+	Close()
 }
 
 func (s *shutdownBackground) End() (c <-chan struct{}) {
+	atomic.StoreInt32(&s.endCalled, 1)
 	return s.end
 }
 
+func (s *shutdownBackground) Context() context.Context {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
+func (s *shutdownBackground) CloseInfo() CloseInfo {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.ctx == nil {
+		return CloseInfo{}
+	}
+
+	return CloseInfoFrom(s.ctx)
+}
+
+func (s *shutdownBackground) setStrictPath(path string) {
+	s.Lock()
+	s.path = path
+	s.Unlock()
+}
+
+func (s *shutdownBackground) strictPath() string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.path
+}
+
 func (s *shutdownBackground) Done() {
 	s.Lock()
 	defer s.Unlock()
 
+	if atomic.LoadInt32(&s.relinquished) == 1 {
+		// no job is associated with the tail
+		return
+	}
+
 	select {
 	case <-s.done:
 		// Already closed
 	default:
+		s.pending--
+		if s.pending <= 0 {
+			close(s.done)
+		}
+	}
+}
+
+func (s *shutdownBackground) Close() {
+	atomic.StoreInt32(&s.endCalled, 1)
+	atomic.StoreInt32(&s.relinquished, 1)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.pending = 0
+
+	// the done channel isn't closed before the End channel, so the parents
+	// don't take the Background for the finished one and close its children
+	if isClosed(s.end) && !isClosed(s.done) {
 		close(s.done)
 	}
 }
 
+func (s *shutdownBackground) Add(delta int) {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.done:
+		// Already closed
+	default:
+		s.pending += delta
+	}
+}
+
 // closer is used for graceful shutdown.
 type closer interface {
 	// close sends close signal to the Background and blocks until the closing
-	// is complete.
-	close()
+	// is complete. The ctx is the context of the Shutdown call that initiated
+	// the closing, it is propagated down the tree.
+	close(ctx context.Context)
 
 	// finishSig returns a channel that's closed when the closing
 	// is complete.
@@ -60,22 +190,283 @@ type closer interface {
 	// of unclosed children to accumulate annotations. There is a
 	// chance that the closing will complete during that check -
 	// in this case it is considered as fully completed and returns nil.
-	cause() error
+	// The ctx is the context of the expired Shutdown call.
+	cause(ctx context.Context) error
+}
+
+// TimeoutError is the error returned by Background.Shutdown when its ctx is
+// done before the shutdown is complete. It describes the first found unclosed
+// Background and is wrapped in annotations on the path to it.
+//
+// TimeoutError matches ErrTimeout with errors.Is, and ErrCanceled as well
+// if the ctx was canceled rather than expired. It unwraps to its Cause
+// if there is one, or to its Err otherwise.
+type TimeoutError struct {
+	// Err is the error of the Shutdown's ctx: context.Canceled or
+	// context.DeadlineExceeded.
+	Err error
+
+	// Cause is the cause of the ctx's cancellation set with
+	// context.WithCancelCause and similar functions, nil if there is none.
+	// The cause is added to the error's message.
+	Cause error
+
+	// Deadline is the deadline of the Shutdown's ctx,
+	// zero if the ctx has no deadline.
+	Deadline time.Time
+
+	// Exceeded is the time passed since the deadline when the unclosed
+	// Background was found.
+	Exceeded time.Duration
+
+	// Closing is the time passed since the unclosed Background received its
+	// close signal, zero if the signal is not received yet.
+	Closing time.Duration
+
+	// Stack is the stack trace of the unclosed Background's creation,
+	// nil if capturing stack traces is disabled with SetStackTraces.
+	// Formatting TimeoutError with %+v prints the stack trace.
+	Stack Stack
+
+	// DuringStartup is true if the tree was not ready yet when the shutdown
+	// began and Options' ReportStartup is enabled. Such errors match
+	// ErrShutdownDuringStartup and mention it in their message.
+	DuringStartup bool
+
+	// Goroutines is the dump of goroutines that may block the unclosed
+	// Background, nil if Options' DumpGoroutines is disabled. If Stack is
+	// known, only goroutines created by the function that created
+	// the Background are dumped, otherwise all goroutines are.
+	// Formatting TimeoutError with %+v prints the dump.
+	Goroutines []byte
+}
+
+func newTimeoutError(ctx context.Context, closedAt time.Time) *TimeoutError {
+	now := time.Now()
+	err := &TimeoutError{Err: ctx.Err()}
+
+	if cause := contextCause(ctx); cause != err.Err {
+		err.Cause = cause
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err.Deadline = deadline
+		err.Exceeded = now.Sub(deadline)
+	}
+
+	if !closedAt.IsZero() {
+		err.Closing = now.Sub(closedAt)
+	}
+
+	// decided before err is wrapped, as the wrapping errors format
+	// its message right away
+	err.DuringStartup = reportsStartup(ctx)
+
+	return err
+}
+
+func (e *TimeoutError) Error() string {
+	msg := ErrTimeout.Error()
+	if e.canceled() {
+		msg = ErrCanceled.Error()
+	}
+
+	if e.DuringStartup {
+		msg = fmt.Sprintf("%s: %s", ErrShutdownDuringStartup, msg)
+	}
+
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s", msg, e.Cause)
+	}
+
+	return msg
+}
+
+// Is reports whether target is ErrTimeout, ErrCanceled if the ctx
+// was canceled, or ErrShutdownDuringStartup if DuringStartup is true.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout ||
+		target == ErrCanceled && e.canceled() ||
+		target == ErrShutdownDuringStartup && e.DuringStartup
+}
+
+// canceled reports whether the ctx was canceled rather than expired.
+func (e *TimeoutError) canceled() bool {
+	return errors.Is(e.Err, context.Canceled)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	if e.Cause != nil {
+		return e.Cause
+	}
+
+	return e.Err
+}
+
+func (e *TimeoutError) Format(s fmt.State, verb rune) {
+	formatWithStack(s, verb, e, e.Stack)
+
+	if verb == 'v' && s.Flag('+') && len(e.Goroutines) != 0 {
+		fmt.Fprintf(s, "\ngoroutines:\n\n%s", e.Goroutines)
+	}
+}
+
+// shutdownResult stores the result of the first Shutdown call that
+// completed the shutdown.
+type shutdownResult struct {
+	completed bool
+	err       error
+
+	// running is closed when the call running f returns,
+	// it is nil if no call runs f
+	running chan struct{}
+
+	sync.RWMutex
+}
+
+// do returns the stored result if there is one, otherwise it calls f
+// shutting down bg with ctx and stores its result if the shutdown of bg
+// completed. Simultaneous calls don't run f again, but wait for the running
+// call to return or for their ctx to be done, whichever happens first.
+func (r *shutdownResult) do(ctx context.Context, bg Background, f func() error) error {
+	for {
+		r.Lock()
+
+		if r.completed {
+			err := r.err
+			r.Unlock()
+
+			return err
+		}
+
+		running := r.running
+		if running == nil {
+			running = make(chan struct{})
+			r.running = running
+			r.Unlock()
+
+			return r.run(bg, f, running)
+		}
+
+		r.Unlock()
+
+		// the result of the timed out call is not stored,
+		// so the waiting calls try the shutdown again
+		select {
+		case <-running:
+		case <-ctx.Done():
+			ctx = withStartup(ctx, bg)
+			return reportStartup(ctx, bg.cause(ctx))
+		}
+	}
+}
+
+// run calls f and stores its result if the shutdown of bg completed,
+// then closes running.
+func (r *shutdownResult) run(bg Background, f func() error, running chan struct{}) error {
+	err := f()
+
+	r.Lock()
+	if isClosed(bg.finishSig()) {
+		r.err, r.completed = err, true
+	}
+	r.running = nil
+	r.Unlock()
+
+	close(running)
+
+	return err
+}
+
+// get returns the stored result and whether it is stored.
+func (r *shutdownResult) get() (error, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.err, r.completed
 }
 
 // shutdown is a function for shutting down Backgrounds that implements
-// closer interface
-func shutdown(ctx context.Context, c closer) error {
-	go c.close()
+// closer interface. The bg is recorded as the initiator of the shutdown,
+// the leave hooks in bg's tree run before the closing, and the Backgrounds
+// assigned to phases are closed before the rest of the tree.
+// If ctx has no deadline, the shutdown is limited by Options' ShutdownTimeout.
+// The shutdown of a tree that is not ready yet is marked as during startup,
+// see CloseInfo's DuringStartup.
+func shutdown(ctx context.Context, bg Background) error {
+	ctx = withStartup(withInitiator(ctx, bg), bg)
 
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := optionsFrom(ctx).ShutdownTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+
+	closed := make(chan struct{})
+
+	go func() {
+		announce(ctx, bg)
+		closePhases(ctx, bg)
+		bg.close(ctx)
+		close(closed)
+	}()
+
+	// the close call is awaited as well, so bg's Finished is closed
+	// by the time Shutdown returns even if bg had nothing to close
 	select {
-	case <-c.finishSig():
-		return nil
+	case <-closed:
+	case <-ctx.Done():
+		return reportStartup(ctx, bg.cause(ctx))
+	}
+
+	select {
+	case <-bg.finishSig():
+		return reportStartup(ctx, nil)
 	case <-ctx.Done():
-		return c.cause()
+		return reportStartup(ctx, bg.cause(ctx))
 	}
 }
 
+// isReadyNow reports whether bg is ready. Unlike Ready, it doesn't start
+// the lazy components, see Lazy.
+func isReadyNow(bg Background) bool {
+	var ready int32
+
+	bg.onReady(func() { atomic.StoreInt32(&ready, 1) })
+
+	return atomic.LoadInt32(&ready) == 1
+}
+
+// withStartup returns ctx marking the shutdown as during startup if bg
+// is not ready, unless ctx already marks it.
+func withStartup(ctx context.Context, bg Background) context.Context {
+	if _, ok := ctx.Value(startupKey{}).(bool); !ok {
+		ctx = context.WithValue(ctx, startupKey{}, !isReadyNow(bg))
+	}
+
+	return ctx
+}
+
+// reportStartup returns ErrShutdownDuringStartup instead of nil err
+// of the shutdown with ctx if reportsStartup is true. The timeout errors
+// are marked as during startup when they are created, see newTimeoutError.
+func reportStartup(ctx context.Context, err error) error {
+	if err == nil && reportsStartup(ctx) {
+		return ErrShutdownDuringStartup
+	}
+
+	return err
+}
+
+// reportsStartup reports whether Options' ReportStartup is enabled and
+// the tree shut down with ctx was not ready.
+func reportsStartup(ctx context.Context) bool {
+	return optionsFrom(ctx).ReportStartup && CloseInfoFrom(ctx).DuringStartup
+}
+
 // WithShutdown returns a new shutdownable Background that depends on children.
 //
 // The returned ShutdownTail's End channel is closed when Background's Shutdown
@@ -91,11 +482,16 @@ func WithShutdown(children ...Background) (Background, ShutdownTail) {
 
 func withShutdown(children ...Background) *shutdownBackground {
 	s := &shutdownBackground{
-		group: merge(children...),
-		done:  make(chan struct{}),
-		end:   make(chan struct{}),
+		group:   merge(children...),
+		done:    make(chan struct{}),
+		end:     make(chan struct{}),
+		pending: 1,
+		stack:   callers(2),
 	}
 
+	strictTrackShutdown(s)
+	trackShutdown(s)
+
 	return s
 }
 
@@ -103,22 +499,35 @@ func withShutdown(children ...Background) *shutdownBackground {
 // Shutdown shuts down its children first, wait until all of them
 // are successfully shut down and then shuts down itself.
 func (s *shutdownBackground) Shutdown(ctx context.Context) error {
-	return shutdown(ctx, s)
+	return s.result.do(ctx, s, func() error { return shutdown(ctx, s) })
 }
 
-func (s *shutdownBackground) close() {
-	go s.group.close()
-	<-s.group.finishSig()
+func (s *shutdownBackground) close(ctx context.Context) {
+	s.closeOnce.Do(func() {
+		s.group.close(ctx)
+		if !awaitFinish(ctx, s.group, s.group) {
+			return
+		}
 
-	s.Lock()
-	defer s.Unlock()
+		s.Lock()
+		defer s.Unlock()
 
-	select {
-	case <-s.end:
-		return // Already closed
-	default:
+		s.ctx = ctx
+		s.closedAt = time.Now()
 		close(s.end)
-	}
+
+		if atomic.LoadInt32(&s.relinquished) == 1 && !isClosed(s.done) {
+			close(s.done)
+		}
+	})
+}
+
+func (s *shutdownBackground) Finished() <-chan struct{} {
+	return s.done
+}
+
+func (s *shutdownBackground) IsFinished() bool {
+	return isClosed(s.done)
 }
 
 func (s *shutdownBackground) finishSig() <-chan struct{} {
@@ -129,8 +538,8 @@ func (s *shutdownBackground) DependsOn(children ...Background) Background {
 	return withDependency(s, children...)
 }
 
-func (s *shutdownBackground) cause() error {
-	if err := s.group.cause(); err != nil {
+func (s *shutdownBackground) cause(ctx context.Context) error {
+	if err := s.group.cause(ctx); err != nil {
 		return err
 	}
 
@@ -138,6 +547,17 @@ func (s *shutdownBackground) cause() error {
 	case <-s.done:
 		return nil
 	default:
-		return ErrTimeout
+		s.Lock()
+		closedAt := s.closedAt
+		s.Unlock()
+
+		err := newTimeoutError(ctx, closedAt)
+		err.Stack = s.stack
+
+		if optionsFrom(ctx).DumpGoroutines {
+			err.Goroutines = goroutinesCreatedBy(s.stack)
+		}
+
+		return strictTimeoutCause(ctx, s, err)
 	}
 }
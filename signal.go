@@ -0,0 +1,65 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// SignalError is the error returned by Await when an OS signal is received.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("received signal: %s", e.Signal)
+}
+
+// signalsKey is a private Value key for signalBackground.
+type signalsKey struct{}
+
+type signalBackground struct {
+	*group
+
+	c chan os.Signal
+}
+
+// WithSignals returns new Background with merged children that relays
+// incoming signals to Await. If no signals are provided, all incoming
+// signals are relayed, see signal.Notify.
+//
+// The relaying stops when the Background's closing begins.
+func WithSignals(signals []os.Signal, children ...Background) Background {
+	return withSignals(signals, children...)
+}
+
+func withSignals(signals []os.Signal, children ...Background) *signalBackground {
+	s := &signalBackground{
+		group: merge(children...),
+		c:     make(chan os.Signal, 1),
+	}
+
+	signal.Notify(s.c, signals...)
+
+	return s
+}
+
+// Value returns the Background itself if key is signalsKey, otherwise
+// it returns value associated with key from its children.
+func (s *signalBackground) Value(key interface{}) interface{} {
+	if key == (signalsKey{}) {
+		return s
+	}
+
+	return s.group.Value(key)
+}
+
+func (s *signalBackground) close(ctx context.Context) {
+	signal.Stop(s.c)
+	s.group.close(ctx)
+}
+
+func (s *signalBackground) DependsOn(children ...Background) Background {
+	return withDependency(s, children...)
+}
@@ -0,0 +1,194 @@
+package background
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TreeSnapshot is a point-in-time view of the state of a tree of Backgrounds.
+type TreeSnapshot struct {
+	// Nodes are the tree's Backgrounds in the same order as Background.Value
+	// searches the tree. Backgrounds shared between multiple parents are
+	// listed once, under the first parent.
+	Nodes []NodeSnapshot
+}
+
+// NodeSnapshot is the state of a single Background in TreeSnapshot.
+type NodeSnapshot struct {
+	// ID is the Background's NodeID, see FindByID.
+	ID NodeID
+
+	// Name is the annotations on the path to the Background joined with ": ",
+	// the same way as they annotate errors, or empty if there are none.
+	Name string
+
+	// Type is the Background's implementation type.
+	Type string
+
+	// Depth is the number of the Background's ancestors.
+	Depth int
+
+	// Index is the position of the Background among its parent's children
+	// in the order they were passed, not counting nil and repeated ones,
+	// and Source describes how they were
+	// passed. Backgrounds created with DependsOn have two children:
+	// the Background DependsOn was called on and the merged dependencies.
+	Index  int
+	Source Source
+
+	// Closing is true if the Background's closing began.
+	Closing bool
+
+	// Finished is true if the Background's closing is complete.
+	Finished bool
+
+	// PreFinished is true if the Background was already finished when
+	// it was merged with its parent. Such Backgrounds are not closed by
+	// the parent, but their errors and values are still visible in it.
+	PreFinished bool
+
+	// Abandoned is true if the Background gave up waiting for its children
+	// that haven't finished closing, see Options' Abandon.
+	Abandoned bool
+
+	// Pending is the number of the Background's children that haven't
+	// finished closing, for Backgrounds created with Merge functions.
+	Pending int
+
+	// Disabled is true if the Background is a disabled component, see If.
+	Disabled bool
+}
+
+// stateLocker is implemented by Backgrounds that guard the changes of their
+// closing state with a lock.
+type stateLocker interface {
+	rlockState()
+	runlockState()
+}
+
+func (g *group) rlockState()   { g.RLock() }
+func (g *group) runlockState() { g.RUnlock() }
+
+func (d *dependBackground) rlockState()   { d.RLock() }
+func (d *dependBackground) runlockState() { d.RUnlock() }
+
+// preFinisher is implemented by Backgrounds that record the children
+// that were already finished when merged.
+type preFinisher interface {
+	preFinishedChildren() []Background
+}
+
+func (g *group) preFinishedChildren() []Background {
+	children := make([]Background, len(g.preFinished))
+	for i, j := range g.preFinished {
+		children[i] = g.backgrounds[j]
+	}
+
+	return children
+}
+
+// Snapshot returns a consistent view of the state of bg's tree for debug
+// handlers, metrics and tests. The locks guarding the state of the tree's
+// groups are held until the whole tree is read, so Snapshot is safe
+// to call concurrently with the shutdown and doesn't observe a group's
+// children finishing halfway. The state of the leaves, such as finishing
+// of WithShutdown Backgrounds, is read without locks.
+func Snapshot(bg Background) TreeSnapshot {
+	var (
+		s           TreeSnapshot
+		locked      []stateLocker
+		preFinished = make(map[Background]struct{})
+	)
+
+	walkDepth(bg, func(bg Background, path []string, depth int, l link) {
+		if l, ok := bg.(stateLocker); ok {
+			l.rlockState()
+			locked = append(locked, l)
+		}
+
+		var pre bool
+		if hashable(bg) {
+			_, pre = preFinished[bg]
+		}
+
+		node := NodeSnapshot{
+			ID:          ID(bg),
+			Name:        strings.Join(path, ": "),
+			Type:        fmt.Sprintf("%T", bg),
+			Depth:       depth,
+			Index:       l.index,
+			Source:      l.source,
+			Closing:     bg.IsClosing(),
+			Finished:    bg.IsFinished(),
+			PreFinished: pre,
+		}
+
+		// children are visited after their parent
+		if p, ok := bg.(preFinisher); ok {
+			for _, child := range p.preFinishedChildren() {
+				if hashable(child) {
+					preFinished[child] = struct{}{}
+				}
+			}
+		}
+
+		if a, ok := bg.(abandoner); ok {
+			node.Abandoned = a.isAbandoned()
+		}
+
+		_, node.Disabled = bg.(*disabledBackground)
+
+		if g, ok := bg.(*group); ok {
+			for i := range g.toClose {
+				if !g.backgrounds[i].IsFinished() {
+					node.Pending++
+				}
+			}
+		}
+
+		s.Nodes = append(s.Nodes, node)
+	})
+
+	for i := len(locked) - 1; i >= 0; i-- {
+		locked[i].runlockState()
+	}
+
+	return s
+}
+
+// String formats the snapshot as an indented tree, one Background per line.
+func (s TreeSnapshot) String() string {
+	var b strings.Builder
+
+	for _, n := range s.Nodes {
+		b.WriteString(strings.Repeat("  ", n.Depth))
+		b.WriteString(n.Type)
+
+		if n.Name != "" {
+			fmt.Fprintf(&b, " %q", n.Name)
+		}
+
+		switch {
+		case n.Disabled:
+			b.WriteString(": disabled")
+		case n.PreFinished:
+			b.WriteString(": finished before merged")
+		case n.Finished:
+			b.WriteString(": finished")
+		case n.Abandoned:
+			b.WriteString(": abandoned")
+		case n.Closing:
+			b.WriteString(": closing")
+		default:
+			b.WriteString(": running")
+		}
+
+		if n.Pending > 0 {
+			fmt.Fprintf(&b, ", %d pending", n.Pending)
+		}
+
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,138 @@
+// Package sqlbg integrates database/sql connection pools with package
+// background.
+//
+// The returned Background health-checks the database, becomes ready once
+// the database is reachable and closes the pool on shutdown:
+//
+//	db, err := sql.Open("postgres", dsn)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	bg := sqlbg.New(db, 10*time.Second)
+package sqlbg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+// statsKey is a private Value key for the pool's shutdown stats.
+type statsKey struct{}
+
+// shutdownStats holds the pool's stats captured at the shutdown.
+type shutdownStats struct {
+	stats    sql.DBStats
+	captured bool
+
+	sync.Mutex
+}
+
+// New returns new Background that pings db with PingContext every
+// pingInterval. The Background becomes ready after the first successful
+// ping and is marked as degraded, see background.WithHealth, while the pings
+// fail. Every ping is limited by pingInterval and is canceled once
+// the shutdown begins.
+//
+// On shutdown the pings stop and db is closed, which waits for the queries
+// in progress. The pool's stats captured right before the closing are
+// available with ShutdownStats, and an error returned from db's Close
+// is returned by the Background's Err annotated with the number of
+// connections that were in use.
+func New(db *sql.DB, pingInterval time.Duration) background.Background {
+	var (
+		stats            = &shutdownStats{}
+		healthBg, health = background.WithHealth()
+		errBg, errs      = background.WithErrorGroup()
+		bg, tail         = background.WithShutdown(healthBg, errBg)
+
+		// the readiness is the parent of the shutdown, so it isn't closed
+		// until the pings stop and readiness.Ok can't race the shutdown
+		readyBg, readiness = background.WithReadiness(bg)
+	)
+
+	go func() {
+		defer tail.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			select {
+			case <-tail.End():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for {
+			ping(ctx, db, pingInterval, health, readiness)
+
+			timer := time.NewTimer(pingInterval)
+
+			select {
+			case <-timer.C:
+			case <-tail.End():
+				timer.Stop()
+
+				s := db.Stats()
+				stats.capture(s)
+
+				if err := db.Close(); err != nil {
+					errs.Error(fmt.Errorf("close with %d connections in use: %w", s.InUse, err))
+				}
+
+				return
+			}
+		}
+	}()
+
+	return background.WithValue(statsKey{}, stats, readyBg)
+}
+
+// ping pings db and reports the result to health and readiness.
+// A ping canceled with ctx isn't reported.
+func ping(ctx context.Context, db *sql.DB, timeout time.Duration, health background.HealthTail, readiness background.ReadinessTail) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := db.PingContext(ctx)
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	if err != nil {
+		health.Degrade(fmt.Sprintf("ping: %v", err))
+		return
+	}
+
+	health.Recover()
+	readiness.Ok()
+}
+
+func (s *shutdownStats) capture(stats sql.DBStats) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stats, s.captured = stats, true
+}
+
+// ShutdownStats returns the stats of the pool captured right before it was
+// closed by the first Background created with New in bg's tree. The second
+// result is false if there is no such Background or it's not shut down yet.
+func ShutdownStats(bg background.Background) (sql.DBStats, bool) {
+	s, ok := bg.Value(statsKey{}).(*shutdownStats)
+	if !ok {
+		return sql.DBStats{}, false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.stats, s.captured
+}
@@ -0,0 +1,123 @@
+package sqlbg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+const (
+	failTimeout = 100 * time.Millisecond
+
+	interval = time.Millisecond
+)
+
+// pingConnector is a driver.Connector of connections that fail pings while
+// its down is 1 and block pings until their context is done while its
+// hang is 1.
+type pingConnector struct {
+	down int32
+	hang int32
+}
+
+func (c *pingConnector) Connect(context.Context) (driver.Conn, error) { return &pingConn{c}, nil }
+func (c *pingConnector) Driver() driver.Driver                        { return nil }
+
+type pingConn struct {
+	c *pingConnector
+}
+
+func (c *pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *pingConn) Close() error                        { return nil }
+func (c *pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *pingConn) Ping(ctx context.Context) error {
+	if atomic.LoadInt32(&c.c.hang) == 1 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if atomic.LoadInt32(&c.c.down) == 1 {
+		return errors.New("down")
+	}
+
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	var (
+		connector = &pingConnector{down: 1}
+		db        = sql.OpenDB(connector)
+		bg        = New(db, interval)
+	)
+
+	time.Sleep(failTimeout / 10)
+
+	select {
+	case <-bg.Ready():
+		t.Fatal("ready while the database is down")
+	default:
+	}
+
+	if degradations := background.Degraded(bg); len(degradations) != 1 || degradations[0].Reason != "ping: down" {
+		t.Errorf("wrong degradations: %v", degradations)
+	}
+
+	atomic.StoreInt32(&connector.down, 0)
+
+	select {
+	case <-bg.Ready():
+	case <-time.After(failTimeout):
+		t.Fatal("not ready while the database is up")
+	}
+
+	if degradations := background.Degraded(bg); degradations != nil {
+		t.Errorf("unexpected degradations: %v", degradations)
+	}
+
+	if _, ok := ShutdownStats(bg); ok {
+		t.Error("stats captured before shutdown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats, ok := ShutdownStats(bg); !ok || stats.OpenConnections != 1 {
+		t.Errorf("wrong shutdown stats: %+v, %t", stats, ok)
+	}
+
+	if err := db.Ping(); err == nil {
+		t.Error("database wasn't closed")
+	}
+}
+
+func TestNewShutdownDuringPing(t *testing.T) {
+	var (
+		connector = &pingConnector{hang: 1}
+		db        = sql.OpenDB(connector)
+		bg        = New(db, time.Hour)
+	)
+
+	time.Sleep(failTimeout / 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatalf("ping in progress blocked the shutdown: %v", err)
+	}
+
+	if degradations := background.Degraded(bg); degradations != nil {
+		t.Errorf("canceled ping is reported: %v", degradations)
+	}
+}
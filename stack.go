@@ -0,0 +1,103 @@
+package background
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// stackTraces is set to 1 when stack traces capturing is enabled.
+// It is accessed atomically.
+var stackTraces int32
+
+// SetStackTraces enables or disables capturing of stack traces:
+//
+// 1. Errors assigned with ErrTail are wrapped in StackError with the stack
+// trace of the Error or Errorf call.
+//
+// 2. TimeoutError of a Shutdown call carries the stack trace of the unclosed
+// Background's creation.
+//
+// Capturing stack traces is expensive, it is disabled by default.
+// SetStackTraces affects only errors and Backgrounds created after the call,
+// so it is supposed to be called at the start of the program.
+func SetStackTraces(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&stackTraces, v)
+}
+
+// Stack is a stack trace.
+type Stack []uintptr
+
+// callers returns the stack trace of the caller skip frames above callers'
+// own caller, or nil if capturing stack traces is disabled.
+func callers(skip int) Stack {
+	if atomic.LoadInt32(&stackTraces) == 0 {
+		return nil
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+
+	return pcs[:n]
+}
+
+// String formats the stack trace the same way as runtime/debug.Stack.
+func (s Stack) String() string {
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(s)
+
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+// StackError is an error with the stack trace of the place where it was
+// assigned to a Background.
+//
+// Formatting StackError with %+v prints the error followed by the stack trace.
+type StackError struct {
+	Err   error
+	Stack Stack
+}
+
+func (e *StackError) Error() string { return e.Err.Error() }
+func (e *StackError) Unwrap() error { return e.Err }
+
+func (e *StackError) Format(s fmt.State, verb rune) {
+	formatWithStack(s, verb, e, e.Stack)
+}
+
+// formatWithStack formats err as fmt's %v, %s and %q verbs do and adds stack
+// to the output of the %+v verb.
+func formatWithStack(s fmt.State, verb rune, err error, stack Stack) {
+	switch verb {
+	case 'v':
+		io.WriteString(s, err.Error())
+
+		if s.Flag('+') && len(stack) != 0 {
+			io.WriteString(s, "\n")
+			io.WriteString(s, stack.String())
+		}
+	case 's':
+		io.WriteString(s, err.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	}
+}
@@ -0,0 +1,54 @@
+package background
+
+import (
+	"context"
+	"fmt"
+)
+
+// Startup runs the init steps in order and returns the Background with merged
+// Backgrounds returned by the steps, which are closed in the reverse order
+// of the steps during shutdown, see MergeReverse. The ctx is passed
+// to every step.
+//
+// Startup stops at the first step that fails or once ctx is done, shuts down
+// the Backgrounds returned by the previous steps in the reverse order and
+// returns the error, so no manual teardown of partially initialized services
+// is needed:
+//
+//	bg, err := background.Startup(ctx, openDB, connectBroker, startServer)
+//	if err != nil {
+//		log.Fatal(err) // the DB and the broker are already shut down
+//	}
+//
+// The teardown isn't bound to ctx, it is limited by Options' ShutdownTimeout
+// only. Its failure is appended to the returned error. The steps may return
+// nil Backgrounds.
+func Startup(ctx context.Context, steps ...func(ctx context.Context) (Background, error)) (Background, error) {
+	started := make([]Background, 0, len(steps))
+
+	for i, step := range steps {
+		err := ctx.Err()
+		if err == nil {
+			var bg Background
+
+			bg, err = step(ctx)
+			if err == nil {
+				if bg != nil {
+					started = append(started, bg)
+				}
+
+				continue
+			}
+		}
+
+		err = fmt.Errorf("step %d: %w", i+1, err)
+
+		if tErr := MergeReverse(started...).Shutdown(context.Background()); tErr != nil {
+			err = fmt.Errorf("%w; teardown: %v", err, tErr)
+		}
+
+		return nil, err
+	}
+
+	return MergeReverse(started...), nil
+}
@@ -74,6 +74,14 @@ type Background interface {
 	// It uses sync.Waitgroup under the hood and shares all its mechanics.
 	Wait()
 
+	// WaitContext blocks until all counters of WaitGroups in this Background
+	// are zero or ctx is done.
+	//
+	// If ctx is done before all counters are zero, WaitContext returns
+	// ctx.Err() annotated by annotation Backgrounds in a chain on the path
+	// to the first found WaitGroup with non-zero counter.
+	WaitContext(ctx context.Context) error
+
 	// Shutdown gracefully shuts down this Background.
 	// Ths shutdown occurs from bottom to top: parents shut down their
 	// children, wait until all of them are successfully shut down and
@@ -81,11 +89,45 @@ type Background interface {
 	//
 	// If ctx expires before the shutdown is complete, Shutdown tries
 	// to find the first full path of unclosed children to accumulate
-	// annotations and returns ErrTimeout wrapped in them.
+	// annotations and returns ErrTimeout wrapped in them, see TimeoutError.
+	// If ctx is canceled with a cause, the cause is wrapped as well.
 	// There is a chance that the shutdown will complete during that check -
 	// in this case, it is considered as fully completed and returns nil.
+	//
+	// The result of the first Shutdown call that completes the shutdown
+	// is stored: subsequent calls return it without shutting down again,
+	// regardless of ctx. The result of the timed out call is not stored,
+	// so the subsequent calls wait for the shutdown with their own ctx.
+	// Simultaneous calls are serialized.
 	Shutdown(ctx context.Context) error
 
+	// ShutdownResult returns the stored result of the first Shutdown call
+	// that completed the shutdown and true, or nil and false if there
+	// is no such call yet.
+	ShutdownResult() (error, bool)
+
+	// Reload reloads all reloadable Backgrounds in this Background.
+	// The reload occurs in the same order as the shutdown: parents reload
+	// their children, wait until all of them are successfully reloaded and
+	// then reload themselves.
+	//
+	// Reload returns the first encountered reload error annotated by
+	// annotation Backgrounds in a chain. If ctx expires before the reload
+	// is complete, Reload returns ErrTimeout.
+	Reload(ctx context.Context) error
+
+	// Pause pauses all pausable Backgrounds in this Background.
+	// The pause occurs in the same order as the shutdown: parents pause
+	// their children first and then pause themselves.
+	// Calling Pause on already paused Background does nothing.
+	Pause()
+
+	// Resume resumes all pausable Backgrounds in this Background
+	// in the reverse order: parents resume themselves first and then
+	// resume their children.
+	// Calling Resume on not paused Background does nothing.
+	Resume()
+
 	// Ready returns a channel that signals that all Backgrounds in tree are
 	// ready. If there is no readiness Backgrounds in the tree - Background is considered
 	// as ready by default.
@@ -95,6 +137,29 @@ type Background interface {
 	// handle possible block.
 	Ready() <-chan struct{}
 
+	// Finished returns a channel that's closed when this Background is fully
+	// closed: all its children and the Background itself are shut down,
+	// either by its Shutdown or by a parent. It allows to observe
+	// the completion of the shutdown without holding the Shutdown call.
+	// Successive calls to Finished return the same value.
+	Finished() <-chan struct{}
+
+	// Closing returns a channel that's closed when the closing of this
+	// Background begins, either by its Shutdown or by a parent, before its
+	// children are closed. It allows code outside of the tree to react
+	// to the start of the shutdown.
+	// Successive calls to Closing return the same value.
+	Closing() <-chan struct{}
+
+	// IsFinished reports whether the channel returned by Finished is closed.
+	// It doesn't block and is cheap enough for hot paths.
+	IsFinished() bool
+
+	// IsClosing reports whether the channel returned by Closing is closed.
+	// It doesn't block and is cheap enough for hot paths, e.g. to reject
+	// new work once the shutdown began.
+	IsClosing() bool
+
 	// Value returns the first found value in this Background for key,
 	// or nil if no value is associated with key. The tree is searched
 	// from top to bottom and from left to right.
@@ -128,6 +193,9 @@ type Background interface {
 	// necessary to have it in exported interface for cases of embedding
 	// Background into another struct.
 	closer
+
+	// readier is a private interface used for readiness propagation.
+	readier
 }
 
 var (
@@ -135,10 +203,37 @@ var (
 	// timeout is expired
 	ErrTimeout = errors.New("timeout expired")
 
+	// ErrCanceled is matched by errors of Background.Shutdown calls which
+	// ctx was canceled by the caller rather than expired, to tell deliberate
+	// aborts from slow shutdowns. Such errors match ErrTimeout as well.
+	ErrCanceled = errors.New("shutdown canceled")
+
+	// ErrShutdownDuringStartup is the error returned by Background.Shutdown
+	// when the tree was not ready yet when the shutdown began and
+	// Options' ReportStartup is enabled.
+	ErrShutdownDuringStartup = errors.New("shutdown during startup")
+
+	// ErrTailDropped is matched by errors passed to Options' OnDroppedTail
+	// in strict mode.
+	ErrTailDropped = errors.New("shutdown tail was dropped without calling End")
+
 	// closedchan is a reusable closed channel.
 	closedchan = make(chan struct{})
+
+	// neverchan is a reusable channel that is never closed.
+	neverchan = make(chan struct{})
 )
 
 func init() {
 	close(closedchan)
 }
+
+// isClosed reports whether c is closed without blocking.
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,11 +1,23 @@
 package background
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"go.uber.org/goleak"
 )
 
 func TestParallel(t *testing.T) {
@@ -15,6 +27,13 @@ func TestParallel(t *testing.T) {
 		t.Run("GroupSuccessiveClose", GroupSuccessiveCloseTest)
 		t.Run("GroupError", GroupErrorTest)
 		t.Run("GroupNilChild", GroupNilChildTest)
+		t.Run("GroupSequentialClose", GroupSequentialCloseTest)
+		t.Run("GroupReverseClose", GroupReverseCloseTest)
+		t.Run("GroupLimitClose", GroupLimitCloseTest)
+		t.Run("GroupFlatten", GroupFlattenTest)
+		t.Run("GroupFlattenShared", GroupFlattenSharedTest)
+		t.Run("GroupDuplicateChild", GroupDuplicateChildTest)
+		t.Run("GroupUnhashableChild", GroupUnhashableChildTest)
 
 		// Shutdown
 		t.Run("ShutdownWrap", ShutdownWrapTest)
@@ -22,9 +41,27 @@ func TestParallel(t *testing.T) {
 		t.Run("ShutdownSuccessiveCall", ShutdownSuccessiveCallTest)
 		t.Run("ShutdownTimeout", ShutdownTimeoutTest)
 		t.Run("ShutdownUnclosed", ShutdownUnclosedTest)
+		t.Run("ShutdownAdd", ShutdownAddTest)
+		t.Run("ShutdownResult", ShutdownResultTest)
+		t.Run("ShutdownResultConcurrent", ShutdownResultConcurrentTest)
+		t.Run("ShutdownContext", ShutdownContextTest)
+		t.Run("ShutdownCloseInfo", ShutdownCloseInfoTest)
+		t.Run("ShutdownFinished", ShutdownFinishedTest)
+		t.Run("ShutdownFinishedLeaf", ShutdownFinishedLeafTest)
+		t.Run("ShutdownClosing", ShutdownClosingTest)
+		t.Run("ShutdownIsClosing", ShutdownIsClosingTest)
+		t.Run("ShutdownTimeoutError", ShutdownTimeoutErrorTest)
+		t.Run("ShutdownTimeoutCause", ShutdownTimeoutCauseTest)
 
 		// Wait
 		t.Run("Wait", WaitTest)
+		t.Run("WaitContext", WaitContextTest)
+		t.Run("WaitContextTimeout", WaitContextTimeoutTest)
+		t.Run("WaitPending", WaitPendingTest)
+		t.Run("WaitSafeNegativeCounter", WaitSafeNegativeCounterTest)
+		t.Run("WaitAny", WaitAnyTest)
+		t.Run("WaitAnySubtree", WaitAnySubtreeTest)
+		t.Run("WaitDrain", WaitDrainTest)
 
 		// Readiness
 		t.Run("ReadinessWrap", ReadinessWrapTest)
@@ -36,6 +73,12 @@ func TestParallel(t *testing.T) {
 		t.Run("ValueChildren", ValueChildrenTest)
 		t.Run("ValueNilPanic", ValueNilPanicTest)
 		t.Run("ValueComparablePanic", ValueComparablePanicTest)
+		t.Run("ValueBottomUp", ValueBottomUpTest)
+		t.Run("ValueScope", ValueScopeTest)
+		t.Run("ValueContext", ValueContextTest)
+		t.Run("ValueProvide", ValueProvideTest)
+		t.Run("ValueResolveInterface", ValueResolveInterfaceTest)
+		t.Run("ValueResolvePanic", ValueResolvePanicTest)
 
 		// Annotation
 		t.Run("AnnotationError", AnnotationErrorTest)
@@ -44,6 +87,7 @@ func TestParallel(t *testing.T) {
 		t.Run("AnnotationNilError", AnnotationNilErrorTest)
 		t.Run("AnnotationNilShutdownError", AnnotationNilShutdownErrorTest)
 		t.Run("AnnotationUnclosed", AnnotationUnclosedTest)
+		t.Run("AnnotationCaller", AnnotationCallerTest)
 
 		// Error
 		t.Run("Error", ErrorTest)
@@ -51,9 +95,15 @@ func TestParallel(t *testing.T) {
 		// Error group
 		t.Run("ErrorGroup", ErrorGroupTest)
 		t.Run("ErrorGroupErrorf", ErrorGroupErrorfTest)
+		t.Run("ErrorGroupCachedErr", ErrorGroupCachedErrTest)
+		t.Run("ErrorGroupUncachedErr", ErrorGroupUncachedErrTest)
+		t.Run("ErrorGroupTryError", ErrorGroupTryErrorTest)
+		t.Run("ErrorIntakePolicies", ErrorIntakePoliciesTest)
+		t.Run("ErrorIntakeErr", ErrorIntakeErrTest)
 
 		// Empty
 		t.Run("Empty", EmptyTest)
+		t.Run("EmptyClosing", EmptyClosingTest)
 
 		// Dependency
 		t.Run("DependencyShutdown", DependencyShutdownTest)
@@ -70,7 +120,403 @@ func TestParallel(t *testing.T) {
 		t.Run("DependencyValueParent", DependencyValueParentTest)
 		t.Run("DependencyValueChildren", DependencyValueChildrenTest)
 		t.Run("DependencyAnnotation", DependencyAnnotationTest)
+		t.Run("DependencyShutdownLevels", DependencyShutdownLevelsTest)
+		t.Run("DependencyShutdownLevelsAnnotated", DependencyShutdownLevelsAnnotatedTest)
+		t.Run("DependencyShutdownLevelsTimeout", DependencyShutdownLevelsTimeoutTest)
+		t.Run("DependencySequential", DependencySequentialTest)
+		t.Run("DependencyInterleave", DependencyInterleaveTest)
+
+		// Adapt
+		t.Run("AdaptShutdowner", AdaptShutdownerTest)
+		t.Run("AdaptEmpty", AdaptEmptyTest)
+
+		// Builder
+		t.Run("BuilderShutdown", BuilderShutdownTest)
+		t.Run("BuilderValidation", BuilderValidationTest)
+		t.Run("GraphBuild", GraphBuildTest)
+		t.Run("GraphValidation", GraphValidationTest)
+
+		// Subtree
+		t.Run("SubtreeShutdown", SubtreeShutdownTest)
+		t.Run("SubtreeNotFound", SubtreeNotFoundTest)
+
+		// Reload
+		t.Run("ReloadDependency", ReloadDependencyTest)
+		t.Run("ReloadAnnotatedError", ReloadAnnotatedErrorTest)
+		t.Run("ReloadTimeout", ReloadTimeoutTest)
+
+		// Pause
+		t.Run("PauseWrap", PauseWrapTest)
+		t.Run("PauseSuccessiveCall", PauseSuccessiveCallTest)
+
+		// Deadline
+		t.Run("DeadlineExceeded", DeadlineExceededTest)
+		t.Run("DeadlineShutdown", DeadlineShutdownTest)
+
+		// Options
+		t.Run("OptionsShutdownTimeout", OptionsShutdownTimeoutTest)
+		t.Run("OptionsCloseLimit", OptionsCloseLimitTest)
+		t.Run("OptionsStrict", OptionsStrictTest)
+		t.Run("OptionsDumpGoroutines", OptionsDumpGoroutinesTest)
+		t.Run("OptionsAbandon", OptionsAbandonTest)
+
+		// Result
+		t.Run("Result", ResultTest)
+		t.Run("ResultEmbedded", ResultEmbeddedTest)
+
+		// Progress
+		t.Run("Progress", ProgressTest)
+
+		// Trigger
+		t.Run("Trigger", TriggerTest)
+		t.Run("TriggerShutdown", TriggerShutdownTest)
+
+		// Hook
+		t.Run("HookOrder", HookOrderTest)
+		t.Run("HookError", HookErrorTest)
+		t.Run("HookTimeout", HookTimeoutTest)
+		t.Run("HookLeave", HookLeaveTest)
+
+		// Job
+		t.Run("Go", GoTest)
+		t.Run("GoN", GoNTest)
+		t.Run("GoNLimit", GoNLimitTest)
+		t.Run("GoEach", GoEachTest)
+		t.Run("GoEachInvalid", GoEachInvalidTest)
+		t.Run("ReadyThen", ReadyThenTest)
+		t.Run("ReadyThenShutdown", ReadyThenShutdownTest)
+		t.Run("ReadyThenLabels", ReadyThenLabelsTest)
+		t.Run("GoLabeled", GoLabeledTest)
+
+		// Warmup
+		t.Run("Warmup", WarmupTest)
+		t.Run("WarmupFailure", WarmupFailureTest)
+		t.Run("WarmupShutdown", WarmupShutdownTest)
+
+		// Startup retry
+		t.Run("StartupRetry", StartupRetryTest)
+		t.Run("StartupRetryFailure", StartupRetryFailureTest)
+		t.Run("StartupRetryShutdown", StartupRetryShutdownTest)
+		t.Run("StartupRetryControls", StartupRetryControlsTest)
+
+		// Readiness timeout
+		t.Run("ReadyTimeout", ReadyTimeoutTest)
+		t.Run("ReadyTimeoutReady", ReadyTimeoutReadyTest)
+		t.Run("ReadinessStatus", ReadinessStatusTest)
+
+		// Await
+		t.Run("AwaitError", AwaitErrorTest)
+		t.Run("AwaitSignal", AwaitSignalTest)
+		t.Run("AwaitContext", AwaitContextTest)
+		t.Run("AwaitShutdown", AwaitShutdownTest)
+
+		// Snapshot
+		t.Run("Snapshot", SnapshotTest)
+		t.Run("SnapshotPreFinished", SnapshotPreFinishedTest)
+		t.Run("SnapshotSources", SnapshotSourcesTest)
+
+		// Phase
+		t.Run("Phase", PhaseTest)
+
+		// App
+		t.Run("AppRun", AppRunTest)
+		t.Run("AppError", AppErrorTest)
+		t.Run("AppProbes", AppProbesTest)
+		t.Run("AppProbesDegraded", AppProbesDegradedTest)
+
+		// Health
+		t.Run("Health", HealthTest)
+
+		// Node
+		t.Run("Find", FindTest)
+
+		// Event log
+		t.Run("EventLog", EventLogTest)
+		t.Run("EventLogSize", EventLogSizeTest)
+
+		// Drain
+		t.Run("Drain", DrainTest)
+		t.Run("DrainClose", DrainCloseTest)
+
+		// Message loop
+		t.Run("MessageLoop", MessageLoopTest)
+		t.Run("MessageLoopHandleError", MessageLoopHandleErrorTest)
+
+		// Pool drain
+		t.Run("PoolDrain", PoolDrainTest)
+		t.Run("PoolDrainAbandon", PoolDrainAbandonTest)
+		t.Run("PoolDrainAbandonCloseError", PoolDrainAbandonCloseErrorTest)
+
+		// Topic
+		t.Run("Topic", TopicTest)
+
+		// Activity
+		t.Run("IdleSince", IdleSinceTest)
+		t.Run("IdleTimeout", IdleTimeoutTest)
+
+		// Deadman
+		t.Run("Deadman", DeadmanTest)
+		t.Run("DeadmanShutdown", DeadmanShutdownTest)
+		t.Run("DeadmanApp", DeadmanAppTest)
+
+		// Plan
+		t.Run("Plan", PlanTest)
+
+		// Readiness gate
+		t.Run("RequireReady", RequireReadyTest)
+
+		// Checkout
+		t.Run("Checkout", CheckoutTest)
+		t.Run("CheckoutTimeout", CheckoutTimeoutTest)
+
+		// Shutdown during startup
+		t.Run("StartupShutdown", StartupShutdownTest)
+		t.Run("StartupShutdownReport", StartupShutdownReportTest)
+		t.Run("StartupShutdownReportTimeout", StartupShutdownReportTimeoutTest)
+		t.Run("StartupShutdownReportTimeoutAnnotated", StartupShutdownReportTimeoutAnnotatedTest)
+
+		// Startup
+		t.Run("Startup", StartupTest)
+		t.Run("StartupAbort", StartupAbortTest)
+
+		// Teardown stack
+		t.Run("TeardownStack", TeardownStackTest)
+		t.Run("TeardownStackClosed", TeardownStackClosedTest)
+		t.Run("TeardownStackControls", TeardownStackControlsTest)
+
+		// All errors
+		t.Run("MergeAllErrors", MergeAllErrorsTest)
+
+		// Error matching
+		t.Run("ErrorsAs", ErrorsAsTest)
+		t.Run("ErrorsAsOwnError", ErrorsAsOwnErrorTest)
+		t.Run("FirstError", FirstErrorTest)
+
+		// Shutdown report
+		t.Run("Report", ReportTest)
+
+		// Wait watchdog
+		t.Run("WaitWithWatchdog", WaitWithWatchdogTest)
+
+		// Shutdown delay
+		t.Run("ShutdownDelay", ShutdownDelayTest)
+		t.Run("ShutdownDelayTimeout", ShutdownDelayTimeoutTest)
+
+		// Serve
+		t.Run("Serve", ServeTest)
+		t.Run("ServeFailure", ServeFailureTest)
+
+		// Keyed error group
+		t.Run("KeyedErrorGroup", KeyedErrorGroupTest)
+
+		// Tail close
+		t.Run("TailClose", TailCloseTest)
+		t.Run("TailCloseChildren", TailCloseChildrenTest)
+
+		// Feature-flagged components
+		t.Run("If", IfTest)
+
+		// Lazy components
+		t.Run("Lazy", LazyTest)
+		t.Run("LazyNeverStarted", LazyNeverStartedTest)
+		t.Run("LazyControls", LazyControlsTest)
+		t.Run("LazyFailure", LazyFailureTest)
+	})
+}
+
+func TestGoroutineLeak(t *testing.T) {
+	current := goleak.IgnoreCurrent()
+
+	var (
+		bgs   []Background
+		tails []ShutdownTail
+	)
+
+	for i := 0; i < 100; i++ {
+		bg1, _ := WithReadiness()
+		bg2, tail := WithShutdown()
+		bg3, _ := WithWait(bg1)
+
+		bg := WithAnnotation("test", Merge(bg2, bg3)).DependsOn(bg1)
+		bg.Ready()
+
+		bgs = append(bgs, bg)
+		tails = append(tails, tail)
+	}
+
+	// trees that are never ready nor shut down must not hold any goroutines
+	if err := goleak.Find(current); err != nil {
+		t.Error(err)
+	}
+
+	for _, bg := range bgs {
+		bg.Wait()
+	}
+
+	// goleak.Find runs the garbage collector, so the tails are kept
+	// until here not to be reported as dropped in strict mode
+	for _, tail := range tails {
+		tail.End()
+	}
+}
+
+func TestWaitContextGoroutineLeak(t *testing.T) {
+	current := goleak.IgnoreCurrent()
+
+	bg, tail := WithWait()
+	tail.Add(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 100; i++ {
+		if err := bg.WaitContext(ctx); err != context.Canceled {
+			t.Fatalf("wrong error, want '%v', have '%v'", context.Canceled, err)
+		}
+
+		if _, err := WaitAnyContext(ctx, bg); err != context.Canceled {
+			t.Fatalf("wrong error, want '%v', have '%v'", context.Canceled, err)
+		}
+	}
+
+	// the waits that gave up must not leave any goroutines behind
+	if err := goleak.Find(current); err != nil {
+		t.Error(err)
+	}
+
+	tail.Done()
+
+	if err := bg.WaitContext(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestStackTraces is not parallel as it toggles capturing stack traces
+// for the whole package.
+func TestStackTraces(t *testing.T) {
+	SetStackTraces(true)
+	defer SetStackTraces(false)
+
+	err := errors.New("test")
+
+	bg1, tail1 := WithErrorGroup()
+	tail1.Error(err)
+
+	var stackErr *StackError
+	if !errors.As(bg1.Err(), &stackErr) || !errors.Is(bg1.Err(), err) {
+		t.Fatalf("error is not wrapped in StackError: %v", bg1.Err())
+	}
+
+	if have := fmt.Sprintf("%+v", bg1.Err()); !strings.Contains(have, "TestStackTraces") {
+		t.Errorf("error stack trace doesn't contain the caller: %s", have)
+	}
+
+	if have := fmt.Sprintf("%v", bg1.Err()); have != err.Error() {
+		t.Errorf("want error message '%s', have '%s'", err, have)
+	}
+
+	bg2, _ := WithShutdown()
+
+	// the job of bg2 that blocks the shutdown
+	block := make(chan struct{})
+	defer close(block)
+
+	go func() { <-block }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	var timeoutErr *TimeoutError
+	if !errors.As(WithOptions(Options{DumpGoroutines: true}, bg2).Shutdown(ctx), &timeoutErr) {
+		t.Fatalf(errNotClosed)
+	}
+
+	if have := fmt.Sprintf("%+v", timeoutErr); !strings.Contains(have, "TestStackTraces") {
+		t.Errorf("timeout stack trace doesn't contain the creator: %s", have)
+	}
+
+	dump := string(timeoutErr.Goroutines)
+	if !strings.Contains(dump, "created by github.com/lefelys/background.TestStackTraces") ||
+		strings.Contains(dump, "created by testing.") {
+		t.Errorf("goroutines are not filtered by the creator:\n%s", dump)
+	}
+}
+
+// TestShutdownOrExit is not parallel as it replaces the package's exit.
+func TestShutdownOrExit(t *testing.T) {
+	var code = -1
+
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("stuck", bg1)
+		out strings.Builder
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	err := ShutdownOrExit(context.Background(), bg2, Escalation{
+		Timeout:  time.Millisecond,
+		Output:   &out,
+		ExitCode: 3,
 	})
+
+	if err != nil || code != 3 {
+		t.Errorf("want exit with code 3, have code %d and error '%v'", code, err)
+	}
+
+	for _, want := range []string{"stuck: *background.shutdownBackground, closing", "goroutine "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diagnostics don't contain '%s':\n%s", want, out.String())
+		}
+	}
+
+	code = -1
+
+	if err := ShutdownOrExit(context.Background(), Empty(), Escalation{Timeout: failTimeout}); err != nil || code != -1 {
+		t.Errorf("unexpected exit with code %d and error '%v'", code, err)
+	}
+
+	// zero Timeout means no escalation
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := ShutdownOrExit(ctx, withShutdown(), Escalation{}); !errors.Is(err, ErrTimeout) || code != -1 {
+		t.Errorf("unexpected exit with code %d and error '%v'", code, err)
+	}
+}
+
+// TestDroppedErrors is not parallel as it changes the package defaults.
+func TestDroppedErrors(t *testing.T) {
+	var dropped []error
+
+	SetDefaults(Options{OnDroppedError: func(err error) { dropped = append(dropped, err) }})
+	defer SetDefaults(Options{})
+
+	var (
+		err1 = errors.New("test1")
+		err2 = errors.New("test2")
+		err3 = errors.New("test3")
+	)
+
+	bg, tail := WithErrorGroup()
+
+	tail.Error(err1)
+	tail.Error(err2)
+	tail.Errorf("wrapped: %w", err2)
+	tail.(ErrTryTail).TryError(err3)
+
+	if !errors.Is(bg.Err(), err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, bg.Err())
+	}
+
+	want := "[test2 wrapped: test2]"
+	if have := fmt.Sprint(dropped); have != want {
+		t.Errorf("wrong dropped errors, want %s, have %s", want, have)
+	}
 }
 
 const (
@@ -101,6 +547,70 @@ func runShutdownable(tail ShutdownTail) (okDone chan struct{}) {
 	return
 }
 
+// endTails calls End on tails of Backgrounds that are never shut down,
+// so they aren't reported as dropped in strict mode.
+func endTails(tails ...ShutdownTail) {
+	for _, tail := range tails {
+		tail.End()
+	}
+}
+
+// controlled is a component that reports the Reload, Pause and Resume
+// calls reaching it.
+type controlled struct {
+	Background
+
+	pause    *pauseBackground
+	reloaded chan ReloadTail
+}
+
+func newControlled() *controlled {
+	var (
+		pause    = withPause()
+		bg, tail = WithReload(pause)
+		reloaded = make(chan ReloadTail, 1)
+	)
+
+	runReloadable(tail, nil, reloaded)
+
+	return &controlled{Background: bg, pause: pause, reloaded: reloaded}
+}
+
+// checkControls checks whether bg's Reload, Pause and Resume calls reach c.
+func (c *controlled) checkControls(t *testing.T, bg Background, reached bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Reload(ctx); err != nil {
+		t.Errorf("unexpected reload error: %v", err)
+	}
+
+	select {
+	case <-c.reloaded:
+		if !reached {
+			t.Error("reload reached the component")
+		}
+	default:
+		if reached {
+			t.Error("reload didn't reach the component")
+		}
+	}
+
+	bg.Pause()
+
+	if isClosed(c.pause.Paused()) != reached {
+		t.Errorf("wrong pause of the component, want %t", reached)
+	}
+
+	bg.Resume()
+
+	if !isClosed(c.pause.Resumed()) {
+		t.Error("component is not resumed")
+	}
+}
+
 func runWaitable(tail WaitTail) (okWait chan struct{}) {
 	okWait = make(chan struct{})
 
@@ -114,6 +624,21 @@ func runWaitable(tail WaitTail) (okWait chan struct{}) {
 	return
 }
 
+// errText returns the message of err without the timeout cause added
+// in strict mode, so the assertions hold with and without it
+func errText(err error) string {
+	msg := err.Error()
+
+	for _, cause := range []string{
+		": shutdown tail's End was never called",
+		": shutdown tail's End was consumed, but Done was never called",
+	} {
+		msg = strings.TrimSuffix(msg, cause)
+	}
+
+	return msg
+}
+
 // checks any of passed channels are closed
 func hasClosed(cc ...<-chan struct{}) bool {
 	for _, c := range cc {
@@ -163,7 +688,7 @@ func GroupCloseTest(t *testing.T) {
 		bg3 = merge(bg1, bg2)
 	)
 
-	go bg3.close()
+	go bg3.close(context.Background())
 	closeChanAndPropagate(okDone1, okDone2)
 
 	switch {
@@ -192,8 +717,8 @@ func GroupSuccessiveCloseTest(t *testing.T) {
 	)
 
 	closeChanAndPropagate(okDone1)
-	bg3.close()
-	bg3.close()
+	bg3.close(context.Background())
+	bg3.close(context.Background())
 }
 
 func GroupErrorTest(t *testing.T) {
@@ -236,38 +761,26 @@ func GroupNilChildTest(t *testing.T) {
 	}
 }
 
-// Shutdown
-
-func ShutdownWrapTest(t *testing.T) {
+func GroupSequentialCloseTest(t *testing.T) {
 	t.Parallel()
 
 	var (
 		bg1 = withShutdown()
-		bg2 = withShutdown(bg1)
-		bg3 = withShutdown(bg2)
+		bg2 = withShutdown()
+		bg3 = mergeOrdered(closeSequential, bg1, nil, bg2)
 
 		okDone1 = runShutdownable(bg1)
 		okDone2 = runShutdownable(bg2)
-		okDone3 = runShutdownable(bg3)
 	)
 
-	if hasClosed(
-		bg1.end, bg2.end, bg3.end,
-		bg1.done, bg2.done, bg3.done,
-	) {
-		t.Error(errInitClosed)
-	}
-
-	go bg3.close()
+	go bg3.close(context.Background())
 	time.Sleep(failTimeout)
 
 	switch {
 	case hasNotClosed(bg1.end):
 		t.Error(errNotClosed)
-	case hasClosed(bg2.end, bg3.end):
+	case hasClosed(bg2.end):
 		t.Error(errClosed)
-	case hasClosed(bg1.done, bg2.done, bg3.done):
-		t.Error(errFinished)
 	}
 
 	closeChanAndPropagate(okDone1)
@@ -275,1156 +788,6442 @@ func ShutdownWrapTest(t *testing.T) {
 	switch {
 	case hasNotClosed(bg2.end):
 		t.Error(errNotClosed)
-	case hasNotClosed(bg1.done):
-		t.Error(errNotFinished)
-	case hasClosed(bg3.end):
-		t.Error(errClosed)
-	case hasClosed(bg2.done, bg3.done):
+	case hasClosed(bg3.finished):
 		t.Error(errFinished)
 	}
 
 	closeChanAndPropagate(okDone2)
 
-	switch {
-	case hasNotClosed(bg2.done):
-		t.Error(errNotFinished)
-	case hasNotClosed(bg3.end):
-		t.Error(errNotClosed)
-	case hasClosed(bg3.done):
-		t.Error(errFinished)
-	}
-
-	closeChanAndPropagate(okDone3)
-
-	// bg3 must be done
-	if hasNotClosed(bg3.done) {
+	if hasNotClosed(bg3.finished) {
 		t.Error(errNotFinished)
 	}
 }
 
-func ShutdownSuccessiveDoneTest(t *testing.T) {
+func GroupReverseCloseTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("successive Done call caused panic")
-		}
-	}()
-
 	var (
 		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = mergeOrdered(closeReverse, bg1, bg2)
 
 		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
 	)
 
-	go bg1.close()
+	go bg3.close(context.Background())
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg1.end):
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone2)
+
+	if hasNotClosed(bg1.end) {
+		t.Error(errNotClosed)
+	}
 
 	closeChanAndPropagate(okDone1)
-	bg1.Done()
+
+	if hasNotClosed(bg3.finished) {
+		t.Error(errNotFinished)
+	}
 }
 
-func ShutdownSuccessiveCallTest(t *testing.T) {
+func GroupLimitCloseTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("successive Shutdown call caused panic")
-		}
-	}()
-
 	var (
 		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = mergeWithLimit(2, bg1, bg2, bg3)
 
 		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
 	)
 
-	go bg1.close()
-	closeChanAndPropagate(okDone1)
+	go bg4.close(context.Background())
+	time.Sleep(failTimeout)
 
-	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
-	defer cancel()
+	switch {
+	case hasNotClosed(bg1.end, bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg3.end):
+		t.Error(errClosed)
+	}
 
-	err := bg1.Shutdown(ctx)
-	if err != nil {
-		t.Errorf(errTimeout)
+	closeChanAndPropagate(okDone2)
+
+	if hasNotClosed(bg3.end) {
+		t.Error(errNotClosed)
 	}
 
-	err = bg1.Shutdown(ctx)
-	if err != nil {
-		t.Errorf(errTimeout)
+	closeChanAndPropagate(okDone1, okDone3)
+
+	if hasNotClosed(bg4.finished) {
+		t.Error(errNotFinished)
 	}
 }
 
-func ShutdownTimeoutTest(t *testing.T) {
+func GroupFlattenTest(t *testing.T) {
 	t.Parallel()
 
-	bg := withShutdown()
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = withShutdown()
 
-	// blocked finish
-	_ = runShutdownable(bg)
+		bg5 = merge(merge(bg1, merge(bg2)), bg3)
+		bg6 = mergeOrdered(closeSequential, bg5, bg4)
+		bg7 = merge(bg6, bg4)
+	)
 
-	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
-	defer cancel()
+	defer endTails(bg1, bg2, bg3, bg4)
 
-	err := bg.Shutdown(ctx)
-	if !errors.Is(err, ErrTimeout) {
-		t.Errorf("blocked shutdown didn't timeout")
+	if len(bg5.backgrounds) != 3 {
+		t.Errorf("wrong number of flattened group children: want 3, have %d", len(bg5.backgrounds))
+	}
+
+	if bg5.backgrounds[0] != bg1 || bg5.backgrounds[1] != bg2 || bg5.backgrounds[2] != bg3 {
+		t.Errorf("flattened group children are out of order")
+	}
+
+	if len(bg6.backgrounds) != 2 {
+		t.Errorf("sequential group children are flattened")
+	}
+
+	if len(bg7.backgrounds) != 2 {
+		t.Errorf("sequential group is flattened")
 	}
 }
 
-func ShutdownUnclosedTest(t *testing.T) {
+func GroupFlattenSharedTest(t *testing.T) {
 	t.Parallel()
 
 	var (
 		bg1 = withShutdown()
-		bg2 = withShutdown(bg1)
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = Merge(bg1, bg2)
+		bg5 = Merge(bg4, bg3)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
 	)
 
-	okDone2 := runShutdownable(bg2)
-	okDone1 := runShutdownable(bg1)
+	if len(bg5.(*group).backgrounds) != 2 {
+		t.Errorf("group returned by Merge is flattened")
+	}
 
-	closeChanAndPropagate(okDone1, okDone2)
+	close(okDone1)
+	close(okDone2)
+	close(okDone3)
 
 	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
 	defer cancel()
 
-	err := bg2.Shutdown(ctx)
-	if err != nil {
+	if err := bg5.Shutdown(ctx); err != nil {
 		t.Errorf(errTimeout)
 	}
 
-	if err := bg1.cause(); err != nil {
-		t.Errorf(errNotFinished)
-	}
-
-	if err := bg2.cause(); err != nil {
-		t.Errorf(errNotFinished)
+	if !bg4.IsClosing() || hasNotClosed(bg4.Finished()) {
+		t.Error("merged group returned by Merge didn't close")
 	}
 }
 
-// Wait
+func GroupDuplicateChildTest(t *testing.T) {
+	t.Parallel()
 
-func WaitTest(t *testing.T) {
+	var (
+		bg1 = withShutdown()
+		bg2 = merge(bg1, bg1)
+		bg3 = merge(withAnnotation("a", bg1), withAnnotation("b", bg1), bg2)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	if len(bg2.backgrounds) != 1 {
+		t.Errorf("wrong number of group children: want 1, have %d", len(bg2.backgrounds))
+	}
+
+	if len(bg3.backgrounds) != 3 {
+		t.Errorf("wrong number of group children: want 3, have %d", len(bg3.backgrounds))
+	}
+
+	go bg3.close(context.Background())
+	closeChanAndPropagate(okDone1)
+
+	if hasNotClosed(bg1.done, bg3.finished) {
+		t.Error(errNotFinished)
+	}
+}
+
+// taggedBackground is a Background of uncomparable dynamic type.
+type taggedBackground struct {
+	Background
+
+	tags []string
+}
+
+func GroupUnhashableChildTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withWait()
-		bg2 = withWait(bg1)
-		bg3 = withWait(bg2)
+		bg1, tail = WithShutdown()
+		bg2       = taggedBackground{Background: bg1, tags: []string{"a"}}
+		bg3       = Merge(bg2, bg2)
 
-		okDone1 = runWaitable(bg1)
-		okDone2 = runWaitable(bg2)
-		okDone3 = runWaitable(bg3)
+		okDone1 = runShutdownable(tail)
 	)
 
-	done := make(chan struct{})
+	_ = Snapshot(bg3)
+	_ = Plan(bg3)
+	_ = Report(bg3)
 
-	go func() {
-		bg3.Wait()
-		close(done)
-	}()
+	go closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg3.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+// Shutdown
+
+func ShutdownWrapTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown(bg1)
+		bg3 = withShutdown(bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	if hasClosed(
+		bg1.end, bg2.end, bg3.end,
+		bg1.done, bg2.done, bg3.done,
+	) {
+		t.Error(errInitClosed)
+	}
 
+	go bg3.close(context.Background())
 	time.Sleep(failTimeout)
 
-	if hasClosed(done) {
-		t.Error(errNotWaited)
+	switch {
+	case hasNotClosed(bg1.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg2.end, bg3.end):
+		t.Error(errClosed)
+	case hasClosed(bg1.done, bg2.done, bg3.done):
+		t.Error(errFinished)
 	}
 
-	closeChanAndPropagate(okDone1, okDone2)
+	closeChanAndPropagate(okDone1)
 
-	if hasClosed(done) {
-		t.Error(errNotWaited)
+	switch {
+	case hasNotClosed(bg2.end):
+		t.Error(errNotClosed)
+	case hasNotClosed(bg1.done):
+		t.Error(errNotFinished)
+	case hasClosed(bg3.end):
+		t.Error(errClosed)
+	case hasClosed(bg2.done, bg3.done):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone2)
+
+	switch {
+	case hasNotClosed(bg2.done):
+		t.Error(errNotFinished)
+	case hasNotClosed(bg3.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg3.done):
+		t.Error(errFinished)
 	}
 
 	closeChanAndPropagate(okDone3)
 
-	time.Sleep(failTimeout)
+	// bg3 must be done
+	if hasNotClosed(bg3.done) {
+		t.Error(errNotFinished)
+	}
+}
 
-	if hasNotClosed(done) {
-		t.Error(errFinishWaiting)
+func ShutdownSuccessiveDoneTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive Done call caused panic")
+		}
+	}()
+
+	var (
+		bg1 = withShutdown()
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	go bg1.close(context.Background())
+
+	closeChanAndPropagate(okDone1)
+	bg1.Done()
+}
+
+func ShutdownSuccessiveCallTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive Shutdown call caused panic")
+		}
+	}()
+
+	var (
+		bg1 = withShutdown()
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	go bg1.close(context.Background())
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg1.Shutdown(ctx)
+	if err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	err = bg1.Shutdown(ctx)
+	if err != nil {
+		t.Errorf(errTimeout)
 	}
 }
 
-// Readiness
+func ShutdownTimeoutTest(t *testing.T) {
+	t.Parallel()
 
-func ReadinessWrapTest(t *testing.T) {
+	bg := withShutdown()
+
+	// blocked finish
+	_ = runShutdownable(bg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+}
+
+func ShutdownUnclosedTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withReadiness()
-		bg2 = withReadiness(bg1)
-		bg3 = withReadiness(bg2)
+		bg1 = withShutdown()
+		bg2 = withShutdown(bg1)
 	)
 
-	if hasClosed(
-		bg1.ready, bg2.ready, bg3.ready,
-	) {
-		t.Error(errInitReady)
+	okDone2 := runShutdownable(bg2)
+	okDone1 := runShutdownable(bg1)
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg2.Shutdown(ctx)
+	if err != nil {
+		t.Errorf(errTimeout)
 	}
 
-	bg1ReadyC := bg1.Ready()
-	bg2ReadyC := bg2.Ready()
-	bg3ReadyC := bg3.Ready()
+	if err := bg1.cause(context.Background()); err != nil {
+		t.Errorf(errNotFinished)
+	}
 
-	bg3.Ok()
+	if err := bg2.cause(context.Background()); err != nil {
+		t.Errorf(errNotFinished)
+	}
+}
+
+func ShutdownAddTest(t *testing.T) {
+	t.Parallel()
+
+	bg := withShutdown()
+	bg.Add(2)
+
+	defer endTails(bg)
 
+	go bg.close(context.Background())
 	time.Sleep(failTimeout)
 
+	for i := 0; i < 2; i++ {
+		bg.Done()
+
+		if hasClosed(bg.done) {
+			t.Error(errFinished)
+		}
+	}
+
+	bg.Done()
+
+	if hasNotClosed(bg.done) {
+		t.Error(errNotFinished)
+	}
+}
+
+func ShutdownResultTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	if _, ok := bg2.ShutdownResult(); ok {
+		t.Errorf("shutdown result is stored before Shutdown call")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg2.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+
+	// the result of the timed out shutdown is not stored
+	if _, ok := bg2.ShutdownResult(); ok {
+		t.Errorf("shutdown result is stored before the shutdown is complete")
+	}
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel = context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Errorf("wrong shutdown result, want '<nil>', have '%v'", err)
+	}
+
+	err, ok := bg2.ShutdownResult()
+
 	switch {
-	case hasNotClosed(bg3.ready):
-		t.Error(errNotReady)
-	case hasClosed(bg1.ready, bg2.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC):
-		t.Error(errReady)
+	case !ok:
+		t.Errorf("shutdown result is not stored")
+	case err != nil:
+		t.Errorf("wrong shutdown result, want '<nil>', have '%v'", err)
 	}
 
-	bg2.Ok()
-	time.Sleep(failTimeout)
+	// the stored result is returned regardless of ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Errorf("wrong shutdown result, want '<nil>', have '%v'", err)
+	}
+}
+
+func ShutdownResultConcurrentTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1)
+
+		okDone1 = runShutdownable(bg1)
+		first   = make(chan error, 1)
+	)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*failTimeout)
+		defer cancel()
+
+		first <- bg2.Shutdown(ctx)
+	}()
+
+	<-bg1.End()
+
+	// the call waiting for the running one returns when its ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := bg2.Shutdown(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrTimeout, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > failTimeout {
+		t.Errorf("waiting shutdown ignored its ctx: returned after %v", elapsed)
+	}
+
+	closeChanAndPropagate(okDone1)
+
+	select {
+	case err := <-first:
+		if err != nil {
+			t.Errorf("wrong shutdown result, want '<nil>', have '%v'", err)
+		}
+	case <-time.After(failTimeout):
+		t.Fatal(errTimeout)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Errorf("wrong shutdown result, want '<nil>', have '%v'", err)
+	}
+}
+
+func ShutdownContextTest(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown(bg1)
+		bg3 = withAnnotation("test", bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	if deadline, ok := bg1.Context().Deadline(); ok {
+		t.Errorf("unexpected context deadline before shutdown: %v", deadline)
+	}
+
+	close(okDone1)
+	close(okDone2)
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), failTimeout)
+	defer cancel()
+
+	if err := bg3.Shutdown(ctx); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	want, _ := ctx.Deadline()
+
+	for _, bg := range []*shutdownBackground{bg1, bg2} {
+		if deadline, _ := bg.Context().Deadline(); !deadline.Equal(want) {
+			t.Errorf("wrong context deadline, want %v, have %v", want, deadline)
+		}
+
+		if value := bg.Context().Value(key{}); value != "value" {
+			t.Errorf("wrong context value, want 'value', have '%v'", value)
+		}
+	}
+}
+
+func ShutdownCloseInfoTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		reason = errors.New("reason")
+
+		bg1 = withShutdown()
+		bg2 = withShutdown(bg1)
+		bg3 = withAnnotation("test", bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	if info := bg1.CloseInfo(); info != (CloseInfo{}) {
+		t.Errorf("unexpected close info before shutdown: %+v", info)
+	}
+
+	close(okDone1)
+	close(okDone2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg3.Shutdown(WithShutdownReason(ctx, reason)); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	want, _ := ctx.Deadline()
+
+	for _, bg := range []*shutdownBackground{bg1, bg2} {
+		info := bg.CloseInfo()
+
+		if info.Initiator != bg3 {
+			t.Errorf("wrong close initiator, want %p, have %p", bg3, info.Initiator)
+		}
+
+		if info.Reason != reason {
+			t.Errorf("wrong close reason, want '%v', have '%v'", reason, info.Reason)
+		}
+
+		if !info.Deadline.Equal(want) {
+			t.Errorf("wrong close deadline, want %v, have %v", want, info.Deadline)
+		}
+	}
+}
+
+func ShutdownFinishedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1)
+		bg3 = merge(bg2).DependsOn(Empty())
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	if hasClosed(bg1.Finished(), bg2.Finished(), bg3.Finished()) {
+		t.Error(errFinished)
+	}
+
+	if hasNotClosed(Empty().Finished()) {
+		t.Error(errNotFinished)
+	}
+
+	go bg3.Shutdown(context.Background())
+
+	close(okDone1)
+
+	select {
+	case <-bg2.Finished():
+	case <-time.After(failTimeout):
+		t.Error(errNotFinished)
+	}
+
+	select {
+	case <-bg3.Finished():
+	case <-time.After(failTimeout):
+		t.Error(errNotFinished)
+	}
+}
+
+func ShutdownFinishedLeafTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, _ = WithReadiness()
+		bg2    = WithValue("key", "value")
+		bg3    = WithError(errors.New("fail"))
+	)
+
+	for _, bg := range []Background{bg1, bg2, bg3} {
+		if hasClosed(bg.Finished()) || bg.IsFinished() {
+			t.Errorf("%T is finished before shutdown", bg)
+		}
+
+		if err := bg.Shutdown(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if hasNotClosed(bg.Finished()) || !bg.IsFinished() || !bg.IsClosing() {
+			t.Errorf("%T is not finished after shutdown", bg)
+		}
+	}
+}
+
+func ShutdownClosingTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = Adapt(struct{}{})
+		bg3 = withHook(func(ctx context.Context) error { return nil }, hookOnFinished)
+		bg4 = withShutdown(bg1, bg2, bg3)
+		bg5 = merge(bg4).DependsOn(Empty())
+
+		okDone1 = runShutdownable(bg1)
+		okDone4 = runShutdownable(bg4)
+	)
+
+	if hasClosed(bg1.Closing(), bg3.Closing(), bg4.Closing(), bg5.Closing()) {
+		t.Error(errClosed)
+	}
+
+	// components that can't be shut down are considered closed
+	if hasNotClosed(bg2.Closing()) {
+		t.Error(errNotClosed)
+	}
+
+	go bg5.Shutdown(context.Background())
+	time.Sleep(failTimeout / 10)
+
+	// the closing began, but bg1 blocks the finish
+	if hasNotClosed(bg1.Closing(), bg2.Closing(), bg3.Closing(), bg4.Closing(), bg5.Closing()) {
+		t.Error(errNotClosed)
+	}
+
+	if hasClosed(bg5.Finished()) {
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone1, okDone4)
+}
+
+func ShutdownIsClosingTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1).DependsOn(Empty())
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	for _, bg := range []Background{bg1, bg2} {
+		if bg.IsClosing() || bg.IsFinished() {
+			t.Error(errClosed)
+		}
+	}
+
+	go bg2.close(context.Background())
+	<-bg1.End()
+
+	for _, bg := range []Background{bg1, bg2} {
+		if !bg.IsClosing() {
+			t.Error(errNotClosed)
+		}
+
+		if bg.IsFinished() {
+			t.Error(errFinished)
+		}
+	}
+
+	close(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	for _, bg := range []Background{bg1, bg2, Empty()} {
+		if !bg.IsFinished() {
+			t.Error(errNotFinished)
+		}
+	}
+}
+
+func ShutdownTimeoutErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1)
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg2.Shutdown(ctx)
+
+	var timeoutErr *TimeoutError
+
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("wrong error type %T", err)
+		return
+	}
+
+	deadline, _ := ctx.Deadline()
+
+	switch {
+	case !timeoutErr.Deadline.Equal(deadline):
+		t.Errorf("wrong deadline, want %v, have %v", deadline, timeoutErr.Deadline)
+	case timeoutErr.Exceeded < 0:
+		t.Errorf("negative exceeded time %v", timeoutErr.Exceeded)
+	case timeoutErr.Closing < failTimeout/2:
+		t.Errorf("wrong closing time %v", timeoutErr.Closing)
+	}
+}
+
+func ShutdownTimeoutCauseTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		cause = errors.New("aborted by operator")
+		bg1   = withShutdown()
+		bg2   = withAnnotation("test", bg1)
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+
+	err := bg2.Shutdown(ctx)
+
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrCanceled) || !errors.Is(err, cause) {
+		t.Errorf("error doesn't match ErrTimeout, ErrCanceled and the cause: %v", err)
+	}
+
+	if want := "test: shutdown canceled: aborted by operator"; err == nil || errText(err) != want {
+		t.Errorf("wrong error message, want '%s', have '%v'", want, err)
+	}
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Err != context.Canceled {
+		t.Errorf("wrong context error in %v", err)
+	}
+
+	ctx, cancel2 := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel2()
+
+	err = withShutdown().Shutdown(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, ErrCanceled) {
+		t.Errorf("error doesn't match context.DeadlineExceeded only: %v", err)
+	}
+
+	if err == nil || errText(err) != ErrTimeout.Error() {
+		t.Errorf("wrong error message, want '%v', have '%v'", ErrTimeout, err)
+	}
+}
+
+// Wait
+
+func WaitTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait(bg1)
+		bg3 = withWait(bg2)
+
+		okDone1 = runWaitable(bg1)
+		okDone2 = runWaitable(bg2)
+		okDone3 = runWaitable(bg3)
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		bg3.Wait()
+		close(done)
+	}()
+
+	time.Sleep(failTimeout)
+
+	if hasClosed(done) {
+		t.Error(errNotWaited)
+	}
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	if hasClosed(done) {
+		t.Error(errNotWaited)
+	}
+
+	closeChanAndPropagate(okDone3)
+
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(done) {
+		t.Error(errFinishWaiting)
+	}
+}
+
+func WaitContextTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait(bg1)
+
+		okDone1 = runWaitable(bg1)
+		okDone2 = runWaitable(bg2)
+	)
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.WaitContext(ctx); err != nil {
+		t.Errorf("unexpected wait error: %v", err)
+	}
+}
+
+func WaitAnyTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait()
+
+		okDone1 = runWaitable(bg1)
+		okDone2 = runWaitable(bg2)
+	)
+
+	defer close(okDone1)
+
+	closeChanAndPropagate(okDone2)
+
+	if i := WaitAny(bg1, bg2); i != 1 {
+		t.Errorf("wrong drained Background index, want 1, have %d", i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if i, err := WaitAnyContext(ctx, bg1); i != -1 || err != context.DeadlineExceeded {
+		t.Errorf("want -1 and context.DeadlineExceeded, have %d and '%v'", i, err)
+	}
+}
+
+func WaitAnySubtreeTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait()
+		bg3 = merge(withAnnotation("a", bg1), withAnnotation("b", bg2))
+
+		okDone1 = runWaitable(bg1)
+		okDone2 = runWaitable(bg2)
+	)
+
+	defer close(okDone2)
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if name, err := WaitAnySubtree(ctx, bg3, "b", "a"); name != "a" || err != nil {
+		t.Errorf("wrong drained subtree, want 'a', have '%s' and '%v'", name, err)
+	}
+
+	if _, err := WaitAnySubtree(ctx, bg3, "c"); !errors.Is(err, ErrSubtreeNotFound) {
+		t.Errorf("want ErrSubtreeNotFound, have '%v'", err)
+	}
+}
+
+func WaitDrainTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withShutdown()
+		bg3 = merge(bg1, bg2)
+
+		okWait1 = runWaitable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := Drain(ctx, bg3); !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(err.Error(), "wait: ") {
+		t.Errorf("want wait phase error, have '%v'", err)
+	}
+
+	if hasClosed(bg2.end) {
+		t.Error(errClosed)
+	}
+
+	close(okWait1)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	// blocked finish
+	if err := Drain(ctx, bg3); !errors.Is(err, ErrTimeout) || !strings.HasPrefix(err.Error(), "shutdown: ") {
+		t.Errorf("want shutdown phase error, have '%v'", err)
+	}
+
+	close(okDone2)
+}
+
+func WaitContextTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	var (
+		bg1 = withWait()
+		bg2 = withAnnotation(annotation, bg1)
+		bg3 = withWait(bg2)
+
+		okDone1 = runWaitable(bg1)
+		okDone3 = runWaitable(bg3)
+	)
+
+	defer close(okDone1)
+
+	closeChanAndPropagate(okDone3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg3.WaitContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("blocked wait didn't timeout")
+		return
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, context.DeadlineExceeded.Error())
+
+	if err.Error() != wantErrStr {
+		t.Errorf("wait error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	}
+}
+
+func WaitPendingTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait(bg1)
+	)
+
+	bg1.Add(2)
+	bg2.Add(1)
+
+	if pending := bg1.Pending(); pending != 2 {
+		t.Errorf("wrong pending counter, want 2, have %d", pending)
+	}
+
+	if pending := bg2.Pending(); pending != 1 {
+		t.Errorf("wrong pending counter, want 1, have %d", pending)
+	}
+
+	bg1.Done()
+	bg1.Done()
+
+	if pending := bg1.Pending(); pending != 0 {
+		t.Errorf("wrong pending counter, want 0, have %d", pending)
+	}
+
+	bg2.Done()
+}
+
+func WaitSafeNegativeCounterTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("negative counter of safe wait Background caused panic")
+		}
+	}()
+
+	bg := withSafeWait()
+
+	okDone := runWaitable(bg)
+
+	closeChanAndPropagate(okDone)
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	bg.Done()
+
+	if err := bg.Err(); !errors.Is(err, ErrNegativeCounter) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrNegativeCounter, err)
+	}
+
+	if pending := bg.Pending(); pending != 0 {
+		t.Errorf("wrong pending counter, want 0, have %d", pending)
+	}
+
+	bg.Wait()
+}
+
+// Readiness
+
+func ReadinessWrapTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = withReadiness(bg1)
+		bg3 = withReadiness(bg2)
+	)
+
+	if hasClosed(
+		bg1.ready, bg2.ready, bg3.ready,
+	) {
+		t.Error(errInitReady)
+	}
+
+	bg1ReadyC := bg1.Ready()
+	bg2ReadyC := bg2.Ready()
+	bg3ReadyC := bg3.Ready()
+
+	bg3.Ok()
+
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg3.ready):
+		t.Error(errNotReady)
+	case hasClosed(bg1.ready, bg2.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC):
+		t.Error(errReady)
+	}
+
+	bg2.Ok()
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg2.ready, bg3.ready):
+		t.Error(errNotReady)
+	case hasClosed(bg1.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC):
+		t.Error(errReady)
+	}
+
+	bg1.Ok()
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(bg1.ready, bg2.ready, bg3.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC) {
+		t.Error(errNotReady)
+	}
+}
+
+func ReadinessSuccessiveOkTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive Ok call caused panic")
+		}
+	}()
+
+	bg1 := withReadiness()
+
+	bg1.Ok()
+	bg1.Ok()
+	bg1.Ok()
+	readyC := bg1.Ready()
+
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(readyC) {
+		t.Error(errNotReady)
+	}
+}
+
+func ReadinessSuccessiveReadyTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive Ready call caused panic")
+		}
+	}()
+
+	bg1 := withReadiness()
+	bg1.Ok()
+	readyC := bg1.Ready()
+
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(readyC) {
+		t.Error(errNotReady)
+	}
+
+	readyC = bg1.Ready()
+
+	if hasNotClosed(readyC) {
+		t.Error(errNotReady)
+	}
+}
+
+// Value
+
+type key string
+
+func ValueWrapTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey   = key("test_key")
+		testValue = "test_value"
+		bg1       = withValue(testKey, testValue)
+		bg2       = withWait(bg1)
+		bg3       = withWait(bg2)
+	)
+
+	value := bg3.Value(testKey)
+	if value == nil {
+		t.Error("test value for test key not found")
+	}
+
+	valueTyped, ok := value.(string)
+	if !ok {
+		t.Error("test value for test key has wrong type")
+	}
+
+	if valueTyped != testValue {
+		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
+	}
+}
+
+func ValueChildrenTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey1   = key("test_key1")
+		testValue1 = "test_value2"
+
+		testKey2   = key("test_key2")
+		testValue2 = "test_value2"
+
+		testKeyNotFound = key("test_key3")
+		bg1             = withValue(testKey1, testValue1)
+		bg2             = withValue(testKey2, testValue2, bg1)
+	)
+
+	value := bg2.Value(testKey1)
+	if value == nil {
+		t.Error("test value 1 for test key not found")
+	}
+
+	valueTyped, ok := value.(string)
+	if !ok {
+		t.Error("test value 1 for test key has wrong type")
+	}
+
+	if valueTyped != testValue1 {
+		t.Errorf("wrong test value 1: want %s have %s", testValue1, valueTyped)
+	}
+
+	value2 := bg2.Value(testKey2)
+	if value2 == nil {
+		t.Error("test value 2 for test key not found")
+	}
+
+	value2Typed, ok := value2.(string)
+	if !ok {
+		t.Error("test value 2 for test key has wrong type")
+	}
+
+	if value2Typed != testValue2 {
+		t.Errorf("wrong test value 2: want %s have %s", testValue1, valueTyped)
+	}
+
+	value3 := bg2.Value(testKeyNotFound)
+	if value3 != nil {
+		t.Error("unused key returned non-nil value")
+	}
+}
+
+func ValueNilPanicTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("nil key did not panic")
+		}
+	}()
+
+	_ = withValue(nil, "")
+}
+
+func ValueComparablePanicTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("incomparable key did not panic")
+		}
+	}()
+
+	_ = withValue(func() {}, "")
+}
+
+func ValueBottomUpTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey = key("test_key")
+		bg1     = withValue(testKey, "child")
+		bg2     = withValue(testKey, "parent", withWait(), withWait(bg1))
+	)
+
+	if value := FindValue(bg2, testKey, TopDown); value != "parent" {
+		t.Errorf("wrong top-down value: want parent have %v", value)
+	}
+
+	if value := FindValue(bg2, testKey, BottomUp); value != "child" {
+		t.Errorf("wrong bottom-up value: want child have %v", value)
+	}
+
+	if value := FindValue(bg2, key("test_key_not_found"), BottomUp); value != nil {
+		t.Error("unused key returned non-nil value")
+	}
+}
+
+func ValueScopeTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey = key("test_key")
+		bg1     = withValue(testKey, "library")
+		bg2     = ScopeValues(bg1)
+		bg3     = merge(bg2, withWait())
+	)
+
+	if value := bg1.Value(testKey); value != "library" {
+		t.Errorf("wrong value inside scope: want library have %v", value)
+	}
+
+	if value := bg3.Value(testKey); value != nil {
+		t.Errorf("scoped value escaped the scope: %v", value)
+	}
+
+	if value := FindValue(bg3, testKey, BottomUp); value != nil {
+		t.Errorf("scoped value escaped the scope bottom-up: %v", value)
+	}
+}
+
+func ValueContextTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey  = key("test_key")
+		ctxKey   = key("ctx_key")
+		ctx      = context.WithValue(context.WithValue(context.Background(), ctxKey, "context"), testKey, "shadowed")
+		bg1      = withValue(testKey, "tree")
+		bg2      = WithContextValues(ctx, bg1)
+		bg3      = merge(bg2, withWait())
+		notFound = key("test_key_not_found")
+	)
+
+	if value := bg3.Value(testKey); value != "tree" {
+		t.Errorf("wrong tree value: want tree have %v", value)
+	}
+
+	if value := bg3.Value(ctxKey); value != "context" {
+		t.Errorf("wrong context value: want context have %v", value)
+	}
+
+	if value := bg3.Value(notFound); value != nil {
+		t.Error("unused key returned non-nil value")
+	}
+}
+
+func ValueProvideTest(t *testing.T) {
+	t.Parallel()
+
+	type fatal chan error
+
+	var (
+		ch  = make(fatal)
+		bg1 = Provide(ch)
+		bg2 = Provide(key("test_value"), withWait(bg1))
+	)
+
+	var have fatal
+	if !Resolve(bg2, &have) {
+		t.Fatal("provided value not resolved")
+	}
+
+	if have != ch {
+		t.Error("wrong resolved value")
+	}
+
+	var unrelated chan error
+	if Resolve(bg2, &unrelated) {
+		t.Error("value of different type resolved")
+	}
+}
+
+func ValueResolveInterfaceTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = Provide(err)
+		bg2 = Provide(key("test_value"), withWait(bg1))
+	)
+
+	var have error
+	if !Resolve(bg2, &have) {
+		t.Fatal("provided value not resolved to the interface it implements")
+	}
+
+	if have != err {
+		t.Errorf("wrong resolved value, want '%v', have '%v'", err, have)
+	}
+
+	var unrelated fmt.Stringer
+	if Resolve(bg2, &unrelated) {
+		t.Error("value not implementing the interface resolved")
+	}
+
+	// scoped values are hidden from both lookups
+	bg3 := Merge(ScopeValues(Provide(err, Provide(key("test_value")))))
+
+	var scoped error
+	if Resolve(bg3, &scoped) {
+		t.Error("scoped value resolved to the interface it implements")
+	}
+
+	var exact key
+	if Resolve(bg3, &exact) {
+		t.Error("scoped value resolved to its type")
+	}
+}
+
+func ValueResolvePanicTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("non-pointer target did not panic")
+		}
+	}()
+
+	Resolve(Empty(), key("test_value"))
+}
+
+// Annotate
+
+func AnnotationErrorTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	var (
+		err1 = errors.New("error1")
+		err2 = errors.New("error2")
+
+		bg1 = withError(err1)
+		bg2 = withError(err2, bg1)
+		bg3 = withAnnotation(annotation, bg2)
+	)
+
+	err := bg3.Err()
+
+	// err2 is higher in the tree so it must be found first
+	if !errors.Is(err, err2) {
+		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, err2.Error())
+
+	if errText(err) != wantErrStr {
+		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	}
+
+	err = bg1.Err()
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+}
+
+func AnnotationShutdownTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	bg1 := withShutdown()
+	bg2 := withAnnotation(annotation, bg1)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg2.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, ErrTimeout.Error())
+
+	if errText(err) != wantErrStr {
+		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	}
+}
+
+func AnnotationChildShutdownTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	bg1 := withShutdown()
+	bg2 := withAnnotation(annotation, bg1)
+	bg3 := withShutdown(bg2)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+	_ = runShutdownable(bg3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg3.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, ErrTimeout.Error())
+
+	if errText(err) != wantErrStr {
+		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	}
+}
+
+func AnnotationNilErrorTest(t *testing.T) {
+	t.Parallel()
+
+	const message = "test"
+
+	bg1 := withError(nil)
+	bg2 := withAnnotation(message, bg1)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("annotated Background returned error instead of nil")
+	}
+}
+
+func AnnotationNilShutdownErrorTest(t *testing.T) {
+	t.Parallel()
+
+	bg1 := withShutdown()
+	bg2 := withAnnotation("", bg1)
+
+	// blocked finish
+	okDone1 := runShutdownable(bg1)
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Errorf("annotation Background returned error instead of nil")
+	}
+}
+
+func AnnotationUnclosedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("", bg1)
+	)
+
+	okDone1 := runShutdownable(bg1)
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg2.Shutdown(ctx)
+	if err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	if err := bg1.cause(context.Background()); err != nil {
+		t.Errorf(errNotFinished)
+	}
+
+	if err := bg2.cause(context.Background()); err != nil {
+		t.Errorf(errNotFinished)
+	}
+}
+
+func AnnotationCallerTest(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("test")
+
+	_, _, line, _ := runtime.Caller(0)
+	bg := WithCallerAnnotation(withError(err))
+
+	want := fmt.Sprintf("background.AnnotationCallerTest (state_test.go:%d): test", line+1)
+
+	if haveErr := bg.Err(); haveErr == nil || !strings.HasSuffix(haveErr.Error(), want) {
+		t.Errorf("error is not annotated with caller, want suffix '%s', have '%v'", want, haveErr)
+	}
+}
+
+// Error
+
+func ErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err1 = errors.New("error1")
+		err2 = errors.New("error2")
+
+		bg1 = withError(err1)
+		bg2 = withError(err2, bg1)
+	)
+
+	err := bg2.Err()
+
+	// err2 is higher in the tree so it must be found first
+	if !errors.Is(err, err2) {
+		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+	}
+
+	err = bg1.Err()
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+}
+
+// Error group
+
+func ErrorGroupTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	var (
+		err1 = errors.New("error1")
+		err2 = errors.New("error2")
+		bg1  = withErrorGroup()
+		bg2  = withAnnotation(annotation, bg1)
+	)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("new error group Background returned error")
+	}
+
+	go func() {
+		bg1.Error(err1)
+	}()
+
+	time.Sleep(failTimeout)
+
+	err := bg2.Err()
+
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, err1.Error())
+
+	if err.Error() != wantErrStr {
+		t.Errorf("error group error is not annotated, want '%s', have '%s'", wantErrStr, err.Error())
+	}
+
+	go func() {
+		bg1.Error(err2)
+	}()
+
+	time.Sleep(failTimeout)
+
+	err = bg2.Err()
+
+	if errors.Is(err, err2) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err2)
+	}
+}
+
+func ErrorGroupErrorfTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test: %w"
+
+	var (
+		err1 = errors.New("error1")
+		err2 = errors.New("error2")
+		bg1  = withErrorGroup()
+		bg2  = merge(bg1)
+	)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("new error group Background returned error")
+	}
+
+	go func() {
+		bg1.Errorf(annotation, err1)
+	}()
+
+	time.Sleep(failTimeout)
+
+	err := bg2.Err()
+
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", "test", err1.Error())
+
+	if err.Error() != wantErrStr {
+		t.Errorf("error group error is not annotated, want '%s', have '%s'", wantErrStr, err.Error())
+	}
+
+	go func() {
+		bg1.Errorf(annotation, err2)
+	}()
+
+	time.Sleep(failTimeout)
+
+	err = bg2.Err()
+
+	if errors.Is(err, err2) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err2)
+	}
+}
+
+func ErrorGroupCachedErrTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = withErrorGroup()
+		bg2 = merge(bg1, withError(nil))
+		bg3 = withAnnotation("test", bg2)
+	)
+
+	// fill the caches
+	for i := 0; i < 2; i++ {
+		if err := bg3.Err(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	bg1.Error(err)
+
+	if haveErr := bg3.Err(); !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+}
+
+// mutableBackground is a Background of another package that changes
+// its error and value without notifying the package.
+type mutableBackground struct {
+	Background
+
+	mu    sync.Mutex
+	err   error
+	value interface{}
+}
+
+func (m *mutableBackground) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+func (m *mutableBackground) Value(key interface{}) interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key == "key" {
+		return m.value
+	}
+
+	return nil
+}
+
+func ErrorGroupUncachedErrTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = &mutableBackground{Background: Empty()}
+		bg2 = withAnnotation("test", merge(bg1, withError(nil)))
+	)
+
+	// fill the caches
+	for i := 0; i < 2; i++ {
+		if err := bg2.Err(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if value := bg2.Value("key"); value != nil {
+			t.Errorf("unexpected value: %v", value)
+		}
+	}
+
+	bg1.mu.Lock()
+	bg1.err, bg1.value = err, "value"
+	bg1.mu.Unlock()
+
+	if haveErr := bg2.Err(); !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	if value := bg2.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
+}
+
+func ErrorGroupTryErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err1 = errors.New("test1")
+		err2 = errors.New("test2")
+		bg   = withErrorGroup()
+	)
+
+	if bg.TryError(nil) {
+		t.Error("nil error reported as assigned")
+	}
+
+	if !bg.TryError(err1) {
+		t.Error("first error reported as dropped")
+	}
+
+	if bg.TryError(err2) {
+		t.Error("second error reported as assigned")
+	}
+
+	if haveErr := bg.Err(); !errors.Is(haveErr, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, haveErr)
+	}
+}
+
+// receiveAll receives the errors buffered in c.
+func receiveAll(c <-chan error) (errs []error) {
+	for {
+		select {
+		case err := <-c:
+			errs = append(errs, err)
+		default:
+			return errs
+		}
+	}
+}
+
+func ErrorIntakePoliciesTest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy IntakePolicy
+		errs   []string
+		want   string
+	}{
+		{"DropNewest", DropNewest(), []string{"1", "2", "3", "4"}, "[1 2 3]"},
+		{"DropOldest", DropOldest(), []string{"1", "2", "3", "4"}, "[2 3 4]"},
+		{"SampleEvery", SampleEvery(2), []string{"1", "2", "3", "4", "5"}, "[1 3 5]"},
+		{"CoalesceIdentical", CoalesceIdentical(), []string{"1", "1", "2", "2", "1"}, "[1 2 1]"},
+	}
+
+	for _, test := range tests {
+		bg, tail := WithErrorIntake(3, test.policy)
+
+		for _, err := range test.errs {
+			tail.Error(errors.New(err))
+		}
+
+		if have := fmt.Sprint(receiveAll(Errors(bg))); have != test.want {
+			t.Errorf("%s: want errors %s, have %s", test.name, test.want, have)
+		}
+	}
+}
+
+func ErrorIntakeErrTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err1 = errors.New("test1")
+		err2 = errors.New("test2")
+		bg1  = withErrorIntake(1, DropNewest())
+		bg2  = withAnnotation("intake", bg1)
+	)
+
+	if !bg1.TryError(err1) {
+		t.Error("error reported as dropped")
+	}
+
+	if bg1.TryError(err2) {
+		t.Error("error to full intake reported as kept")
+	}
+
+	if haveErr := bg2.Err(); !errors.Is(haveErr, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, haveErr)
+	}
+
+	if have := <-Errors(bg2); have != err1 {
+		t.Errorf("wrong intake error, want '%v', have '%v'", err1, have)
+	}
+
+	if Errors(Empty()) != nil {
+		t.Error("intake found in empty Background")
+	}
+}
+
+// Empty
+
+func EmptyClosingTest(t *testing.T) {
+	t.Parallel()
+
+	bg := Empty()
+
+	if bg.IsClosing() || hasClosed(bg.Closing()) {
+		t.Error("empty Background is closing before shutdown")
+	}
+
+	handler := RequireReady(bg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("wrong status of empty Background: %d", w.Code)
+	}
+}
+
+func EmptyTest(t *testing.T) {
+	t.Parallel()
+
+	bg1 := emptyBackground{}
+
+	if err := bg1.Err(); err != nil {
+		t.Errorf("empty Background returned error")
+	}
+
+	if err := bg1.Shutdown(context.Background()); err != nil {
+		t.Errorf("empty Background shutdowned with error")
+	}
+
+	okDone1 := make(chan struct{})
+
+	go func() {
+		bg1.Wait()
+		close(okDone1)
+	}()
+
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(okDone1) {
+		t.Errorf("empty Background blocked on wait")
+	}
+
+	readyC := bg1.Ready()
+	if hasNotClosed(readyC) {
+		t.Errorf("empty Background is not ready")
+	}
+
+	if value := bg1.Value(""); value != nil {
+		t.Errorf("empty Background returned value")
+	}
+
+	okDone2 := make(chan struct{})
+
+	go func() {
+		bg1.close(context.Background())
+		close(okDone2)
+	}()
+
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(okDone2) {
+		t.Errorf("empty Background blocked on close")
+	}
+
+	if hasNotClosed(bg1.finishSig()) {
+		t.Errorf("empty Background is not done")
+	}
+
+	if err := bg1.cause(context.Background()); err != nil {
+		t.Errorf("empty Background cause call returned error")
+	}
+}
+
+// Dependency
+
+func DependencyShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	bg4 := withDependency(bg3, bg1, bg2)
+
+	if hasClosed(
+		bg1.end, bg2.end, bg3.end,
+		bg1.done, bg2.done, bg3.done,
+	) {
+		t.Error(errInitClosed)
+	}
+
+	go bg4.close(context.Background())
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg1.end, bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg3.end):
+		t.Error(errClosed)
+	case hasClosed(bg1.done, bg2.done, bg3.done):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	switch {
+	case hasNotClosed(bg3.end):
+		t.Error(errNotClosed)
+	case hasNotClosed(bg1.done, bg2.done):
+		t.Error(errNotFinished)
+	case hasClosed(bg3.done):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone3)
+
+	if hasNotClosed(bg3.done) {
+		t.Error(errNotFinished)
+	}
+}
+
+func DependencyShutdownChainTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	bg4 := withDependency(bg3, bg1)
+	bg4 = withDependency(bg4, bg2)
+
+	if hasClosed(
+		bg1.end, bg2.end, bg3.end,
+		bg1.done, bg2.done, bg3.done, bg4.finished,
+	) {
+		t.Error(errInitClosed)
+	}
+
+	go bg4.close(context.Background())
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg1.end, bg3.end):
+		t.Error(errClosed)
+	case hasClosed(bg1.done, bg2.done, bg3.done, bg4.finished):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone2)
+
+	switch {
+	case hasNotClosed(bg1.end):
+		t.Error(errNotClosed)
+	case hasNotClosed(bg2.done):
+		t.Error(errNotFinished)
+	case hasClosed(bg3.end):
+		t.Error(errClosed)
+	case hasClosed(bg1.done, bg3.done, bg4.finished):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone1)
+
+	switch {
+	case hasNotClosed(bg3.end):
+		t.Error(errNotClosed)
+	case hasNotClosed(bg1.done):
+		t.Error(errNotFinished)
+	case hasClosed(bg3.done, bg4.finished):
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(okDone3)
+
+	if hasNotClosed(bg3.done, bg4.finished) {
+		t.Error(errNotFinished)
+	}
+}
+
+func DependencyShutdownSuccessiveCloseTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive close call caused panic")
+		}
+	}()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+	)
+
+	close(runShutdownable(bg1))
+	close(runShutdownable(bg2))
+
+	bg4 := withDependency(bg1, bg2)
+
+	go bg4.close(context.Background())
+	time.Sleep(failTimeout)
+
+	bg4.close(context.Background())
+}
+
+func DependencyShutdownChildrenTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = emptyBackground{}
+		bg3 = bg1.DependsOn(bg2)
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg3.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+}
+
+func DependencyShutdownParentTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = bg1.DependsOn(bg2)
+	)
+
+	okDone1 := runShutdownable(bg1)
+	_ = runShutdownable(bg2)
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg3.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+	}
+}
+
+func DependencyShutdownUnclosedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withDependency(bg1, bg2)
+	)
+
+	// blocked finish
+	okDone2 := runShutdownable(bg2)
+	okDone1 := runShutdownable(bg1)
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	err := bg3.Shutdown(ctx)
+	if err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	if err := bg3.cause(context.Background()); err != nil {
+		t.Errorf(errNotFinished)
+	}
+}
+
+func DependencyWaitTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withWait()
+		bg2 = withWait()
+		bg3 = withWait()
+
+		okDone1 = runWaitable(bg1)
+		okDone2 = runWaitable(bg2)
+		okDone3 = runWaitable(bg3)
+
+		bg4 = bg3.DependsOn(bg1, bg2)
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		bg4.Wait()
+		close(done)
+	}()
+
+	time.Sleep(failTimeout)
+
+	if hasClosed(done) {
+		t.Error(errNotWaited)
+	}
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	if hasClosed(done) {
+		t.Error(errNotWaited)
+	}
+
+	closeChanAndPropagate(okDone3)
+
+	if hasNotClosed(done) {
+		t.Error(errNotWaited)
+	}
+}
+
+func DependencyReadinessTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = withReadiness()
+		bg3 = withReadiness()
+	)
+
+	bg4 := withDependency(bg3, bg1, bg2)
+
+	if hasClosed(
+		bg1.ready, bg2.ready, bg3.ready,
+	) {
+		t.Error(errInitReady)
+	}
+
+	bg1ReadyC := bg1.Ready()
+	bg2ReadyC := bg2.Ready()
+	bg3ReadyC := bg3.Ready()
+	bg4ReadyC := bg4.Ready()
+
+	bg1.Ok()
+
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg1.ready, bg1ReadyC):
+		t.Error(errNotReady)
+	case hasClosed(bg2.ready, bg3.ready, bg2ReadyC, bg3ReadyC, bg4ReadyC):
+		t.Error(errReady)
+	}
+
+	bg2.Ok()
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg1.ready, bg1ReadyC, bg2.ready, bg2ReadyC):
+		t.Error(errNotReady)
+	case hasClosed(bg3.ready, bg3ReadyC, bg4ReadyC):
+		t.Error(errReady)
+	}
+
+	bg3.Ok()
+	time.Sleep(failTimeout)
+
+	if hasNotClosed(bg1.ready, bg1ReadyC, bg2.ready, bg2ReadyC, bg3.ready, bg3ReadyC, bg4ReadyC) {
+		t.Error(errNotReady)
+	}
+}
+
+func DependencyErrorParentTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err1 = errors.New("error1")
+		err2 = errors.New("error2")
+
+		bg1 = withError(err1)
+		bg2 = withError(err2)
+		bg3 = withDependency(bg1, bg2)
+	)
+
+	err := bg3.Err()
+
+	// err1 is higher in the tree so it must be found first
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+
+	err = bg2.Err()
+	if !errors.Is(err, err2) {
+		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+	}
+}
+
+func DependencyErrorChildrenTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err1 = errors.New("error1")
+
+		bg1 = withError(err1)
+		bg2 = emptyBackground{}
+		bg3 = bg2.DependsOn(bg1)
+	)
+
+	err := bg3.Err()
+
+	if !errors.Is(err, err1) {
+		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	}
+}
+
+func DependencyErrorNilTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withError(nil)
+		bg2 = emptyBackground{}
+		bg3 = bg2.DependsOn(bg1)
+	)
+
+	if err := bg3.Err(); err != nil {
+		t.Errorf("error must be nil, have '%v'", err)
+	}
+}
+
+func DependencyValueParentTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey   = key("test_key")
+		testValue = "test_value"
+		bg1       = emptyBackground{}
+		bg2       = emptyBackground{}
+		bg3       = bg2.DependsOn(bg1)
+		bg4       = withValue(testKey, testValue)
+		bg5       = withDependency(bg4, bg3)
+	)
+
+	value := bg5.Value(testKey)
+	if value == nil {
+		t.Error("test value for test key not found")
+	}
+
+	valueTyped, ok := value.(string)
+	if !ok {
+		t.Error("test value for test key has wrong type")
+	}
+
+	if valueTyped != testValue {
+		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
+	}
+}
+
+func DependencyValueChildrenTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		testKey   = key("test_key")
+		testValue = "test_value"
+		bg1       = withValue(testKey, testValue)
+		bg2       = emptyBackground{}
+		bg3       = bg2.DependsOn(bg1)
+		bg4       = emptyBackground{}
+		bg5       = bg4.DependsOn(bg3)
+	)
+
+	value := bg5.Value(testKey)
+	if value == nil {
+		t.Error("test value for test key not found")
+	}
+
+	valueTyped, ok := value.(string)
+	if !ok {
+		t.Error("test value for test key has wrong type")
+	}
+
+	if valueTyped != testValue {
+		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
+	}
+}
+
+func DependencyAnnotationTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = emptyBackground{}
+		bg2 = emptyBackground{}
+		bg3 = withAnnotation("", bg1, bg2)
+		bg4 = emptyBackground{}
+		bg5 = withDependency(bg3, bg4)
+	)
+
+	if bg5.parent == nil {
+		t.Errorf("parent of dependency Background is nil, must be empty Background")
+	}
+
+	if bg5.parent != bg3 {
+		t.Errorf("wrong parent of dependency Background")
+	}
+
+	if len(bg5.children.backgrounds) != 1 {
+		t.Errorf("wrong number of dependency Background children, want 1, have %d", len(bg5.children.backgrounds))
+		return
+	}
+
+	if bg5.children.backgrounds[0] != bg4 {
+		t.Errorf("wrong children of dependency Background")
+	}
+}
+
+func DependencyShutdownLevelsTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = withDependency(bg1, bg2).dependsOn(bg3)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	close(okDone1)
+	close(okDone2)
+	close(okDone3)
+
+	if levels := dependencyLevels(bg4); len(levels) != 3 {
+		t.Errorf("wrong number of dependency levels: want 3, have %d", len(levels))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := ShutdownLevels(ctx, bg4); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	if hasNotClosed(bg1.done, bg2.done, bg3.done, bg4.finished) {
+		t.Error(errNotFinished)
+	}
+}
+
+func DependencyShutdownLevelsAnnotatedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = withAnnotation("chain", withAnnotation("inner", withDependency(bg1, bg2)).DependsOn(bg3))
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	close(okDone1)
+	close(okDone2)
+	close(okDone3)
+
+	if levels := dependencyLevels(bg4); len(levels) != 3 {
+		t.Errorf("wrong number of dependency levels: want 3, have %d", len(levels))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := ShutdownLevels(ctx, bg4); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	if hasNotClosed(bg1.done, bg2.done, bg3.done, bg4.finished) {
+		t.Error(errNotFinished)
+	}
+}
+
+func DependencyShutdownLevelsTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withDependency(bg1, bg2)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	defer close(okDone1)
+
+	// blocked finish
+	_ = runShutdownable(bg2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*failTimeout)
+	defer cancel()
+
+	start := time.Now()
+
+	err := ShutdownLevels(ctx, bg3)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked shutdown didn't timeout")
+		return
+	}
+
+	if elapsed := time.Since(start); elapsed > 3*failTimeout/2 {
+		t.Errorf("level exceeded its budget: %v", elapsed)
+	}
+
+	wantErrStr := fmt.Sprintf("level 1: %s", ErrTimeout.Error())
+
+	if errText(err) != wantErrStr {
+		t.Errorf("timeout error doesn't name the level, want error '%s', have '%s'", wantErrStr, err.Error())
+	}
+}
+
+func DependencySequentialTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = DependsOnWith(bg1, LevelOptions{Sequential: true}, bg2, bg3)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	go bg4.Shutdown(context.Background())
+
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg2.end) {
+		t.Error(errNotClosed)
+	}
+
+	if hasClosed(bg3.end, bg1.end) {
+		t.Error(errClosed)
+	}
+
+	close(okDone2)
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg3.end) {
+		t.Error(errNotClosed)
+	}
+
+	if hasClosed(bg1.end) {
+		t.Error(errClosed)
+	}
+
+	close(okDone3)
+	close(okDone1)
+
+	select {
+	case <-bg4.Finished():
+	case <-time.After(failTimeout):
+		t.Error(errNotFinished)
+	}
+}
+
+func DependencyInterleaveTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = DependsOnWith(bg1, LevelOptions{Interleave: true}, bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	defer close(okDone1)
+
+	go bg3.Shutdown(context.Background())
+
+	time.Sleep(failTimeout / 10)
+
+	if !bg1.IsClosing() {
+		t.Error("parent's closing signal isn't fired")
+	}
+
+	if hasClosed(bg1.end) {
+		t.Error(errClosed)
+	}
+
+	close(okDone2)
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg1.end) {
+		t.Error(errNotClosed)
+	}
+}
+
+// Subtree
+
+func SubtreeShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	const name = "tenant"
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withAnnotation(name, bg1)
+		bg4 = merge(bg3, bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	if sub := Subtree(bg4, name); sub != bg3 {
+		t.Errorf("wrong subtree, want '%v', have '%v'", bg3, sub)
+	}
+
+	closeChanAndPropagate(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := ShutdownSubtree(ctx, bg4, name); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	switch {
+	case hasNotClosed(bg1.done):
+		t.Error(errNotFinished)
+	case hasClosed(bg2.end):
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone2)
+
+	ctx, cancel = context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg4.Shutdown(ctx); err != nil {
+		t.Errorf(errTimeout)
+	}
+}
+
+func SubtreeNotFoundTest(t *testing.T) {
+	t.Parallel()
+
+	bg1 := withShutdown()
+	bg := withAnnotation("test", bg1)
+
+	defer endTails(bg1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := ShutdownSubtree(ctx, bg, "unknown"); !errors.Is(err, ErrSubtreeNotFound) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrSubtreeNotFound, err)
+	}
+}
+
+// Reload
+
+func runReloadable(tail ReloadTail, err error, reloaded chan<- ReloadTail) {
+	go func() {
+		for range tail.Reloads() {
+			reloaded <- tail
+			tail.Done(err)
+		}
+	}()
+}
+
+func ReloadDependencyTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReload()
+		bg2 = withReload()
+		bg3 = withReload(bg2)
+		bg4 = bg1.DependsOn(bg3)
+
+		reloaded = make(chan ReloadTail, 3)
+	)
+
+	runReloadable(bg1, nil, reloaded)
+	runReloadable(bg2, nil, reloaded)
+	runReloadable(bg3, nil, reloaded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg4.Reload(ctx); err != nil {
+		t.Errorf("unexpected reload error: %v", err)
+	}
+
+	close(reloaded)
+
+	var order []ReloadTail
+	for tail := range reloaded {
+		order = append(order, tail)
+	}
+
+	if len(order) != 3 || order[0] != bg2 || order[1] != bg3 || order[2] != bg1 {
+		t.Errorf("wrong reload order")
+	}
+}
+
+func ReloadAnnotatedErrorTest(t *testing.T) {
+	t.Parallel()
+
+	const annotation = "test"
+
+	var (
+		err = errors.New("error")
+		bg1 = withReload()
+		bg2 = withAnnotation(annotation, bg1)
+		bg3 = withReload(bg2)
+
+		reloaded = make(chan ReloadTail, 2)
+	)
+
+	runReloadable(bg1, err, reloaded)
+	runReloadable(bg3, nil, reloaded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	haveErr := bg3.Reload(ctx)
+	if !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+		return
+	}
+
+	wantErrStr := fmt.Sprintf("%s: %s", annotation, err.Error())
+
+	if haveErr.Error() != wantErrStr {
+		t.Errorf("reload error is not annotated, want error '%s', have '%s'", wantErrStr, haveErr.Error())
+	}
+
+	if len(reloaded) != 1 {
+		t.Errorf("parent reloaded after child reload error")
+	}
+}
+
+func ReloadTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	bg := withReload()
+
+	// blocked reload
+	go func() { <-bg.Reloads() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Reload(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("blocked reload didn't timeout")
+	}
+}
+
+// Pause
+
+func PauseWrapTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withPause()
+		bg2 = withPause()
+		bg3 = bg1.DependsOn(withAnnotation("test", bg2))
+	)
+
+	switch {
+	case hasClosed(bg1.Paused(), bg2.Paused()):
+		t.Error("pause Background initialized paused")
+	case hasNotClosed(bg1.Resumed(), bg2.Resumed()):
+		t.Error("pause Background initialized not resumed")
+	}
+
+	bg3.Pause()
+
+	switch {
+	case hasNotClosed(bg1.Paused(), bg2.Paused()):
+		t.Error("pause Background didn't pause")
+	case hasClosed(bg1.Resumed(), bg2.Resumed()):
+		t.Error("unexpected resume of pause Background")
+	}
+
+	resumed := bg1.Resumed()
+
+	bg3.Resume()
+
+	switch {
+	case hasNotClosed(resumed, bg1.Resumed(), bg2.Resumed()):
+		t.Error("pause Background didn't resume")
+	case hasClosed(bg1.Paused(), bg2.Paused()):
+		t.Error("unexpected pause of pause Background")
+	}
+}
+
+func PauseSuccessiveCallTest(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("successive Pause or Resume call caused panic")
+		}
+	}()
+
+	bg := withPause()
+
+	bg.Resume()
+	bg.Pause()
+	bg.Pause()
+	bg.Resume()
+	bg.Resume()
+}
+
+// Deadline
+
+func DeadlineExceededTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withDeadline(time.Now().Add(failTimeout), bg1)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("unexpected error before deadline: %v", err)
+	}
+
+	if hasClosed(bg1.end) {
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone1)
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg1.done, bg2.finished):
+		t.Error(errNotFinished)
+	case !errors.Is(bg2.Err(), ErrDeadlineExceeded):
+		t.Errorf("wrong error, want '%v', have '%v'", ErrDeadlineExceeded, bg2.Err())
+	}
+}
+
+func DeadlineShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withDeadline(time.Now().Add(failTimeout), bg1)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	close(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Errorf(errTimeout)
+	}
+
+	time.Sleep(2 * failTimeout)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("deadline exceeded after shutdown: %v", err)
+	}
+}
+
+// Adapt
+
+type testComponent struct {
+	err      error
+	shutdown chan struct{}
+	ready    chan struct{}
+}
+
+func (c *testComponent) Shutdown(_ context.Context) error {
+	<-c.shutdown
+	return c.err
+}
+
+func (c *testComponent) Ready() <-chan struct{} {
+	return c.ready
+}
+
+func (c *testComponent) Value(key interface{}) interface{} {
+	if key == "key" {
+		return "value"
+	}
+
+	return nil
+}
+
+func AdaptShutdownerTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		c = &testComponent{
+			err:      errors.New("test"),
+			shutdown: make(chan struct{}),
+			ready:    make(chan struct{}),
+		}
+
+		bg1 = adapt(c)
+		bg2 = withShutdown()
+		bg3 = withDependency(bg2, bg1)
+
+		okDone2 = runShutdownable(bg2)
+	)
+
+	readyC := bg3.Ready()
+
+	if value := bg3.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
+
+	if hasClosed(readyC) {
+		t.Error(errReady)
+	}
+
+	closeChanAndPropagate(c.ready)
+
+	if hasNotClosed(readyC) {
+		t.Error(errNotReady)
+	}
+
+	go bg3.close(context.Background())
+	time.Sleep(failTimeout)
+
+	if hasClosed(bg1.finished, bg2.end) {
+		t.Error(errFinished)
+	}
+
+	closeChanAndPropagate(c.shutdown, okDone2)
+
+	if hasNotClosed(bg1.finished, bg2.done, bg3.finished) {
+		t.Error(errNotFinished)
+	}
+
+	if err := bg3.Err(); !errors.Is(err, c.err) {
+		t.Errorf("wrong error, want '%v', have '%v'", c.err, err)
+	}
+}
+
+func AdaptEmptyTest(t *testing.T) {
+	t.Parallel()
+
+	bg := Adapt(struct{}{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	switch {
+	case hasNotClosed(bg.Ready()):
+		t.Error(errNotReady)
+	case bg.Err() != nil:
+		t.Errorf("unexpected error: %v", bg.Err())
+	case bg.Value("key") != nil:
+		t.Errorf("unexpected value: %v", bg.Value("key"))
+	case bg.Shutdown(ctx) != nil:
+		t.Errorf(errTimeout)
+	}
+}
+
+// Builder
+
+func BuilderShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		err = errors.New("test")
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	bg, buildErr := NewBuilder().
+		Add("server", bg1).
+		Add("database", bg2).
+		Add("cache", bg3).
+		Add("config", withError(err)).
+		AddDependency("server", "database", "cache").
+		AddDependency("cache", "database").
+		Annotate("config", "config").
+		Build()
+	if buildErr != nil {
+		t.Errorf("unexpected build error: %v", buildErr)
+		return
+	}
+
+	if haveErr := bg.Err(); haveErr == nil || haveErr.Error() != "config: test" {
+		t.Errorf("wrong error, want 'config: test', have '%v'", haveErr)
+	}
+
+	go bg.close(context.Background())
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg1.end, bg3.end):
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone2)
+
+	switch {
+	case hasNotClosed(bg3.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg1.end):
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone3, okDone1)
+
+	if hasNotClosed(bg.finishSig()) {
+		t.Error(errNotFinished)
+	}
+}
+
+func BuilderValidationTest(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBuilder().
+		Add("a", Empty()).
+		Add("b", Empty()).
+		Add("c", Empty()).
+		AddDependency("a", "b").
+		AddDependency("b", "c").
+		AddDependency("c", "a").
+		Build()
+	if !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrDependencyCycle, err)
+	}
+
+	for _, b := range []*Builder{
+		NewBuilder().Add("a", Empty()).Add("a", Empty()),
+		NewBuilder().Add("a", nil),
+		NewBuilder().Add("a", Empty()).AddDependency("a", "b"),
+		NewBuilder().Add("a", Empty()).AddDependency("b", "a"),
+		NewBuilder().Add("a", Empty()).Annotate("b", "b"),
+	} {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("invalid graph is built")
+		}
+	}
+}
+
+func GraphBuildTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	g, err := ParseGraph([]byte(`{"components": [
+		{"name": "server", "annotation": "http", "dependsOn": ["db"]},
+		{"name": "db", "factory": "postgres"}
+	]}`))
+	if err != nil {
+		t.Errorf("unexpected parse error: %v", err)
+		return
+	}
+
+	bg, err := g.Build(Registry{
+		"server":   func() Background { return bg1 },
+		"postgres": func() Background { return bg2 },
+	})
+	if err != nil {
+		t.Errorf("unexpected build error: %v", err)
+		return
+	}
+
+	go bg.close(context.Background())
+	time.Sleep(failTimeout)
+
+	switch {
+	case hasNotClosed(bg2.end):
+		t.Error(errNotClosed)
+	case hasClosed(bg1.end):
+		t.Error(errClosed)
+	}
+
+	closeChanAndPropagate(okDone2, okDone1)
+
+	if hasNotClosed(bg.finishSig()) {
+		t.Error(errNotFinished)
+	}
+}
+
+func GraphValidationTest(t *testing.T) {
+	t.Parallel()
+
+	var created bool
+
+	r := Registry{
+		"a": func() Background {
+			created = true
+			return Empty()
+		},
+	}
+
+	for _, g := range []Graph{
+		{Components: []GraphComponent{{Name: "a", DependsOn: []string{"a"}}}},
+		{Components: []GraphComponent{{Name: "a", DependsOn: []string{"b"}}}},
+		{Components: []GraphComponent{{Name: "a"}, {Name: "b"}}},
+	} {
+		if _, err := g.Build(r); err == nil {
+			t.Errorf("invalid graph is built")
+		}
+	}
+
+	if created {
+		t.Errorf("component is created from invalid graph")
+	}
+
+	if _, err := ParseGraph([]byte("{")); err == nil {
+		t.Errorf("invalid graph is parsed")
+	}
+}
+
+// Options
+
+func OptionsShutdownTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withOptions(Options{ShutdownTimeout: time.Millisecond}, bg1)
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	done := make(chan error, 1)
+	go func() { done <- bg2.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-done:
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr.Deadline.IsZero() {
+			t.Errorf("want TimeoutError with deadline, have '%v'", err)
+		}
+	case <-time.After(failTimeout):
+		t.Error(errNotFinished)
+	}
+}
+
+func OptionsCloseLimitTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withOptions(Options{CloseLimit: 1}, bg1, bg2)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+	)
+
+	go bg3.close(context.Background())
+	time.Sleep(failTimeout)
+
+	if hasClosed(bg1.end) == hasClosed(bg2.end) {
+		t.Error("want exactly one child closing")
+	}
+
+	closeChanAndPropagate(okDone1, okDone2)
+
+	if hasNotClosed(bg3.finishSig()) {
+		t.Error(errNotFinished)
+	}
+}
+
+func OptionsStrictTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withOptions(Options{Strict: true}, bg1)
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "End was never called") {
+		t.Errorf("wrong strict timeout cause: %v", err)
+	}
+}
+
+func OptionsAbandonTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("stuck", bg1)
+		bg3 = withOptions(Options{Abandon: true}, bg2)
+	)
+
+	okDone := runShutdownable(bg1)
+	defer close(okDone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
+
+	if err := bg3.Shutdown(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrTimeout, err)
+	}
+
+	want := `*background.optionsBackground: abandoned
+  *background.annotationBackground "stuck": abandoned
+    *background.shutdownBackground "stuck": closing
+`
+
+	var have string
+
+	for start := time.Now(); time.Since(start) < failTimeout; time.Sleep(time.Millisecond) {
+		if have = Snapshot(bg3).String(); have == want {
+			return
+		}
+	}
+
+	t.Errorf("wrong snapshot, want\n%s\nhave\n%s", want, have)
+}
+
+func OptionsDumpGoroutinesTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withOptions(Options{DumpGoroutines: true}, bg1)
+	)
+
+	// blocked finish
+	_ = runShutdownable(bg1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	var timeoutErr *TimeoutError
+	if !errors.As(bg2.Shutdown(ctx), &timeoutErr) {
+		t.Fatal(errNotClosed)
+	}
+
+	// without creation stack all goroutines are dumped
+	if !strings.Contains(string(timeoutErr.Goroutines), "runShutdownable") {
+		t.Errorf("goroutine dump doesn't contain the blocked job")
+	}
+
+	if !strings.Contains(fmt.Sprintf("%+v", timeoutErr), "goroutines:") {
+		t.Errorf("formatted error doesn't contain the goroutine dump")
+	}
+}
+
+// Result
+
+func ResultTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withResult()
+		bg2 = withResult()
+		bg3 = withResult(bg2)
+		bg4 = merge(withAnnotation("test", bg1), bg2).DependsOn(bg3)
+	)
+
+	bg1.Push(1)
+	bg2.Push(2)
+	bg3.Push(3)
+	bg1.Push(4)
+
+	want := []interface{}{1, 4, 2, 3}
+
+	if have := Results(bg4); fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("wrong results, want %v, have %v", want, have)
+	}
+
+	if have := Results(withWait()); len(have) != 0 {
+		t.Errorf("unexpected results: %v", have)
+	}
+}
+
+func ResultEmbeddedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1      = withResult()
+		bg2, t2  = WithShutdown(bg1)
+		bg3      = Merge(&taggedBackground{Background: bg2}, taggedBackground{Background: Empty()})
+		embedded = func(s TreeSnapshot) (n int) {
+			for _, node := range s.Nodes {
+				if node.Source == SourceEmbedded {
+					n++
+				}
+			}
+			return n
+		}
+	)
+
+	defer endTails(t2)
+
+	bg1.Push(1)
+
+	if have := Results(bg3); fmt.Sprint(have) != "[1]" {
+		t.Errorf("wrong results, want [1], have %v", have)
+	}
+
+	if have := len(Report(bg3).NotReached); have != 1 {
+		t.Errorf("wrong number of not reached Backgrounds, want 1, have %d", have)
+	}
+
+	if have := embedded(Snapshot(bg3)); have != 2 {
+		t.Errorf("wrong number of embedded Backgrounds, want 2, have %d", have)
+	}
+}
+
+// Progress
+
+func ProgressTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withProgress()
+		bg2 = withProgress()
+		bg3 = withProgress(bg1)
+		bg4 = merge(bg1, withAnnotation("test", bg3)).DependsOn(bg2)
+	)
+
+	if done, total := Progress(bg4); done != 0 || total != 0 {
+		t.Errorf("unexpected initial progress %d/%d", done, total)
+	}
+
+	bg1.Set(1, 10)
+	bg2.Set(5, 5)
+	bg3.Set(0, 100)
+
+	// bg1 is shared and must be counted once
+	if done, total := Progress(bg4); done != 6 || total != 115 {
+		t.Errorf("wrong progress, want 6/115, have %d/%d", done, total)
+	}
+}
+
+// Trigger
+
+func TriggerTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err     = errors.New("test")
+		runs    = make(chan struct{}, 10)
+		release = make(chan struct{})
+	)
+
+	bg := withTrigger(TriggerImmediately(), func(ctx context.Context) error {
+		runs <- struct{}{}
+		<-release
+
+		return err
+	})
+
+	bg.Trigger()
+	<-runs
+
+	// coalesced into one run after the current one
+	bg.Trigger()
+	bg.Trigger()
+
+	close(release)
+	<-runs
+
+	time.Sleep(failTimeout / 10)
+
+	if len(runs) != 0 {
+		t.Errorf("concurrent triggers were not coalesced")
+	}
+
+	if haveErr := bg.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func TriggerShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		canceled = make(chan struct{})
+	)
+
+	bg := withTrigger(TriggerImmediately(), func(ctx context.Context) error {
+		close(started)
+
+		select {
+		case <-release:
+		case <-ctx.Done():
+			close(canceled)
+		}
+
+		return nil
+	})
+
+	bg.Trigger()
+	<-started
+
+	go bg.close(context.Background())
+	time.Sleep(failTimeout / 10)
+
+	// the shutdown waits for the run in progress
+	if hasClosed(bg.finishSig()) {
+		t.Error(errFinished)
+	}
+
+	// triggers after the shutdown began are ignored
+	bg.Trigger()
+	close(release)
+
+	time.Sleep(failTimeout / 10)
+
+	if hasNotClosed(bg.finishSig()) {
+		t.Error(errNotFinished)
+	}
+
+	if hasClosed(canceled) {
+		t.Error("run's context was canceled")
+	}
+}
+
+// Hook
+
+func HookOrderTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+
+		closedBefore, closedAfter bool
+
+		bg2 = withHook(func(ctx context.Context) error {
+			closedBefore = hasClosed(bg1.end)
+			return nil
+		}, hookOnShutdown, bg1)
+
+		bg3 = withHook(func(ctx context.Context) error {
+			closedAfter = hasClosed(bg1.finishSig())
+			return nil
+		}, hookOnFinished, bg2)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	close(okDone1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg3.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if closedBefore {
+		t.Error("shutdown hook ran after the closing of children began")
+	}
+
+	if !closedAfter {
+		t.Error("finished hook ran before children were closed")
+	}
+}
+
+func HookErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = withHook(func(ctx context.Context) error { return err }, hookOnShutdown)
+		bg2 = merge(bg1)
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if haveErr := bg1.Shutdown(ctx); haveErr != err {
+		t.Errorf("wrong shutdown error, want '%v', have '%v'", err, haveErr)
+	}
+
+	if haveErr := bg2.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+}
+
+func HookTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	bg := withAnnotation("test", withHook(func(ctx context.Context) error {
+		<-release
+		return nil
+	}, hookOnFinished))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); !errors.Is(err, ErrTimeout) || !strings.HasPrefix(err.Error(), "test: ") {
+		t.Errorf("want annotated timeout error, have '%v'", err)
+	}
+}
+
+func HookLeaveTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+
+		left = make(chan struct{}, 2)
+
+		// leave hooks are at the bottom of the tree, yet they must run
+		// before the closing of bg1 and bg2 begins
+		bg3 = withHook(func(ctx context.Context) error {
+			if hasClosed(bg1.end, bg2.end) {
+				t.Error(errClosed)
+			}
+
+			left <- struct{}{}
+
+			return nil
+		}, hookOnLeave)
+
+		bg4 = withHook(func(ctx context.Context) error {
+			left <- struct{}{}
+			return nil
+		}, hookOnLeave)
+
+		bg5 = withShutdown(bg1, bg3)
+		bg6 = bg5.DependsOn(merge(bg2, bg4))
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone5 = runShutdownable(bg5)
+	)
+
+	closeChanAndPropagate(okDone1, okDone2, okDone5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg6.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if len(left) != 2 {
+		t.Errorf("want 2 leave hooks run, have %d", len(left))
+	}
+}
+
+// Job
+
+func GoTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err      = errors.New("test")
+		started  = make(chan struct{})
+		canceled = make(chan struct{})
+
+		bg = Go(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+
+			return err
+		})
+	)
+
+	<-started
+
+	if hasClosed(canceled) {
+		t.Error("job canceled before shutdown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if !hasClosed(canceled) {
+		t.Error("job wasn't canceled")
+	}
+
+	if haveErr := bg.Err(); !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+}
+
+func GoNTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		ran int32
+
+		bg = GoN(4, func(ctx context.Context, i int) error {
+			atomic.AddInt32(&ran, 1)
+
+			if i%2 == 1 {
+				return err
+			}
+
+			return nil
+		})
+	)
+
+	bg.Wait()
+
+	if ran != 4 {
+		t.Errorf("want 4 jobs run, have %d", ran)
+	}
+
+	haveErr := bg.Err()
+
+	var jobErrs *JobErrors
+	if !errors.As(haveErr, &jobErrs) || !errors.Is(haveErr, err) || len(jobErrs.Errs) != 2 {
+		t.Fatalf("wrong error: %v", haveErr)
+	}
+
+	if want := "job 1: test; job 3: test"; haveErr.Error() != want {
+		t.Errorf("wrong error message, want '%s', have '%s'", want, haveErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func GoNLimitTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		running, max, ran int32
+
+		bg = GoNLimit(10, 2, func(ctx context.Context, i int) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+
+			atomic.AddInt32(&ran, 1)
+			<-ctx.Done()
+
+			return nil
+		})
+	)
+
+	time.Sleep(failTimeout / 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if max != 2 {
+		t.Errorf("want at most 2 jobs running simultaneously, have %d", max)
+	}
+
+	if ran != 2 {
+		t.Errorf("want jobs not started before the shutdown skipped, have %d run", ran)
+	}
+}
+
+func GoEachTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		fail  = errors.New("test")
+		mu    sync.Mutex
+		items []string
+		bg    = GoEach([]string{"a", "b", "c"}, func(ctx context.Context, item string) error {
+			mu.Lock()
+			items = append(items, item)
+			mu.Unlock()
+
+			if item == "b" {
+				return fail
+			}
+
+			return nil
+		}, 2)
+	)
+
+	bg.Wait()
+
+	sort.Strings(items)
+
+	if have := fmt.Sprint(items); have != "[a b c]" {
+		t.Errorf("wrong items, want '[a b c]', have '%s'", have)
+	}
+
+	var jobErrs *JobErrors
+	if !errors.As(bg.Err(), &jobErrs) || len(jobErrs.Errs) != 1 || jobErrs.Errs[1] != fail {
+		t.Errorf("wrong error, want job 1 failed, have '%v'", bg.Err())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func GoEachInvalidTest(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		name      string
+		items, fn interface{}
+	}{
+		{"not a slice", 1, func(ctx context.Context, i int) error { return nil }},
+		{"nil fn", []int{1}, nil},
+		{"wrong item type", []int{1}, func(ctx context.Context, s string) error { return nil }},
+		{"no ctx", []int{1}, func(i int) error { return nil }},
+		{"no error", []int{1}, func(ctx context.Context, i int) {}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic on invalid arguments", c.name)
+				}
+			}()
+
+			GoEach(c.items, c.fn, 0)
+		}()
+	}
+}
+
+func ReadyThenTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err      = errors.New("test")
+		started  = make(chan struct{})
+		canceled = make(chan struct{})
+
+		bg1 = withReadiness()
+		bg2 = ReadyThen(bg1, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+
+			return err
+		})
+	)
+
+	time.Sleep(failTimeout / 10)
+
+	if hasClosed(started) {
+		t.Error("job started before readiness")
+	}
+
+	bg1.Ok()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if hasNotClosed(canceled) {
+		t.Error("job's context wasn't canceled")
+	}
+
+	if haveErr := bg2.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+}
+
+func ReadyThenShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = ReadyThen(bg1, func(ctx context.Context) error {
+			t.Error("job started after shutdown")
+			return nil
+		})
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	bg1.Ok()
+	time.Sleep(failTimeout / 10)
+}
+
+func ReadyThenLabelsTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		started = make(chan struct{})
+		bg      = ReadyThen(Empty(), func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+
+			return nil
+		})
+	)
+
+	defer bg.Shutdown(context.Background())
+
+	<-started
+
+	var b bytes.Buffer
+
+	if err := pprof.Lookup("goroutine").WriteTo(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	label := fmt.Sprintf("%q:\"github.com/lefelys/background.ReadyThenLabelsTest (", LabelComponent)
+	if !strings.Contains(b.String(), label) {
+		t.Errorf("goroutine profile doesn't contain the label %s", label)
+	}
+}
+
+// startHelper is a job helper of another package built with GoLabeled.
+func startHelper(f func()) {
+	GoLabeled(1, f)
+}
+
+func GoLabeledTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		started = make(chan struct{})
+		stop    = make(chan struct{})
+	)
+
+	defer close(stop)
+
+	startHelper(func() {
+		close(started)
+		<-stop
+	})
+
+	<-started
+
+	var b bytes.Buffer
+
+	if err := pprof.Lookup("goroutine").WriteTo(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	label := fmt.Sprintf("%q:\"github.com/lefelys/background.GoLabeledTest (", LabelComponent)
+	if !strings.Contains(b.String(), label) {
+		t.Errorf("goroutine profile doesn't contain the label %s", label)
+	}
+}
+
+// Warmup
+
+func WarmupTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err              = errors.New("test")
+		running, overlap int32
+	)
+
+	task := WarmupTask{Run: func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 2 {
+			atomic.StoreInt32(&overlap, 1)
+		}
+
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		return nil
+	}}
+
+	bg := WithWarmup(2, task, task, task, task, WarmupTask{
+		Run:      func(ctx context.Context) error { return err },
+		Optional: true,
+	})
+
+	select {
+	case <-bg.Ready():
+	case <-time.After(failTimeout):
+		t.Fatal(errNotReady)
+	}
+
+	if atomic.LoadInt32(&overlap) != 0 {
+		t.Error("more than 2 simultaneous warmup tasks")
+	}
+
+	// optional failure degrades the Background instead of failing it
+	if haveErr := bg.Err(); haveErr != nil {
+		t.Errorf("unexpected error: %v", haveErr)
+	}
+
+	if failures := WarmupFailures(bg); len(failures) != 1 || failures[0] != err {
+		t.Errorf("wrong warmup failures, want [%v], have %v", err, failures)
+	}
+
+	if degradations := Degraded(bg); len(degradations) != 1 || degradations[0].Reason != "warmup: test" {
+		t.Errorf("wrong degradations: %v", degradations)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func WarmupFailureTest(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("test")
+
+	bg := WithWarmup(0,
+		WarmupTask{Run: func(ctx context.Context) error { return nil }},
+		WarmupTask{Run: func(ctx context.Context) error { return err }},
+	)
+
+	select {
+	case <-bg.Ready():
+		t.Error(errReady)
+	case <-time.After(failTimeout / 10):
+	}
+
+	if haveErr := bg.Err(); haveErr != err {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	if failures := WarmupFailures(bg); failures != nil {
+		t.Errorf("required failure is reported as optional: %v", failures)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func WarmupShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		started = make(chan struct{})
+		skipped = true
+	)
+
+	bg := WithWarmup(1,
+		WarmupTask{Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+
+			return nil
+		}},
+		WarmupTask{Run: func(ctx context.Context) error {
+			skipped = false
+			return nil
+		}},
+	)
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if !skipped {
+		t.Error("warmup task started after shutdown")
+	}
+
+	if hasClosed(bg.Ready()) {
+		t.Error(errReady)
+	}
+}
+
+// Startup retry
+
+func StartupRetryTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err     = errors.New("test")
+		retries []int
+	)
+
+	shutdownBg, tail := WithShutdown()
+	readinessBg, readinessTail := WithReadiness()
+
+	go func() {
+		<-tail.End()
+		tail.Done()
+	}()
+
+	attempt := 0
+	policy := RetryPolicy{
+		InitialBackoff: time.Millisecond,
+		OnRetry:        func(attempt int, _ error) { retries = append(retries, attempt) },
+	}
+
+	bg := WithStartupRetry(policy, func(ctx context.Context) (Background, error) {
+		if attempt++; attempt < 3 {
+			return nil, err
+		}
+
+		return WithValue("key", "value", shutdownBg, readinessBg), nil
+	})
+
+	select {
+	case <-bg.Ready():
+		t.Fatal(errReady)
+	case <-time.After(failTimeout / 10):
+	}
+
+	readinessTail.Ok()
+
+	select {
+	case <-bg.Ready():
+	case <-time.After(failTimeout):
+		t.Fatal(errNotReady)
+	}
+
+	if len(retries) != 2 || retries[0] != 1 || retries[1] != 2 {
+		t.Errorf("wrong retries, want [1 2], have %v", retries)
+	}
+
+	if haveErr := bg.Err(); haveErr != nil {
+		t.Errorf("unexpected error: %v", haveErr)
+	}
+
+	if value := bg.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if !shutdownBg.IsFinished() {
+		t.Error("started Background wasn't closed")
+	}
+}
+
+func StartupRetryFailureTest(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("test")
+
+	bg := WithStartupRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		func(ctx context.Context) (Background, error) { return nil, err })
+
+	time.Sleep(failTimeout / 2)
+
+	if haveErr := bg.Err(); !errors.Is(haveErr, err) || haveErr.Error() != "startup failed after 3 attempts: test" {
+		t.Errorf("wrong error, want 'startup failed after 3 attempts: test', have '%v'", haveErr)
+	}
+
+	if hasClosed(bg.Ready()) {
+		t.Error(errReady)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func StartupRetryControlsTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		component = newControlled()
+		release   = make(chan struct{})
+		bg        = WithStartupRetry(RetryPolicy{}, func(ctx context.Context) (Background, error) {
+			<-release
+			return component, nil
+		})
+	)
+
+	component.checkControls(t, bg, false)
+
+	close(release)
+
+	select {
+	case <-bg.Ready():
+	case <-time.After(failTimeout):
+		t.Fatal("started Background is not ready")
+	}
+
+	component.checkControls(t, bg, true)
+}
+
+func StartupRetryShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	bg := WithStartupRetry(RetryPolicy{InitialBackoff: time.Hour},
+		func(ctx context.Context) (Background, error) { return nil, errors.New("test") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Readiness timeout
+
+func ReadyTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = withAnnotation("test", mustBeReadyWithin(time.Millisecond, bg1))
+	)
+
+	time.Sleep(failTimeout / 10)
+
+	err := bg2.Err()
+	if !errors.Is(err, ErrNotReady) || err.Error() != "test: not ready after 1ms" {
+		t.Errorf("want annotated ErrNotReady, have '%v'", err)
+	}
+}
+
+func ReadyTimeoutReadyTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = mustBeReadyWithin(failTimeout/10, bg1)
+	)
+
+	bg1.Ok()
+	time.Sleep(failTimeout / 5)
+
+	if err := bg2.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func ReadinessStatusTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withReadiness()
+		bg2 = withReadiness()
+		bg3 = withReadiness()
+		bg4 = withAnnotation("server", merge(withAnnotation("cache", bg1), bg2)).DependsOn(bg3)
+	)
+
+	bg1.Ok()
+
+	want := []ComponentStatus{
+		{Name: "server: cache", Ready: true},
+		{Name: "server", Ready: false},
+		{Name: "", Ready: false},
+	}
+
+	if have := ReadinessStatus(bg4); fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("wrong readiness status, want %v, have %v", want, have)
+	}
+}
+
+// Await
+
+// await runs Await and returns a channel with its result.
+func await(ctx context.Context, bg Background) <-chan []interface{} {
+	result := make(chan []interface{}, 1)
+
+	go func() {
+		reason, err := Await(ctx, bg)
+		result <- []interface{}{reason, err}
+	}()
+
+	return result
+}
+
+func AwaitErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = withErrorGroup()
+		bg2 = withAnnotation("test", bg1)
+	)
+
+	result := await(context.Background(), bg2)
+	time.Sleep(failTimeout / 10)
+
+	bg1.Error(err)
+
+	select {
+	case have := <-result:
+		if have[0] != ReasonError || !errors.Is(have[1].(error), err) {
+			t.Errorf("want error reason, have %v", have)
+		}
+	case <-time.After(failTimeout):
+		t.Error("Await didn't return on error")
+	}
+}
+
+func AwaitSignalTest(t *testing.T) {
+	t.Parallel()
+
+	bg := withSignals([]os.Signal{os.Interrupt})
+	defer bg.close(context.Background())
+
+	result := await(context.Background(), merge(bg))
+
+	bg.c <- os.Interrupt
+
+	select {
+	case have := <-result:
+		var sigErr *SignalError
+		if have[0] != ReasonSignal || !errors.As(have[1].(error), &sigErr) || sigErr.Signal != os.Interrupt {
+			t.Errorf("want signal reason, have %v", have)
+		}
+	case <-time.After(failTimeout):
+		t.Error("Await didn't return on signal")
+	}
+}
+
+func AwaitContextTest(t *testing.T) {
+	t.Parallel()
+
+	bg := withShutdown()
+	defer endTails(bg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := await(ctx, bg)
+
+	cancel()
+
+	select {
+	case have := <-result:
+		if have[0] != ReasonContext || have[1] != context.Canceled {
+			t.Errorf("want context reason, have %v", have)
+		}
+	case <-time.After(failTimeout):
+		t.Error("Await didn't return on context cancel")
+	}
+}
+
+func AwaitShutdownTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withAnnotation("test", bg1)
+
+		okDone1 = runShutdownable(bg1)
+	)
+
+	result := await(context.Background(), bg2)
+
+	close(okDone1)
+	go bg2.Shutdown(context.Background())
+
+	select {
+	case have := <-result:
+		if have[0] != ReasonShutdown || have[1] != nil {
+			t.Errorf("want shutdown reason, have %v", have)
+		}
+	case <-time.After(failTimeout):
+		t.Error("Await didn't return on shutdown")
+	}
+}
+
+// Snapshot
+
+func SnapshotTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = merge(withAnnotation("server", bg1), bg2)
+	)
+
+	okDone1 := runShutdownable(bg1)
+	defer close(okDone1)
+	close(runShutdownable(bg2))
+
+	want := `*background.group: running, 2 pending
+  *background.annotationBackground "server": running
+    *background.shutdownBackground "server": running
+  *background.shutdownBackground: running
+`
+
+	if have := Snapshot(bg3).String(); have != want {
+		t.Errorf("wrong snapshot, want\n%s\nhave\n%s", want, have)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
+
+	if err := bg3.Shutdown(ctx); err == nil {
+		t.Error("blocked shutdown didn't timeout")
+	}
+
+	want = `*background.group: closing, 1 pending
+  *background.annotationBackground "server": closing
+    *background.shutdownBackground "server": closing
+  *background.shutdownBackground: finished
+`
+
+	if have := Snapshot(bg3).String(); have != want {
+		t.Errorf("wrong snapshot, want\n%s\nhave\n%s", want, have)
+	}
+}
+
+func SnapshotPreFinishedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		bg1 = withError(err)
+		bg2 = withShutdown()
+		bg3 = merge(bg1, bg2)
+	)
+
+	close(runShutdownable(bg2))
+
+	if haveErr := bg3.Err(); !errors.Is(haveErr, err) {
+		t.Errorf("wrong error, want '%v', have '%v'", err, haveErr)
+	}
+
+	want := `*background.group: running, 1 pending
+  *background.errBackground: finished before merged
+  *background.shutdownBackground: running
+`
+
+	if have := Snapshot(bg3).String(); have != want {
+		t.Errorf("wrong snapshot, want\n%s\nhave\n%s", want, have)
+	}
+}
+
+func SnapshotSourcesTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown(bg2)
+		bg4 = withShutdown()
+		bg5 = Merge(bg1, bg3).DependsOn(bg4)
+	)
+
+	defer endTails(bg1, bg2, bg3, bg4)
+
+	var have []string
+
+	for _, n := range Snapshot(bg5).Nodes {
+		have = append(have, fmt.Sprintf("%d %s %s", n.Depth, n.Source, n.Type))
+	}
+
+	want := []string{
+		"0 root *background.dependBackground",
+		"1 parent *background.group",
+		"2 merge *background.shutdownBackground",
+		"2 merge *background.shutdownBackground",
+		"3 children *background.shutdownBackground",
+		"1 dependency *background.group",
+		"2 dependency *background.shutdownBackground",
+	}
+
+	if fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("wrong sources, want %q, have %q", want, have)
+	}
+
+	if n := Snapshot(bg5).Nodes[3]; n.Index != 1 {
+		t.Errorf("wrong index of the second merged child: %d", n.Index)
+	}
+}
+
+// Phase
+
+func PhaseTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1 = withShutdown()
+		bg2 = withShutdown()
+		bg3 = withShutdown()
+		bg4 = merge(withPhase(2, bg1), withAnnotation("test", withPhase(1, bg2)), bg3)
+
+		okDone1 = runShutdownable(bg1)
+		okDone2 = runShutdownable(bg2)
+		okDone3 = runShutdownable(bg3)
+	)
+
+	go bg4.Shutdown(context.Background())
+
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg2.end) {
+		t.Error(errNotClosed)
+	}
+
+	if hasClosed(bg1.end, bg3.end) {
+		t.Error(errClosed)
+	}
+
+	close(okDone2)
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg1.end) {
+		t.Error(errNotClosed)
+	}
+
+	if hasClosed(bg3.end) {
+		t.Error(errClosed)
+	}
+
+	close(okDone1)
+	time.Sleep(failTimeout / 10)
+
+	if !hasClosed(bg3.end) {
+		t.Error(errNotClosed)
+	}
+
+	close(okDone3)
+
+	select {
+	case <-bg4.Finished():
+	case <-time.After(failTimeout):
+		t.Error(errNotFinished)
+	}
+}
+
+// App
+
+func AppRunTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1  = withShutdown()
+		bg2  = withShutdown()
+		logs []string
+		app  = NewApp("test")
+	)
+
+	close(runShutdownable(bg1))
+	close(runShutdownable(bg2))
+
+	app.Add("server", bg1).Add("db", bg2).AddDependency("db", "server")
+	app.Logf = func(format string, a ...interface{}) { logs = append(logs, fmt.Sprintf(format, a...)) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := app.Run(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if hasNotClosed(bg1.Finished(), bg2.Finished()) {
+		t.Error(errNotFinished)
+	}
+
+	want := "[started shutting down: context: context canceled stopped]"
+	if have := fmt.Sprint(logs); have != want {
+		t.Errorf("wrong logs, want %s, have %s", want, have)
+	}
+}
+
+func AppErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err = errors.New("test")
+		app = NewApp("test")
+	)
+
+	app.Add("failed", withError(err))
+
+	if have := app.Run(context.Background()); !errors.Is(have, err) || have.Error() != "test: test" {
+		t.Errorf("wrong error, want 'test: test', have '%v'", have)
+	}
+
+	app = NewApp("test")
+	app.Add("stuck", withShutdown())
+	app.ShutdownTimeout = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if have := app.Run(ctx); !errors.Is(have, ErrTimeout) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrTimeout, have)
+	}
+}
+
+func AppProbesTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1    = withReadiness()
+		app    = NewApp("test")
+		probes = app.Probes()
+	)
+
+	app.Add("server", bg1)
+
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		probes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w.Code
+	}
+
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong readiness status before Run: %d", code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		_ = app.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(failTimeout / 10)
+
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong readiness status of not ready app: %d", code)
+	}
+
+	bg1.Ok()
+
+	if code := probe("/readyz"); code != http.StatusOK {
+		t.Errorf("wrong readiness status of ready app: %d", code)
+	}
+
+	if code := probe("/livez"); code != http.StatusOK {
+		t.Errorf("wrong liveness status: %d", code)
+	}
+
+	cancel()
+	<-done
+
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong readiness status of stopped app: %d", code)
+	}
+}
+
+func AppProbesDegradedTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithHealth()
+		app        = NewApp("test")
+		probes     = app.Probes()
+	)
+
+	app.Add("broker", bg1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		_ = app.Run(ctx)
+		close(done)
+	}()
+
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	time.Sleep(failTimeout / 10)
+
+	tail1.Degrade("1 of 3 brokers down")
+
+	w := httptest.NewRecorder()
+	probes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("wrong readiness status of degraded app: %d", w.Code)
+	}
+
+	if body, want := w.Body.String(), "degraded\ntest: 1 of 3 brokers down\n"; body != want {
+		t.Errorf("wrong readiness body, want %q, have %q", want, body)
+	}
+}
+
+// Health
+
+func HealthTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithHealth()
+		bg2, tail2 = WithHealth()
+		bg         = Merge(WithAnnotation("first", bg1), bg2)
+	)
+
+	if degradations := Degraded(bg); degradations != nil {
+		t.Errorf("unexpected degradations: %v", degradations)
+	}
+
+	tail1.Degrade("broker down")
+	tail2.Degrade("")
+
+	want := "[first: broker down degraded]"
+	if degradations := fmt.Sprint(Degraded(bg)); degradations != want {
+		t.Errorf("wrong degradations, want %s, have %s", want, degradations)
+	}
+
+	if !hasClosed(bg.Ready()) {
+		t.Error("degradation affected readiness")
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tail1.Recover()
+	tail2.Recover()
+
+	if degradations := Degraded(bg); degradations != nil {
+		t.Errorf("unexpected degradations after recovery: %v", degradations)
+	}
+}
+
+// Node
+
+func FindTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithShutdown()
+		bg2        = WithAnnotation("db", bg1)
+		bg3        = withReadiness()
+		bg         = WithAnnotation("api", bg3).DependsOn(bg2)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
+
+	if ID(bg1) == 0 || ID(bg1) == ID(bg2) || ID(Empty()) != 0 {
+		t.Errorf("wrong IDs: %d, %d, %d", ID(bg1), ID(bg2), ID(Empty()))
+	}
+
+	node, ok := FindByName(bg, "db")
+	if !ok || node.Background != bg2 || node.ID != ID(bg2) || node.Name != "db" {
+		t.Fatalf("wrong node found by name: %+v", node)
+	}
+
+	if node, ok := FindByID(bg, ID(bg1)); !ok || node.Background != bg1 || node.Name != "db" {
+		t.Errorf("wrong node found by ID: %+v", node)
+	}
+
+	if _, ok := FindByName(bg, "api: db"); ok {
+		t.Error("found not existing node")
+	}
+
+	if _, ok := FindByID(bg, 0); ok {
+		t.Error("found node with zero ID")
+	}
+
+	if nodes := node.Snapshot().Nodes; len(nodes) != 2 || nodes[0].ID != ID(bg2) || nodes[1].ID != ID(bg1) {
+		t.Errorf("wrong node snapshot: %+v", nodes)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := node.Background.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if bg3.IsClosing() {
+		t.Error("node's shutdown closed the rest of the tree")
+	}
+}
+
+// Event log
+
+func EventLogTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err        = errors.New("test")
+		bg1, tail1 = WithShutdown()
+		bg2, tail2 = WithErrorGroup()
+		bg         = WithEventLog(0, WithAnnotation("db", bg1, bg2))
+	)
+
+	go func() {
+		<-tail1.End()
+		tail2.Error(err)
+		tail1.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	var have []string
+	for _, e := range Events(bg) {
+		if e.Time.IsZero() || e.ID == 0 {
+			t.Errorf("event without time or ID: %+v", e)
+		}
+
+		have = append(have, fmt.Sprintf("%s %s %v", e.Kind, e.Name, e.Err))
+	}
+
+	want := []string{
+		"ready  <nil>",
+		"ready db <nil>",
+		"shutdown  <nil>",
+		"closing  <nil>",
+		"closing db <nil>",
+		"finished db db: test",
+		"finished  db: test",
+		"shutdown result  <nil>",
+	}
+
+	// the simultaneously closed children are not ordered
+	sort.Strings(have[3:5])
+	sort.Strings(want[3:5])
+
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong events, want:\n%s\nhave:\n%s", strings.Join(want, "\n"), strings.Join(have, "\n"))
+	}
+
+	if events := Events(Empty()); events != nil {
+		t.Errorf("unexpected events: %v", events)
+	}
+}
+
+func EventLogSizeTest(t *testing.T) {
+	t.Parallel()
+
+	bg := WithEventLog(2, WithAnnotation("a"), WithAnnotation("b"), WithAnnotation("c"))
+
+	var have []string
+	for _, e := range Events(bg) {
+		have = append(have, e.Kind.String()+" "+e.Name)
+	}
+
+	if want := []string{"ready b", "ready c"}; strings.Join(have, ", ") != strings.Join(want, ", ") {
+		t.Errorf("wrong events, want %v, have %v", want, have)
+	}
+}
+
+// Drain
+
+func DrainTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithDrain()
+		bg2, tail2 = WithShutdown()
+		bg         = bg1.DependsOn(bg2)
+		shutdownCh = make(chan error, 1)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
+
+	if hasClosed(tail1.Drain()) {
+		t.Error("drained before shutdown")
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+		defer cancel()
+
+		shutdownCh <- bg.Shutdown(ctx)
+	}()
+
+	// the drain begins with the shutdown of the tree,
+	// while the End waits for the dependency
+	select {
+	case <-tail1.Drain():
+	case <-time.After(failTimeout):
+		t.Fatal("not drained after shutdown began")
+	}
+
+	if hasClosed(tail1.End()) {
+		t.Error("ended before dependency was shut down")
+	}
+
+	<-tail2.End()
+	tail2.Done()
+
+	if err := <-shutdownCh; err != nil {
+		t.Error(errTimeout)
+	}
+}
+
+func DrainCloseTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithDrain()
+		bg         = WithAnnotation("server", bg1)
+	)
+
+	go func() {
+		<-tail1.Drain()
+		<-tail1.End()
+		tail1.Done()
+	}()
+
+	// the Background closed by its parent drains without announce
+	bg.(closer).close(context.Background())
+
+	if hasNotClosed(tail1.Drain(), bg1.Finished()) {
+		t.Error("drainable Background wasn't drained and closed")
+	}
+}
+
+// Message loop
+
+func MessageLoopTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		msgs      = make(chan interface{})
+		handling  = make(chan struct{})
+		release   = make(chan struct{})
+		handled   []interface{}
+		committed bool
+	)
+
+	bg := WithMessageLoop(
+		func(ctx context.Context) (interface{}, error) {
+			select {
+			case msg := <-msgs:
+				return msg, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+		func(ctx context.Context, msg interface{}) error {
+			if msg == 2 {
+				close(handling)
+				<-release
+			}
+
+			handled = append(handled, msg)
+
+			return nil
+		},
+		func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	)
+
+	msgs <- 1
+	msgs <- 2
+	<-handling
+
+	shutdownCh := make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+		defer cancel()
+
+		shutdownCh <- bg.Shutdown(ctx)
+	}()
+
+	// the in-flight message is handled to the end
+	time.Sleep(failTimeout / 10)
+	close(release)
+
+	if err := <-shutdownCh; err != nil {
+		t.Error(errTimeout)
+	}
+
+	if fmt.Sprint(handled) != "[1 2]" || !committed {
+		t.Errorf("wrong consumer state, handled %v, committed %t", handled, committed)
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func MessageLoopHandleErrorTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		err       = errors.New("test")
+		committed bool
+	)
+
+	bg := WithMessageLoop(
+		func(ctx context.Context) (interface{}, error) { return 1, nil },
+		func(ctx context.Context, msg interface{}) error { return err },
+		func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if haveErr := bg.Err(); !errors.Is(haveErr, err) || haveErr.Error() != "handle: test" {
+		t.Errorf("wrong error, want 'handle: test', have '%v'", haveErr)
+	}
+
+	if committed {
+		t.Error("committed after failed handle")
+	}
+}
+
+// Pool drain
+
+// testPool is a Pool with controllable number of connections in use.
+type testPool struct {
+	inUse    int32
+	closed   int32
+	closeErr error
+}
+
+func (p *testPool) InUse() int { return int(atomic.LoadInt32(&p.inUse)) }
+
+func (p *testPool) Close() error {
+	atomic.StoreInt32(&p.closed, 1)
+	return p.closeErr
+}
+
+func PoolDrainTest(t *testing.T) {
+	t.Parallel()
+
+	pool := &testPool{inUse: 2}
+	bg := WithPoolDrain(pool, 0)
+
+	go func() {
+		time.Sleep(failTimeout / 10)
+		atomic.StoreInt32(&pool.inUse, 0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if atomic.LoadInt32(&pool.closed) != 1 {
+		t.Error("pool wasn't closed")
+	}
+}
+
+func PoolDrainAbandonTest(t *testing.T) {
+	t.Parallel()
+
+	pool := &testPool{inUse: 3}
+	bg := WithAnnotation("redis", WithPoolDrain(pool, failTimeout/10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	want := "redis: pool closed with 3 connections in use: connections abandoned"
+	if err := bg.Err(); !errors.Is(err, ErrConnectionsAbandoned) || err.Error() != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
+	}
+
+	if atomic.LoadInt32(&pool.closed) != 1 {
+		t.Error("pool wasn't closed")
+	}
+}
+
+func PoolDrainAbandonCloseErrorTest(t *testing.T) {
+	t.Parallel()
+
+	closeErr := errors.New("test")
+	pool := &testPool{inUse: 1, closeErr: closeErr}
+	bg := WithPoolDrain(pool, failTimeout/10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	want := "pool closed with 1 connections in use: connections abandoned; close pool: test"
+	if err := bg.Err(); !errors.Is(err, ErrConnectionsAbandoned) || !errors.Is(err, closeErr) || err.Error() != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
+	}
+}
+
+// Topic
+
+func TopicTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		topic    = NewTopic()
+		release  = make(chan struct{})
+		received = make(chan interface{}, 10)
+	)
+
+	sub := topic.Subscribe(2, func(msg interface{}) {
+		<-release
+		received <- msg
+	})
+
+	if n := topic.Subscribers(); n != 1 {
+		t.Errorf("wrong number of subscribers, want 1, have %d", n)
+	}
+
+	// one message is being handled and two are queued
+	topic.Publish(1)
+	topic.Publish(2)
+	topic.Publish(3)
+
+	shutdownCh := make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+		defer cancel()
+
+		shutdownCh <- sub.Shutdown(ctx)
+	}()
+
+	close(release)
+
+	if err := <-shutdownCh; err != nil {
+		t.Error(errTimeout)
+	}
+
+	close(received)
+
+	var have []interface{}
+	for msg := range received {
+		have = append(have, msg)
+	}
+
+	if fmt.Sprint(have) != "[1 2 3]" {
+		t.Errorf("wrong received messages, want [1 2 3], have %v", have)
+	}
+
+	if n := topic.Subscribers(); n != 0 {
+		t.Errorf("wrong number of subscribers after shutdown, want 0, have %d", n)
+	}
+
+	// publishing without subscribers doesn't block
+	topic.Publish(4)
+}
+
+// Activity
+
+func IdleSinceTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithActivity()
+		bg2, tail2 = WithActivity()
+		bg         = Merge(bg1, bg2)
+	)
+
+	if _, ok := IdleSince(Empty()); ok {
+		t.Error("tree without activity reported idleness")
+	}
+
+	tail1.Touch()
+	time.Sleep(time.Millisecond)
+
+	before := time.Now()
+
+	tail2.Touch()
+
+	since, ok := IdleSince(bg)
+	if !ok || since.Before(before) {
+		t.Errorf("wrong idle time, want after %v, have %v", before, since)
+	}
+}
+
+func IdleTimeoutTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithActivity()
+		bg2, tail2 = WithShutdown()
+		bg         = WithIdleTimeout(failTimeout/5, bg1, bg2)
+		start      = time.Now()
+	)
+
+	go func() {
+		<-tail2.End()
+		tail2.Done()
+	}()
+
+	for time.Since(start) < failTimeout/2 {
+		tail1.Touch()
+		time.Sleep(failTimeout / 50)
+	}
+
+	if bg.IsClosing() {
+		t.Fatal("active Background closed")
+	}
+
+	select {
+	case <-bg2.Finished():
+	case <-time.After(failTimeout):
+		t.Fatal("idle Background didn't close")
+	}
+
+	if err := bg.Err(); err != ErrIdle {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrIdle, err)
+	}
+
+	if reason := bg2.(ShutdownTail).CloseInfo().Reason; reason != ErrIdle {
+		t.Errorf("wrong shutdown reason, want '%v', have '%v'", ErrIdle, reason)
+	}
+}
+
+// Deadman
+
+func DeadmanTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		expired    = make(chan struct{})
+		start      = time.Now()
+		bg1, tail1 = WithShutdown()
+		bg, tail   = WithDeadman(failTimeout/5, func() { close(expired) }, bg1)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
+
+	for time.Since(start) < failTimeout/2 {
+		tail.Reset()
+		time.Sleep(failTimeout / 50)
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(failTimeout):
+		t.Fatal("deadman switch didn't expire")
+	}
+
+	if err := bg.Err(); err != ErrDeadmanExpired {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrDeadmanExpired, err)
+	}
+
+	select {
+	case <-bg1.Finished():
+	case <-time.After(failTimeout):
+		t.Fatal("expired Background didn't close")
+	}
 
-	switch {
-	case hasNotClosed(bg2.ready, bg3.ready):
-		t.Error(errNotReady)
-	case hasClosed(bg1.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC):
-		t.Error(errReady)
+	if reason := tail1.CloseInfo().Reason; reason != ErrDeadmanExpired {
+		t.Errorf("wrong shutdown reason, want '%v', have '%v'", ErrDeadmanExpired, reason)
 	}
 
-	bg1.Ok()
-	time.Sleep(failTimeout)
+	// the expiration is reported to Await
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if hasNotClosed(bg1.ready, bg2.ready, bg3.ready, bg1ReadyC, bg2ReadyC, bg3ReadyC) {
-		t.Error(errNotReady)
+	if reason, err := Await(ctx, bg); reason != ReasonError || err != ErrDeadmanExpired {
+		t.Errorf("wrong await result: %v, %v", reason, err)
 	}
 }
 
-func ReadinessSuccessiveOkTest(t *testing.T) {
+func DeadmanAppTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("successive Ok call caused panic")
-		}
+	var (
+		app        = NewApp("")
+		bg1, tail1 = WithShutdown()
+		bg2, _     = WithDeadman(failTimeout/10, nil)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
 	}()
 
-	bg1 := withReadiness()
+	app.Add("sibling", bg1).Add("deadman", bg2)
 
-	bg1.Ok()
-	bg1.Ok()
-	bg1.Ok()
-	readyC := bg1.Ready()
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	time.Sleep(failTimeout)
+	if err := app.Run(ctx); err != ErrDeadmanExpired {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrDeadmanExpired, err)
+	}
 
-	if hasNotClosed(readyC) {
-		t.Error(errNotReady)
+	if hasNotClosed(bg1.Finished()) {
+		t.Error("sibling of the expired deadman switch is not closed")
+	}
+
+	if reason := tail1.CloseInfo().Reason; reason != ErrDeadmanExpired {
+		t.Errorf("wrong shutdown reason, want '%v', have '%v'", ErrDeadmanExpired, reason)
 	}
 }
 
-func ReadinessSuccessiveReadyTest(t *testing.T) {
+func DeadmanShutdownTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("successive Ready call caused panic")
-		}
-	}()
-
-	bg1 := withReadiness()
-	bg1.Ok()
-	readyC := bg1.Ready()
+	bg, _ := WithDeadman(failTimeout/10, func() { t.Error("stopped deadman switch expired") })
 
-	time.Sleep(failTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if hasNotClosed(readyC) {
-		t.Error(errNotReady)
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
 	}
 
-	readyC = bg1.Ready()
+	time.Sleep(failTimeout / 5)
 
-	if hasNotClosed(readyC) {
-		t.Error(errNotReady)
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-// Value
-
-type key string
+// Plan
 
-func ValueWrapTest(t *testing.T) {
+func PlanTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		testKey   = key("test_key")
-		testValue = "test_value"
-		bg1       = withValue(testKey, testValue)
-		bg2       = withWait(bg1)
-		bg3       = withWait(bg2)
+		db, t1     = WithShutdown()
+		cache, t2  = WithShutdown()
+		server, t3 = WithShutdown()
+		flush      = WithFinishedHook(func(ctx context.Context) error { return nil })
+		leave      = WithLeaveHook(func(ctx context.Context) error { return nil })
+		ingress    = WithPhase(0, WithAnnotation("ingress", leave))
+
+		bg = MergeSequential(
+			ingress,
+			WithAnnotation("server", server).DependsOn(
+				WithAnnotation("db", db),
+				WithAnnotation("cache", WithFinishedHook(func(ctx context.Context) error { return nil }, cache)),
+			),
+			WithAnnotation("flush", flush),
+		)
 	)
 
-	value := bg3.Value(testKey)
-	if value == nil {
-		t.Error("test value for test key not found")
+	defer endTails(t1, t2, t3)
+
+	var have []string
+	for _, step := range Plan(bg) {
+		have = append(have, fmt.Sprintf("%d %s", step.Stage, step.Name))
 	}
 
-	valueTyped, ok := value.(string)
-	if !ok {
-		t.Error("test value for test key has wrong type")
+	want := []string{
+		"0 ingress",
+		"1 db",
+		"1 cache",
+		"2 cache",
+		"3 server",
+		"4 flush",
 	}
 
-	if valueTyped != testValue {
-		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
+	if strings.Join(have, ", ") != strings.Join(want, ", ") {
+		t.Errorf("wrong plan, want %v, have %v", want, have)
+	}
+
+	if bg.IsClosing() {
+		t.Error("Plan began closing")
 	}
 }
 
-func ValueChildrenTest(t *testing.T) {
+// Readiness gate
+
+func RequireReadyTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		testKey1   = key("test_key1")
-		testValue1 = "test_value2"
-
-		testKey2   = key("test_key2")
-		testValue2 = "test_value2"
-
-		testKeyNotFound = key("test_key3")
-		bg1             = withValue(testKey1, testValue1)
-		bg2             = withValue(testKey2, testValue2, bg1)
+		bg1     = withReadiness()
+		bg2, _  = WithShutdown(bg1)
+		handler = RequireReady(bg2, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
 	)
 
-	value := bg2.Value(testKey1)
-	if value == nil {
-		t.Error("test value 1 for test key not found")
-	}
+	serve := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
 
-	valueTyped, ok := value.(string)
-	if !ok {
-		t.Error("test value 1 for test key has wrong type")
+		return w.Code
 	}
 
-	if valueTyped != testValue1 {
-		t.Errorf("wrong test value 1: want %s have %s", testValue1, valueTyped)
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong status before readiness: %d", code)
 	}
 
-	value2 := bg2.Value(testKey2)
-	if value2 == nil {
-		t.Error("test value 2 for test key not found")
-	}
+	bg1.Ok()
 
-	value2Typed, ok := value2.(string)
-	if !ok {
-		t.Error("test value 2 for test key has wrong type")
+	if code := serve(); code != http.StatusNoContent {
+		t.Errorf("wrong status after readiness: %d", code)
 	}
 
-	if value2Typed != testValue2 {
-		t.Errorf("wrong test value 2: want %s have %s", testValue1, valueTyped)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
 
-	value3 := bg2.Value(testKeyNotFound)
-	if value3 != nil {
-		t.Error("unused key returned non-nil value")
+	// the shutdown blocks, as the tail's Done is never called
+	_ = bg2.Shutdown(ctx)
+
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("wrong status during shutdown: %d", code)
 	}
 }
 
-func ValueNilPanicTest(t *testing.T) {
+// Checkout
+
+func CheckoutTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("nil key did not panic")
-		}
+	var (
+		bg1, tail1    = WithShutdown()
+		bg2, checkout = WithCheckout(bg1)
+		shutdownCh    = make(chan error, 1)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
 	}()
 
-	_ = withValue(nil, "")
+	release, err := checkout.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+		defer cancel()
+
+		shutdownCh <- bg2.Shutdown(ctx)
+	}()
+
+	time.Sleep(failTimeout / 10)
+
+	if _, err := checkout.Acquire(context.Background()); err != ErrClosing {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrClosing, err)
+	}
+
+	// the children are closed after the in-flight work
+	if hasClosed(bg1.Closing()) {
+		t.Error("children closed before in-flight work was complete")
+	}
+
+	release()
+	release()
+
+	if err := <-shutdownCh; err != nil {
+		t.Error(errTimeout)
+	}
 }
 
-func ValueComparablePanicTest(t *testing.T) {
+func CheckoutTimeoutTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("incomparable key did not panic")
-		}
-	}()
+	bg, checkout := WithCheckout()
 
-	_ = withValue(func() {}, "")
+	if _, err := checkout.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
+
+	err := bg.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) || err.Error() != "1 in-flight: timeout expired" {
+		t.Errorf("wrong error, want '1 in-flight: timeout expired', have '%v'", err)
+	}
 }
 
-// Annotate
+// Shutdown during startup
 
-func AnnotationErrorTest(t *testing.T) {
+func StartupShutdownTest(t *testing.T) {
 	t.Parallel()
 
-	const annotation = "test"
-
 	var (
-		err1 = errors.New("error1")
-		err2 = errors.New("error2")
-
-		bg1 = withError(err1)
-		bg2 = withError(err2, bg1)
-		bg3 = withAnnotation(annotation, bg2)
+		bg1, tail1 = WithShutdown()
+		bg2, _     = WithReadiness()
+		skipped    = make(chan struct{})
+		bg3        = ReadyThen(bg2, func(ctx context.Context) error {
+			close(skipped)
+			return nil
+		})
+		bg = Merge(bg1, bg2, bg3)
 	)
 
-	err := bg3.Err()
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
 
-	// err2 is higher in the tree so it must be found first
-	if !errors.Is(err, err2) {
-		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 
-	wantErrStr := fmt.Sprintf("%s: %s", annotation, err2.Error())
+	if !tail1.CloseInfo().DuringStartup {
+		t.Error("shutdown is not reported as during startup")
+	}
 
-	if err.Error() != wantErrStr {
-		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	if hasClosed(skipped) {
+		t.Error("ReadyThen job is not skipped")
 	}
 
-	err = bg1.Err()
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	// the shutdown of a ready tree is not during startup
+	bg4, tail4 := WithShutdown()
+
+	go func() {
+		<-tail4.End()
+		tail4.Done()
+	}()
+
+	if err := bg4.Shutdown(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if tail4.CloseInfo().DuringStartup {
+		t.Error("shutdown of ready tree is reported as during startup")
 	}
 }
 
-func AnnotationShutdownTimeoutTest(t *testing.T) {
+func StartupShutdownReportTest(t *testing.T) {
 	t.Parallel()
 
-	const annotation = "test"
-
-	bg1 := withShutdown()
-	bg2 := withAnnotation(annotation, bg1)
-
-	// blocked finish
-	_ = runShutdownable(bg1)
+	var (
+		bg1, _ = WithReadiness()
+		bg2    = WithOptions(Options{ReportStartup: true}, bg1)
+		bg3    = WithOptions(Options{ReportStartup: true}, Empty())
+	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
 	defer cancel()
 
-	err := bg2.Shutdown(ctx)
-	if !errors.Is(err, ErrTimeout) {
-		t.Errorf("blocked shutdown didn't timeout")
+	if err := bg2.Shutdown(ctx); err != ErrShutdownDuringStartup {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrShutdownDuringStartup, err)
 	}
 
-	wantErrStr := fmt.Sprintf("%s: %s", annotation, ErrTimeout.Error())
-
-	if err.Error() != wantErrStr {
-		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	if err := bg3.Shutdown(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-func AnnotationChildShutdownTimeoutTest(t *testing.T) {
+func StartupShutdownReportTimeoutTest(t *testing.T) {
 	t.Parallel()
 
-	const annotation = "test"
-
-	bg1 := withShutdown()
-	bg2 := withAnnotation(annotation, bg1)
-	bg3 := withShutdown(bg2)
-
-	// blocked finish
-	_ = runShutdownable(bg1)
-	_ = runShutdownable(bg3)
+	var (
+		bg1, _ = WithShutdown()
+		bg2, _ = WithReadiness(bg1)
+		bg3    = WithOptions(Options{ReportStartup: true}, bg2)
+	)
 
-	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
 	defer cancel()
 
 	err := bg3.Shutdown(ctx)
-	if !errors.Is(err, ErrTimeout) {
-		t.Errorf("blocked shutdown didn't timeout")
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrShutdownDuringStartup) {
+		t.Errorf("wrong error, want '%v' and '%v', have '%v'", ErrTimeout, ErrShutdownDuringStartup, err)
 	}
 
-	wantErrStr := fmt.Sprintf("%s: %s", annotation, ErrTimeout.Error())
-
-	if err.Error() != wantErrStr {
-		t.Errorf("timeout error is not annotated, want error '%s', have '%s'", wantErrStr, err.Error())
+	if want := "shutdown during startup: timeout expired"; errText(err) != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
 	}
 }
 
-func AnnotationNilErrorTest(t *testing.T) {
+func StartupShutdownReportTimeoutAnnotatedTest(t *testing.T) {
 	t.Parallel()
 
-	const message = "test"
+	var (
+		bg1, _ = WithShutdown()
+		bg2, _ = WithReadiness(WithAnnotation("test", bg1))
+		bg3    = WithOptions(Options{ReportStartup: true}, bg2)
+	)
 
-	bg1 := withError(nil)
-	bg2 := withAnnotation(message, bg1)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
 
-	if err := bg2.Err(); err != nil {
-		t.Errorf("annotated Background returned error instead of nil")
+	err := bg3.Shutdown(ctx)
+	if want := "test: shutdown during startup: timeout expired"; err == nil || errText(err) != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
 	}
 }
 
-func AnnotationNilShutdownErrorTest(t *testing.T) {
-	t.Parallel()
+// Startup
 
-	bg1 := withShutdown()
-	bg2 := withAnnotation("", bg1)
+// startupStep returns the init step that appends name to order when its
+// Background is closed.
+func startupStep(name string, order *[]string, mu *sync.Mutex) func(ctx context.Context) (Background, error) {
+	return func(ctx context.Context) (Background, error) {
+		bg, tail := WithShutdown()
 
-	// blocked finish
-	okDone1 := runShutdownable(bg1)
+		go func() {
+			<-tail.End()
 
-	closeChanAndPropagate(okDone1)
+			mu.Lock()
+			*order = append(*order, name)
+			mu.Unlock()
+
+			tail.Done()
+		}()
+
+		return bg, nil
+	}
+}
+
+func StartupTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+
+	bg, err := Startup(context.Background(),
+		startupStep("db", &order, &mu),
+		func(ctx context.Context) (Background, error) { return nil, nil },
+		startupStep("server", &order, &mu),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
 	defer cancel()
 
-	if err := bg2.Shutdown(ctx); err != nil {
-		t.Errorf("annotation Background returned error instead of nil")
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if have := fmt.Sprint(order); have != "[server db]" {
+		t.Errorf("wrong shutdown order, want '[server db]', have '%s'", have)
 	}
 }
 
-func AnnotationUnclosedTest(t *testing.T) {
+func StartupAbortTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = withAnnotation("", bg1)
+		order []string
+		mu    sync.Mutex
+		fail  = errors.New("broker unavailable")
+		ran   bool
 	)
 
-	okDone1 := runShutdownable(bg1)
+	bg, err := Startup(context.Background(),
+		startupStep("db", &order, &mu),
+		startupStep("cache", &order, &mu),
+		func(ctx context.Context) (Background, error) { return nil, fail },
+		func(ctx context.Context) (Background, error) {
+			ran = true
+			return nil, nil
+		},
+	)
 
-	closeChanAndPropagate(okDone1)
+	if bg != nil {
+		t.Error("non-nil Background after failed startup")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
-	defer cancel()
+	if !errors.Is(err, fail) || err.Error() != "step 3: broker unavailable" {
+		t.Errorf("wrong error, want 'step 3: broker unavailable', have '%v'", err)
+	}
 
-	err := bg2.Shutdown(ctx)
-	if err != nil {
-		t.Errorf(errTimeout)
+	if ran {
+		t.Error("step after failed one was run")
 	}
 
-	if err := bg1.cause(); err != nil {
-		t.Errorf(errNotFinished)
+	if have := fmt.Sprint(order); have != "[cache db]" {
+		t.Errorf("wrong teardown order, want '[cache db]', have '%s'", have)
 	}
 
-	if err := bg2.cause(); err != nil {
-		t.Errorf(errNotFinished)
+	// canceled ctx stops the startup before the next step
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err = Startup(ctx, func(ctx context.Context) (Background, error) {
+		cancel()
+		return nil, nil
+	}, func(ctx context.Context) (Background, error) {
+		ran = true
+		return nil, nil
+	})
+
+	if !errors.Is(err, context.Canceled) || ran {
+		t.Errorf("wrong error, want '%v', have '%v'", context.Canceled, err)
 	}
 }
 
-// Error
+// Teardown stack
 
-func ErrorTest(t *testing.T) {
+func TeardownStackTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		err1 = errors.New("error1")
-		err2 = errors.New("error2")
-
-		bg1 = withError(err1)
-		bg2 = withError(err2, bg1)
+		order      []string
+		mu         sync.Mutex
+		bg, stack  = WithTeardownStack()
+		errs, tail = WithErrorGroup()
+		fail       = errors.New("fail")
 	)
 
-	err := bg2.Err()
+	for _, name := range []string{"db", "cache", "server"} {
+		bg, err := startupStep(name, &order, &mu)(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
-	// err2 is higher in the tree so it must be found first
-	if !errors.Is(err, err2) {
-		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+		stack.Push(bg)
 	}
 
-	err = bg1.Err()
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	stack.Push(WithValue("key", "value", errs))
+	tail.Error(fail)
+
+	if err := bg.Err(); err != fail {
+		t.Errorf("wrong error, want '%v', have '%v'", fail, err)
 	}
-}
 
-// Error group
+	if value := bg.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
 
-func ErrorGroupTest(t *testing.T) {
-	t.Parallel()
+	// the stack can be embedded in a larger tree
+	parent := Merge(bg)
 
-	const annotation = "test"
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := parent.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if have := fmt.Sprint(order); have != "[server cache db]" {
+		t.Errorf("wrong shutdown order, want '[server cache db]', have '%s'", have)
+	}
+}
+
+func TeardownStackControlsTest(t *testing.T) {
+	t.Parallel()
 
 	var (
-		err1 = errors.New("error1")
-		err2 = errors.New("error2")
-		bg1  = withErrorGroup()
-		bg2  = withAnnotation(annotation, bg1)
+		component = newControlled()
+		bg, stack = WithTeardownStack()
 	)
 
-	if err := bg2.Err(); err != nil {
-		t.Errorf("new error group Background returned error")
-	}
+	component.checkControls(t, bg, false)
 
-	go func() {
-		bg1.Error(err1)
-	}()
+	stack.Push(Empty())
+	stack.Push(component)
 
-	time.Sleep(failTimeout)
+	component.checkControls(t, bg, true)
+}
 
-	err := bg2.Err()
+func TeardownStackClosedTest(t *testing.T) {
+	t.Parallel()
 
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
-	}
+	var (
+		bg1, stack = WithTeardownStack()
+		bg2, tail2 = WithShutdown()
+	)
 
-	wantErrStr := fmt.Sprintf("%s: %s", annotation, err1.Error())
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if err.Error() != wantErrStr {
-		t.Errorf("error group error is not annotated, want '%s', have '%s'", wantErrStr, err.Error())
+	if err := bg1.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
 	}
 
 	go func() {
-		bg1.Error(err2)
+		<-tail2.End()
+		tail2.Done()
 	}()
 
-	time.Sleep(failTimeout)
-
-	err = bg2.Err()
+	// pushed after the closing, bg2 is closed right away
+	stack.Push(bg2)
 
-	if errors.Is(err, err2) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err2)
+	if hasNotClosed(bg2.Finished()) {
+		t.Error("Background pushed after closing is not finished")
 	}
 }
 
-func ErrorGroupErrorfTest(t *testing.T) {
-	t.Parallel()
+// All errors
 
-	const annotation = "test: %w"
+func MergeAllErrorsTest(t *testing.T) {
+	t.Parallel()
 
 	var (
-		err1 = errors.New("error1")
-		err2 = errors.New("error2")
-		bg1  = withErrorGroup()
-		bg2  = merge(bg1)
+		bg1, tail1 = WithErrorGroup()
+		bg2, tail2 = WithErrorGroup()
+		bg3, _     = WithErrorGroup()
+		bg         = MergeAllErrors(WithAnnotation("db", bg1), bg3, WithAnnotation("broker", bg2))
+		fail       = errors.New("connection refused")
+		timeout    = errors.New("timeout")
 	)
 
-	if err := bg2.Err(); err != nil {
-		t.Errorf("new error group Background returned error")
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 
-	go func() {
-		bg1.Errorf(annotation, err1)
-	}()
-
-	time.Sleep(failTimeout)
-
-	err := bg2.Err()
+	tail2.Error(fail)
 
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	if err := bg.Err(); err == nil || err.Error() != "broker: connection refused" {
+		t.Errorf("wrong error, want 'broker: connection refused', have '%v'", err)
 	}
 
-	wantErrStr := fmt.Sprintf("%s: %s", "test", err1.Error())
+	tail1.Error(timeout)
 
-	if err.Error() != wantErrStr {
-		t.Errorf("error group error is not annotated, want '%s', have '%s'", wantErrStr, err.Error())
+	var merged *MergedErrors
+
+	err := bg.Err()
+	if !errors.As(err, &merged) || len(merged.Errs) != 2 {
+		t.Fatalf("wrong error, want MergedErrors with 2 errors, have '%v'", err)
 	}
 
-	go func() {
-		bg1.Errorf(annotation, err2)
-	}()
+	if want := "db: timeout; broker: connection refused"; err.Error() != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
+	}
 
-	time.Sleep(failTimeout)
+	if !errors.Is(err, fail) || !errors.Is(err, timeout) {
+		t.Error("MergedErrors doesn't match children's errors")
+	}
+}
 
-	err = bg2.Err()
+// Error matching
 
-	if errors.Is(err, err2) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err2)
-	}
+type codeError struct {
+	code int
 }
 
-// Empty
+func (e *codeError) Error() string { return fmt.Sprintf("code %d", e.code) }
 
-func EmptyTest(t *testing.T) {
+func ErrorsAsTest(t *testing.T) {
 	t.Parallel()
 
-	bg1 := emptyBackground{}
+	var (
+		bg1, tail1 = WithErrorGroup()
+		bg2, tail2 = WithErrorGroup()
+		bg3, tail3 = WithErrorGroup()
+		bg         = Merge(
+			WithAnnotation("db", bg1),
+			WithAnnotation("broker", WithAnnotation("consumer", bg2), bg3),
+		)
+		codes []*codeError
+	)
 
-	if err := bg1.Err(); err != nil {
-		t.Errorf("empty Background returned error")
+	if ErrorsAs(bg, &codes) || len(codes) != 0 {
+		t.Errorf("unexpected errors: %v", codes)
 	}
 
-	if err := bg1.Shutdown(context.Background()); err != nil {
-		t.Errorf("empty Background shutdowned with error")
+	tail1.Error(&codeError{code: 1})
+	tail2.Error(fmt.Errorf("wrapped: %w", &codeError{code: 2}))
+	tail3.Error(errors.New("other"))
+
+	if !ErrorsAs(bg, &codes) || fmt.Sprint(codes) != "[code 1 code 2]" {
+		t.Errorf("wrong errors, want '[code 1 code 2]', have '%v'", codes)
 	}
 
-	okDone1 := make(chan struct{})
+	var all []error
 
-	go func() {
-		bg1.Wait()
-		close(okDone1)
+	ErrorsAs(bg, &all)
+
+	want := "[db: code 1 broker: consumer: wrapped: code 2 broker: other]"
+	if have := fmt.Sprint(all); have != want {
+		t.Errorf("wrong errors, want '%s', have '%s'", want, have)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic on invalid target")
+		}
 	}()
 
-	time.Sleep(failTimeout)
+	ErrorsAs(bg, codes)
+}
 
-	if hasNotClosed(okDone1) {
-		t.Errorf("empty Background blocked on wait")
-	}
+func ErrorsAsOwnErrorTest(t *testing.T) {
+	t.Parallel()
 
-	readyC := bg1.Ready()
-	if hasNotClosed(readyC) {
-		t.Errorf("empty Background is not ready")
-	}
+	var (
+		own   = &codeError{code: 1}
+		child = &codeError{code: 2}
+		bg    = WithError(own, WithAnnotation("child", WithError(child)))
+		codes []*codeError
+	)
 
-	if value := bg1.Value(""); value != nil {
-		t.Errorf("empty Background returned value")
+	if !ErrorsAs(bg, &codes) || fmt.Sprint(codes) != "[code 1 code 2]" {
+		t.Errorf("wrong errors, want '[code 1 code 2]', have '%v'", codes)
 	}
+}
 
-	okDone2 := make(chan struct{})
-
-	go func() {
-		bg1.close()
-		close(okDone2)
-	}()
+func FirstErrorTest(t *testing.T) {
+	t.Parallel()
 
-	time.Sleep(failTimeout)
+	var (
+		bg1, tail1 = WithErrorGroup()
+		bg2, tail2 = WithErrorGroup()
+		bg         = Merge(bg1, WithAnnotation("cache", bg2))
+		fail       = errors.New("evicted")
+	)
 
-	if hasNotClosed(okDone2) {
-		t.Errorf("empty Background blocked on close")
-	}
+	tail1.Error(errors.New("other"))
+	tail2.Error(fail)
 
-	if hasNotClosed(bg1.finishSig()) {
-		t.Errorf("empty Background is not done")
+	err := FirstError(bg, func(err error) bool { return errors.Is(err, fail) })
+	if !errors.Is(err, fail) || err.Error() != "cache: evicted" {
+		t.Errorf("wrong error, want 'cache: evicted', have '%v'", err)
 	}
 
-	if err := bg1.cause(); err != nil {
-		t.Errorf("empty Background cause call returned error")
+	if err := FirstError(bg, func(err error) bool { return false }); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-// Dependency
+// Shutdown report
 
-func DependencyShutdownTest(t *testing.T) {
+func ReportTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = withShutdown()
-		bg3 = withShutdown()
-
-		okDone1 = runShutdownable(bg1)
-		okDone2 = runShutdownable(bg2)
-		okDone3 = runShutdownable(bg3)
+		bg1, tail1 = WithShutdown()
+		bg2, _     = WithShutdown()
+		bg3, _     = WithShutdown()
+		bg         = Merge(
+			WithAnnotation("cache", bg1),
+			MergeSequential(WithAnnotation("db", bg2), WithAnnotation("broker", bg3)),
+		)
 	)
 
-	bg4 := withDependency(bg3, bg1, bg2)
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
 
-	if hasClosed(
-		bg1.end, bg2.end, bg3.end,
-		bg1.done, bg2.done, bg3.done,
-	) {
-		t.Error(errInitClosed)
+	r := Report(bg)
+	if len(r.Completed) != 0 || len(r.TimedOut) != 0 || len(r.NotReached) != 3 {
+		t.Errorf("wrong report before shutdown: %s", r)
 	}
 
-	go bg4.close()
-	time.Sleep(failTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
 
-	switch {
-	case hasNotClosed(bg1.end, bg2.end):
-		t.Error(errNotClosed)
-	case hasClosed(bg3.end):
-		t.Error(errClosed)
-	case hasClosed(bg1.done, bg2.done, bg3.done):
-		t.Error(errFinished)
+	if err := bg.Shutdown(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrTimeout, err)
 	}
 
-	closeChanAndPropagate(okDone1, okDone2)
+	r = Report(bg)
 
-	switch {
-	case hasNotClosed(bg3.end):
-		t.Error(errNotClosed)
-	case hasNotClosed(bg1.done, bg2.done):
-		t.Error(errNotFinished)
-	case hasClosed(bg3.done):
-		t.Error(errFinished)
+	want := `completed: 1, timed out: 1, not reached: 1
+timed out: *background.shutdownBackground "db"
+not reached: *background.shutdownBackground "broker"`
+	if have := r.String(); have != want {
+		t.Errorf("wrong report, want:\n%s\nhave:\n%s", want, have)
 	}
 
-	closeChanAndPropagate(okDone3)
-
-	if hasNotClosed(bg3.done) {
-		t.Error(errNotFinished)
+	if len(r.Completed) != 1 || r.Completed[0].Name != "cache" || r.Completed[0].ID != ID(bg1) {
+		t.Errorf("wrong completed nodes: %v", r.Completed)
 	}
 }
 
-func DependencyShutdownChainTest(t *testing.T) {
+// Wait watchdog
+
+func WaitWithWatchdogTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = withShutdown()
-		bg3 = withShutdown()
-
-		okDone1 = runShutdownable(bg1)
-		okDone2 = runShutdownable(bg2)
-		okDone3 = runShutdownable(bg3)
+		bg1, tail1 = WithWait()
+		bg2, _     = WithWait()
+		bg         = Merge(WithAnnotation("workers", bg1), bg2)
+		stalls     = make(chan []string, 10)
+		done       = make(chan struct{})
 	)
 
-	bg4 := withDependency(bg3, bg1)
-	bg4 = withDependency(bg4, bg2)
+	tail1.Add(2)
 
-	if hasClosed(
-		bg1.end, bg2.end, bg3.end,
-		bg1.done, bg2.done, bg3.done, bg4.finished,
-	) {
-		t.Error(errInitClosed)
+	go func() {
+		WaitWithWatchdog(bg, failTimeout/10, func(pending []string) { stalls <- pending })
+		close(done)
+	}()
+
+	select {
+	case pending := <-stalls:
+		if have := fmt.Sprint(pending); have != "[workers: 2 pending]" {
+			t.Errorf("wrong pending, want '[workers: 2 pending]', have '%s'", have)
+		}
+	case <-time.After(failTimeout):
+		t.Fatal("stall is not reported")
 	}
 
-	go bg4.close()
-	time.Sleep(failTimeout)
+	tail1.Done()
+	tail1.Done()
 
-	switch {
-	case hasNotClosed(bg2.end):
-		t.Error(errNotClosed)
-	case hasClosed(bg1.end, bg3.end):
-		t.Error(errClosed)
-	case hasClosed(bg1.done, bg2.done, bg3.done, bg4.finished):
-		t.Error(errFinished)
+	select {
+	case <-done:
+	case <-time.After(failTimeout):
+		t.Error(errTimeout)
 	}
+}
 
-	closeChanAndPropagate(okDone2)
+// Shutdown delay
 
-	switch {
-	case hasNotClosed(bg1.end):
-		t.Error(errNotClosed)
-	case hasNotClosed(bg2.done):
-		t.Error(errNotFinished)
-	case hasClosed(bg3.end):
-		t.Error(errClosed)
-	case hasClosed(bg1.done, bg3.done, bg4.finished):
-		t.Error(errFinished)
+func ShutdownDelayTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithShutdown()
+		bg2        = WithShutdownDelay(failTimeout/2, WithAnnotation("server", bg1))
+		shutdownCh = make(chan error, 1)
+	)
+
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
+
+	if have := fmt.Sprint(Plan(bg2)); have != `[0: *background.delayBackground 1: *background.shutdownBackground "server"]` {
+		t.Errorf("wrong plan: %s", have)
 	}
 
-	closeChanAndPropagate(okDone1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), failTimeout*2)
+		defer cancel()
 
-	switch {
-	case hasNotClosed(bg3.end):
-		t.Error(errNotClosed)
-	case hasNotClosed(bg1.done):
-		t.Error(errNotFinished)
-	case hasClosed(bg3.done, bg4.finished):
-		t.Error(errFinished)
+		shutdownCh <- bg2.Shutdown(ctx)
+	}()
+
+	time.Sleep(failTimeout / 10)
+
+	if hasNotClosed(bg2.Closing()) {
+		t.Error("closing didn't begin during delay")
 	}
 
-	closeChanAndPropagate(okDone3)
+	if hasClosed(tail1.End()) {
+		t.Error("children closed during delay")
+	}
 
-	if hasNotClosed(bg3.done, bg4.finished) {
-		t.Error(errNotFinished)
+	if r := Report(bg2); len(r.TimedOut) != 1 || len(r.NotReached) != 1 {
+		t.Errorf("wrong report during delay: %s", r)
+	}
+
+	if err := <-shutdownCh; err != nil {
+		t.Error(errTimeout)
 	}
 }
 
-func DependencyShutdownSuccessiveCloseTest(t *testing.T) {
+func ShutdownDelayTimeoutTest(t *testing.T) {
 	t.Parallel()
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("successive close call caused panic")
-		}
-	}()
-
 	var (
-		bg1 = withShutdown()
-		bg2 = withShutdown()
+		bg1, _ = WithShutdown()
+		bg2    = WithShutdownDelay(time.Minute, bg1)
 	)
 
-	close(runShutdownable(bg1))
-	close(runShutdownable(bg2))
-
-	bg4 := withDependency(bg1, bg2)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout/10)
+	defer cancel()
 
-	go bg4.close()
-	time.Sleep(failTimeout)
+	err := bg2.Shutdown(ctx)
+	if !errors.Is(err, ErrTimeout) || err.Error() != "shutdown delay 1m0s: timeout expired" {
+		t.Errorf("wrong error, want 'shutdown delay 1m0s: timeout expired', have '%v'", err)
+	}
 
-	bg4.close()
+	// the children are closed right away after the shutdown's ctx is done
+	select {
+	case <-bg1.Closing():
+	case <-time.After(failTimeout):
+		t.Error("children not closed after timeout")
+	}
 }
 
-func DependencyShutdownChildrenTimeoutTest(t *testing.T) {
+// Serve
+
+func ServeTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = emptyBackground{}
-		bg3 = bg1.DependsOn(bg2)
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		bg     = Serve(func() error { return server.Config.Serve(server.Listener) }, server.Config.Shutdown, func(err error) bool {
+			return errors.Is(err, http.ErrServerClosed)
+		})
 	)
 
-	// blocked finish
-	_ = runShutdownable(bg1)
+	resp, err := http.Get("http://" + server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp.Body.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
 	defer cancel()
 
-	err := bg3.Shutdown(ctx)
-	if !errors.Is(err, ErrTimeout) {
-		t.Errorf("blocked shutdown didn't timeout")
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-func DependencyShutdownParentTimeoutTest(t *testing.T) {
+func ServeFailureTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = withShutdown()
-		bg3 = bg1.DependsOn(bg2)
+		fail    = errors.New("address in use")
+		stopped bool
+		bg      = Serve(func() error { return fail }, func(ctx context.Context) error {
+			stopped = true
+			return nil
+		}, nil)
 	)
 
-	okDone1 := runShutdownable(bg1)
-	_ = runShutdownable(bg2)
+	awaitCtx, cancelAwait := context.WithTimeout(context.Background(), failTimeout)
+	defer cancelAwait()
 
-	closeChanAndPropagate(okDone1)
+	// Await returns as soon as the server fails
+	if _, err := Await(awaitCtx, bg); !errors.Is(err, fail) || err.Error() != "serve: address in use" {
+		t.Errorf("wrong error, want 'serve: address in use', have '%v'", err)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
 	defer cancel()
 
-	err := bg3.Shutdown(ctx)
-	if !errors.Is(err, ErrTimeout) {
-		t.Errorf("blocked shutdown didn't timeout")
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if stopped {
+		t.Error("stop called after start returned")
+	}
+
+	// the errors of stop are returned by Err
+	var (
+		release = make(chan struct{})
+		bg2     = Serve(func() error {
+			<-release
+			return nil
+		}, func(ctx context.Context) error {
+			close(release)
+			return fail
+		}, nil)
+	)
+
+	if err := bg2.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if err := bg2.Err(); !errors.Is(err, fail) || err.Error() != "stop: address in use" {
+		t.Errorf("wrong error, want 'stop: address in use', have '%v'", err)
 	}
 }
 
-func DependencyShutdownUnclosedTest(t *testing.T) {
+// Keyed error group
+
+func KeyedErrorGroupTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withShutdown()
-		bg2 = withShutdown()
-		bg3 = withDependency(bg1, bg2)
+		bg1, tail1 = WithErrorGroup()
+		bg2, tail2 = WithKeyedErrorGroup(bg1)
+		bg         = WithAnnotation("consumers", bg2)
+		fail1      = errors.New("fail 1")
+		fail3      = errors.New("fail 3")
 	)
 
-	// blocked finish
-	okDone2 := runShutdownable(bg2)
-	okDone1 := runShutdownable(bg1)
+	tail1.Error(errors.New("child"))
 
-	closeChanAndPropagate(okDone1, okDone2)
+	if err := bg2.Err(); err == nil || err.Error() != "child" {
+		t.Errorf("wrong error, want 'child', have '%v'", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
-	defer cancel()
+	tail2.ErrorFor("consumer-3", fail3)
+	tail2.ErrorFor("consumer-1", nil)
+	tail2.ErrorFor("consumer-1", fail1)
+	tail2.ErrorFor("consumer-1", errors.New("dropped"))
 
-	err := bg3.Shutdown(ctx)
-	if err != nil {
-		t.Errorf(errTimeout)
+	want := "consumers: consumer-3: fail 3; consumer-1: fail 1"
+	if err := bg.Err(); !errors.Is(err, fail1) || !errors.Is(err, fail3) || err.Error() != want {
+		t.Errorf("wrong error, want '%s', have '%v'", want, err)
 	}
 
-	if err := bg3.cause(); err != nil {
-		t.Errorf(errNotFinished)
+	if err := ErrFor(bg, "consumer-1"); err != fail1 {
+		t.Errorf("wrong error, want '%v', have '%v'", fail1, err)
+	}
+
+	if err := ErrFor(bg, "consumer-2"); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 }
 
-func DependencyWaitTest(t *testing.T) {
+// Tail close
+
+func TailCloseTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withWait()
-		bg2 = withWait()
-		bg3 = withWait()
+		bg1, tail1 = WithShutdown()
+		bg2, tail2 = WithReadiness(bg1)
+		bg3, tail3 = WithShutdown()
+		bg         = Merge(bg2, bg3)
+	)
 
-		okDone1 = runWaitable(bg1)
-		okDone2 = runWaitable(bg2)
-		okDone3 = runWaitable(bg3)
+	// the feature is disabled, the jobs are not started
+	tail1.Add(2)
+	tail1.Close()
+	tail2.Close()
 
-		bg4 = bg3.DependsOn(bg1, bg2)
-	)
+	if hasNotClosed(bg.Ready()) {
+		t.Error("closed tails block the tree")
+	}
 
-	done := make(chan struct{})
+	if hasClosed(bg1.Finished()) {
+		t.Error(errFinished)
+	}
 
 	go func() {
-		bg4.Wait()
-		close(done)
+		<-tail3.End()
+		tail3.Done()
 	}()
 
-	time.Sleep(failTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if hasClosed(done) {
-		t.Error(errNotWaited)
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
 	}
 
-	closeChanAndPropagate(okDone1, okDone2)
-
-	if hasClosed(done) {
-		t.Error(errNotWaited)
+	if hasNotClosed(bg1.Finished()) {
+		t.Error(errNotFinished)
 	}
 
-	closeChanAndPropagate(okDone3)
+	// closing after the shutdown does nothing
+	tail1.Close()
+	tail2.Close()
+}
 
-	if hasNotClosed(done) {
-		t.Error(errNotWaited)
+func TailCloseChildrenTest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		bg1, tail1 = WithShutdown()
+		bg2, tail2 = WithShutdown(bg1)
+		bg         = Merge(bg2)
+	)
+
+	okDone := runShutdownable(tail1)
+
+	// the job of bg2 is not started, but its child still has to be shut down
+	tail2.Close()
+
+	go closeChanAndPropagate(okDone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if hasNotClosed(tail1.End(), bg1.Finished(), bg2.Finished()) {
+		t.Error(errNotFinished)
 	}
 }
 
-func DependencyReadinessTest(t *testing.T) {
+// Feature-flagged components
+
+func IfTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = withReadiness()
-		bg2 = withReadiness()
-		bg3 = withReadiness()
+		called  bool
+		bg1, t1 = WithShutdown()
+		enabled = If(true, func() Background { return bg1 })
+		nilBg   = If(true, func() Background { return nil })
+		bg      = Merge(
+			WithAnnotation("tracing", If(false, func() Background {
+				called = true
+				return Empty()
+			})),
+			WithAnnotation("metrics", If(false, nil)),
+		)
 	)
 
-	bg4 := withDependency(bg3, bg1, bg2)
+	defer endTails(t1)
 
-	if hasClosed(
-		bg1.ready, bg2.ready, bg3.ready,
-	) {
-		t.Error(errInitReady)
+	if called {
+		t.Error("disabled component is constructed")
 	}
 
-	bg1ReadyC := bg1.Ready()
-	bg2ReadyC := bg2.Ready()
-	bg3ReadyC := bg3.Ready()
-	bg4ReadyC := bg4.Ready()
-
-	bg1.Ok()
+	if enabled != bg1 || nilBg != Empty() {
+		t.Error("enabled component is not returned")
+	}
 
-	time.Sleep(failTimeout)
+	var disabled []string
 
-	switch {
-	case hasNotClosed(bg1.ready, bg1ReadyC):
-		t.Error(errNotReady)
-	case hasClosed(bg2.ready, bg3.ready, bg2ReadyC, bg3ReadyC, bg4ReadyC):
-		t.Error(errReady)
+	for _, node := range Snapshot(bg).Nodes {
+		if node.Disabled {
+			disabled = append(disabled, node.Name)
+		}
 	}
 
-	bg2.Ok()
-	time.Sleep(failTimeout)
+	if have := fmt.Sprint(disabled); have != "[tracing metrics]" {
+		t.Errorf("wrong disabled nodes, want '[tracing metrics]', have '%s'", have)
+	}
 
-	switch {
-	case hasNotClosed(bg1.ready, bg1ReadyC, bg2.ready, bg2ReadyC):
-		t.Error(errNotReady)
-	case hasClosed(bg3.ready, bg3ReadyC, bg4ReadyC):
-		t.Error(errReady)
+	if !strings.Contains(Snapshot(bg).String(), `*background.disabledBackground "tracing": disabled`) {
+		t.Errorf("disabled node is not marked in snapshot:\n%s", Snapshot(bg))
 	}
 
-	bg3.Ok()
-	time.Sleep(failTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if hasNotClosed(bg1.ready, bg1ReadyC, bg2.ready, bg2ReadyC, bg3.ready, bg3ReadyC, bg4ReadyC) {
-		t.Error(errNotReady)
+	if err := bg.Shutdown(ctx); err != nil || hasNotClosed(bg.Ready()) {
+		t.Error("disabled components block the tree")
 	}
 }
 
-func DependencyErrorParentTest(t *testing.T) {
+// Lazy components
+
+func LazyTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		err1 = errors.New("error1")
-		err2 = errors.New("error2")
-
-		bg1 = withError(err1)
-		bg2 = withError(err2)
-		bg3 = withDependency(bg1, bg2)
+		calls      int32
+		bg1, tail1 = WithShutdown()
+		bg2, tail2 = WithReadiness(bg1)
+		bg3, lazy  = Lazy(func() (Background, error) {
+			atomic.AddInt32(&calls, 1)
+			return WithValue("key", "value", bg2), nil
+		})
+		bg = Merge(bg3)
 	)
 
-	err := bg3.Err()
+	go func() {
+		<-tail1.End()
+		tail1.Done()
+	}()
 
-	// err1 is higher in the tree so it must be found first
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	// the lookups don't construct the component
+	if value := bg.Value("key"); value != nil || bg.Err() != nil {
+		t.Errorf("unexpected value: %v", value)
 	}
 
-	err = bg2.Err()
-	if !errors.Is(err, err2) {
-		t.Errorf("wrong error, want '%v', have '%v'", err2, err)
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatal("lazy component is constructed before first use")
 	}
-}
 
-func DependencyErrorChildrenTest(t *testing.T) {
-	t.Parallel()
+	// the component that isn't started doesn't hold the tree's readiness
+	if hasNotClosed(bg.Ready()) {
+		t.Error("not started component holds parent's readiness")
+	}
 
-	var (
-		err1 = errors.New("error1")
+	// the readiness of the Background triggers the construction
+	ready := bg3.Ready()
 
-		bg1 = withError(err1)
-		bg2 = emptyBackground{}
-		bg3 = bg2.DependsOn(bg1)
-	)
+	if err := lazy.Start(); err != nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("component constructed %d times, error: %v", calls, err)
+	}
 
-	err := bg3.Err()
+	if hasClosed(ready) {
+		t.Error("ready before component is ready")
+	}
 
-	if !errors.Is(err, err1) {
-		t.Errorf("wrong error, want '%v', have '%v'", err1, err)
+	tail2.Ok()
+
+	if hasNotClosed(ready) {
+		t.Error("not ready after component is ready")
 	}
-}
 
-func DependencyErrorNilTest(t *testing.T) {
-	t.Parallel()
+	if value := bg.Value("key"); value != "value" {
+		t.Errorf("wrong value, want 'value', have '%v'", value)
+	}
 
-	var (
-		bg1 = withError(nil)
-		bg2 = emptyBackground{}
-		bg3 = bg2.DependsOn(bg1)
-	)
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
 
-	if err := bg3.Err(); err != nil {
-		t.Errorf("error must be nil, have '%v'", err)
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
+	}
+
+	if hasNotClosed(bg1.Finished()) {
+		t.Error("component is not shut down")
 	}
 }
 
-func DependencyValueParentTest(t *testing.T) {
+func LazyControlsTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		testKey   = key("test_key")
-		testValue = "test_value"
-		bg1       = emptyBackground{}
-		bg2       = emptyBackground{}
-		bg3       = bg2.DependsOn(bg1)
-		bg4       = withValue(testKey, testValue)
-		bg5       = withDependency(bg4, bg3)
+		component = newControlled()
+		bg, lazy  = Lazy(func() (Background, error) { return component, nil })
 	)
 
-	value := bg5.Value(testKey)
-	if value == nil {
-		t.Error("test value for test key not found")
-	}
+	component.checkControls(t, bg, false)
 
-	valueTyped, ok := value.(string)
-	if !ok {
-		t.Error("test value for test key has wrong type")
+	if err := lazy.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
 	}
 
-	if valueTyped != testValue {
-		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
-	}
+	component.checkControls(t, bg, true)
 }
 
-func DependencyValueChildrenTest(t *testing.T) {
+func LazyNeverStartedTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		testKey   = key("test_key")
-		testValue = "test_value"
-		bg1       = withValue(testKey, testValue)
-		bg2       = emptyBackground{}
-		bg3       = bg2.DependsOn(bg1)
-		bg4       = emptyBackground{}
-		bg5       = bg4.DependsOn(bg3)
+		called   bool
+		bg, lazy = Lazy(func() (Background, error) {
+			called = true
+			return Empty(), nil
+		})
 	)
 
-	value := bg5.Value(testKey)
-	if value == nil {
-		t.Error("test value for test key not found")
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Error(errTimeout)
 	}
 
-	valueTyped, ok := value.(string)
-	if !ok {
-		t.Error("test value for test key has wrong type")
+	if err := lazy.Start(); err != ErrClosing {
+		t.Errorf("wrong error, want '%v', have '%v'", ErrClosing, err)
 	}
 
-	if valueTyped != testValue {
-		t.Errorf("wrong test value: want %s have %s", testValue, valueTyped)
+	if called {
+		t.Error("component is constructed after shutdown")
 	}
 }
 
-func DependencyAnnotationTest(t *testing.T) {
+func LazyFailureTest(t *testing.T) {
 	t.Parallel()
 
 	var (
-		bg1 = emptyBackground{}
-		bg2 = emptyBackground{}
-		bg3 = withAnnotation("", bg1, bg2)
-		bg4 = emptyBackground{}
-		bg5 = withDependency(bg3, bg4)
+		fail     = errors.New("index unavailable")
+		bg, lazy = Lazy(func() (Background, error) { return nil, fail })
 	)
 
-	if bg5.parent == nil {
-		t.Errorf("parent of dependency Background is nil, must be empty Background")
+	if err := bg.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
 
-	if bg5.parent != bg3 {
-		t.Errorf("wrong parent of dependency Background")
+	if err := lazy.Start(); err != fail {
+		t.Errorf("wrong error, want '%v', have '%v'", fail, err)
 	}
 
-	if len(bg5.children.backgrounds) != 1 {
-		t.Errorf("wrong number of dependency Background children, want 1, have %d", len(bg5.children.backgrounds))
-		return
+	if err := bg.Err(); err != fail {
+		t.Errorf("wrong error, want '%v', have '%v'", fail, err)
 	}
 
-	if bg5.children.backgrounds[0] != bg4 {
-		t.Errorf("wrong children of dependency Background")
+	if hasClosed(bg.Ready()) {
+		t.Error("ready after failed construction")
 	}
 }
@@ -0,0 +1,40 @@
+package background
+
+import "strings"
+
+// ComponentStatus is the readiness status of a component.
+type ComponentStatus struct {
+	// Name is the annotations on the path to the component joined with ": ",
+	// the same way as they annotate errors, or empty if there are none.
+	Name string
+
+	// Ready is true if the component's ReadinessTail's Ok was called.
+	Ready bool
+}
+
+// ReadinessStatus returns the readiness statuses of all components created
+// with WithReadiness in bg's tree in the same order as Background.Value
+// searches the tree. It helps to find the components that hold the tree's
+// readiness.
+func ReadinessStatus(bg Background) []ComponentStatus {
+	var statuses []ComponentStatus
+
+	walkPath(bg, func(bg Background, path []string) {
+		r, ok := bg.(*readinessBackground)
+		if !ok {
+			return
+		}
+
+		status := ComponentStatus{Name: strings.Join(path, ": ")}
+
+		select {
+		case <-r.ready:
+			status.Ready = true
+		default:
+		}
+
+		statuses = append(statuses, status)
+	})
+
+	return statuses
+}
@@ -0,0 +1,105 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// Strict mode detects common misuse of tails. It is enabled by building
+// the program with the background_strict build tag:
+//
+//	go test -tags background_strict ./...
+//
+// In strict mode:
+//
+// 1. Shutdown timeout errors tell whether the ShutdownTail's End was never
+// called, or it was consumed, but Done was never called.
+//
+// 2. Calling ReadinessTail's Ok after the closing of its Background began
+// panics, ReadinessTail's Close is meant for jobs stopped before they got ready.
+//
+// 3. Garbage collection of a shutdown Background whose ShutdownTail's End was
+// never called is reported to Options' OnDroppedTail of the package defaults,
+// as it means that the tail was dropped without use.
+//
+// The panics and reports name the annotations on the path to the tail's
+// Background, see WithAnnotation.
+//
+// Strict mode can also be enabled with Options' Strict.
+//
+// Strict mode adds runtime overhead and is intended for tests and debugging.
+
+// strictTimeoutCause returns the shutdown timeout cause of s based on its
+// timeout error err and the expired shutdown context ctx.
+func strictTimeoutCause(ctx context.Context, s *shutdownBackground, err error) error {
+	if !strict && !optionsFrom(ctx).Strict {
+		return err
+	}
+
+	if atomic.LoadInt32(&s.endCalled) == 0 {
+		return fmt.Errorf("%w: shutdown tail's End was never called", err)
+	}
+
+	return fmt.Errorf("%w: shutdown tail's End was consumed, but Done was never called", err)
+}
+
+// strictTrackShutdown reports to Options' OnDroppedTail if s is garbage
+// collected and its tail's End was never called. It doesn't panic, as
+// the panics of finalizers can't be recovered and don't point at the misuse.
+func strictTrackShutdown(s *shutdownBackground) {
+	if !strict && !Defaults().Strict {
+		return
+	}
+
+	runtime.SetFinalizer(s, func(s *shutdownBackground) {
+		f := Defaults().OnDroppedTail
+		if f == nil || atomic.LoadInt32(&s.endCalled) == 1 {
+			return
+		}
+
+		f(fmt.Errorf("background: %w%s", ErrTailDropped, strictAt(s.strictPath())))
+	})
+}
+
+// strictCheckOk panics if the closing of r already began.
+func strictCheckOk(r *readinessBackground) {
+	if !strict && !Defaults().Strict {
+		return
+	}
+
+	if r.IsClosing() {
+		panic("background: readiness tail's Ok called after the Background was shut down" + strictAt(r.strictPath()))
+	}
+}
+
+// strictAnnotate records the annotations on the paths to the tails in a's
+// tree, so the strict mode panics and reports can name them.
+func strictAnnotate(a *annotationBackground) {
+	if !strict && !Defaults().Strict {
+		return
+	}
+
+	// a is the outermost annotation yet, so the paths from it are
+	// the longest ones and replace the recorded paths.
+	walkPath(a, func(bg Background, path []string) {
+		switch bg := bg.(type) {
+		case *shutdownBackground:
+			bg.setStrictPath(strings.Join(path, ": "))
+		case *readinessBackground:
+			bg.setStrictPath(strings.Join(path, ": "))
+		}
+	})
+}
+
+// strictAt returns the description of the tail's annotation path
+// for the strict mode panics and reports.
+func strictAt(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	return " (at " + path + ")"
+}
@@ -0,0 +1,7 @@
+//go:build background_strict
+// +build background_strict
+
+package background
+
+// strict enables the detection of tails misuse.
+const strict = true
@@ -0,0 +1,96 @@
+//go:build background_strict
+// +build background_strict
+
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrictTimeoutCause(t *testing.T) {
+	bg1 := withShutdown()
+	bg2 := withShutdown()
+
+	// consumed End without Done
+	_ = bg2.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := bg1.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "never called") {
+		t.Errorf("wrong strict timeout cause: %v", err)
+	}
+
+	if err := bg2.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "Done was never called") {
+		t.Errorf("wrong strict timeout cause: %v", err)
+	}
+}
+
+func TestStrictOkAfterShutdown(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("Ok after shutdown didn't panic")
+		}
+	}()
+
+	bg := withReadiness()
+	bg.close(context.Background())
+	bg.Ok()
+}
+
+func TestStrictOkBeforeShutdown(t *testing.T) {
+	_, tail := WithReadiness()
+	tail.Ok()
+}
+
+func TestStrictOkAnnotationPath(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "(at outer: inner)") {
+			t.Errorf("panic doesn't name the annotation path: %v", r)
+		}
+	}()
+
+	r := withReadiness()
+	bg := WithAnnotation("outer", WithAnnotation("inner", r))
+
+	_ = bg.Shutdown(context.Background())
+	r.Ok()
+}
+
+// TestStrictDroppedTail is not parallel as it changes the package defaults.
+func TestStrictDroppedTail(t *testing.T) {
+	dropped := make(chan error, 100)
+
+	SetDefaults(Options{OnDroppedTail: func(err error) { dropped <- err }})
+	defer SetDefaults(Options{})
+
+	func() {
+		_ = WithAnnotation("dropped", withShutdown())
+	}()
+
+	timeout := time.After(failTimeout)
+
+	for {
+		runtime.GC()
+
+		select {
+		case err := <-dropped:
+			if !errors.Is(err, ErrTailDropped) {
+				t.Fatalf("wrong error, want '%v', have '%v'", ErrTailDropped, err)
+			}
+
+			if strings.Contains(err.Error(), "(at dropped)") {
+				return
+			}
+		case <-timeout:
+			t.Fatal("dropped tail wasn't reported")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSubtreeNotFound is the error returned by ShutdownSubtree when there is
+// no annotated subtree with the requested name.
+var ErrSubtreeNotFound = errors.New("subtree not found")
+
+// subtreeKey is a private Value key used to look up annotation Backgrounds
+// by their annotation.
+type subtreeKey string
+
+// Subtree returns the first Background in bg's tree annotated with name,
+// or nil if there is none. The tree is searched the same way as in
+// Background.Value: from top to bottom and from left to right.
+//
+// The returned Background stays a part of bg's tree: shutting it down
+// leaves the rest of the tree untouched, and the subsequent shutdown
+// of bg treats the subtree as already shut down.
+func Subtree(bg Background, name string) Background {
+	if sub, ok := bg.Value(subtreeKey(name)).(Background); ok {
+		return sub
+	}
+
+	return nil
+}
+
+// ShutdownSubtree gracefully shuts down the first Background in bg's tree
+// annotated with name without shutting down the rest of the tree.
+//
+// Returns error wrapping ErrSubtreeNotFound if there is no such subtree.
+func ShutdownSubtree(ctx context.Context, bg Background, name string) error {
+	sub := Subtree(bg, name)
+	if sub == nil {
+		return fmt.Errorf("%s: %w", name, ErrSubtreeNotFound)
+	}
+
+	return sub.Shutdown(ctx)
+}
@@ -0,0 +1,216 @@
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+type teardownBackground struct {
+	*group
+
+	// pushed are the Backgrounds pushed to the stack, in the push order.
+	pushed []Background
+
+	// ctx is the context of the closing, nil before the closing begins.
+	ctx      context.Context
+	finished chan struct{}
+
+	// closeOnce ensures that the Background shared between multiple
+	// parents is closed once.
+	closeOnce sync.Once
+
+	mu sync.RWMutex
+}
+
+// TeardownStack detaches after teardown stack Background initialization.
+// It is supposed to be used by the constructor of a component made
+// of multiple Backgrounds.
+type TeardownStack interface {
+	// Push adds successfully constructed bg to the top of the stack.
+	// If the closing of the stack has already begun, bg is closed
+	// right away and Push blocks until bg is finished.
+	Push(bg Background)
+}
+
+// WithTeardownStack returns new empty Background and TeardownStack, a LIFO
+// accumulator of Backgrounds: during shutdown the pushed Backgrounds are
+// closed strictly in the reverse order of pushing, every Background is closed
+// only after the next one is successfully closed. It guarantees the reverse
+// teardown of the components both on the constructor's failure and on
+// the final shutdown:
+//
+//	func newService(ctx context.Context) (background.Background, error) {
+//		bg, stack := background.WithTeardownStack()
+//
+//		db, err := openDB(ctx)
+//		if err != nil {
+//			return nil, err
+//		}
+//		stack.Push(db)
+//
+//		server, err := startServer(ctx, db)
+//		if err != nil {
+//			return nil, fmt.Errorf("server: %w: %v", err, bg.Shutdown(ctx))
+//		}
+//		stack.Push(server)
+//
+//		return bg, nil
+//	}
+//
+// The pushed Backgrounds take part in the Background's Err, Value, Wait,
+// Reload, Pause and Resume calls. They are paused in the reverse order
+// of pushing, like they are closed, and reloaded and resumed in the push
+// order. The Background's Ready isn't affected by them, as the Backgrounds
+// pushed after Ready is called couldn't delay it.
+func WithTeardownStack() (Background, TeardownStack) {
+	t := &teardownBackground{
+		group:    merge(),
+		finished: make(chan struct{}),
+	}
+
+	return t, t
+}
+
+func (t *teardownBackground) Push(bg Background) {
+	t.mu.Lock()
+
+	if t.ctx == nil {
+		t.pushed = append(t.pushed, bg)
+		t.mu.Unlock()
+
+		return
+	}
+
+	ctx := t.ctx
+	t.mu.Unlock()
+
+	bg.close(ctx)
+	awaitFinish(ctx, t, bg)
+}
+
+// pushedBackgrounds returns a copy of the pushed Backgrounds.
+func (t *teardownBackground) pushedBackgrounds() []Background {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return append([]Background(nil), t.pushed...)
+}
+
+func (t *teardownBackground) Shutdown(ctx context.Context) error {
+	return t.result.do(ctx, t, func() error { return shutdown(ctx, t) })
+}
+
+func (t *teardownBackground) close(ctx context.Context) {
+	t.closeOnce.Do(func() {
+		t.prepare()
+
+		t.mu.Lock()
+		t.ctx = ctx
+		pushed := t.pushed
+		t.mu.Unlock()
+
+		for i := len(pushed) - 1; i >= 0; i-- {
+			pushed[i].close(ctx)
+			if !awaitFinish(ctx, t, pushed[i]) {
+				return
+			}
+		}
+
+		t.group.close(ctx)
+		close(t.finished)
+	})
+}
+
+func (t *teardownBackground) Finished() <-chan struct{} {
+	return t.finished
+}
+
+func (t *teardownBackground) IsFinished() bool {
+	return isClosed(t.finished)
+}
+
+func (t *teardownBackground) finishSig() <-chan struct{} {
+	return t.finished
+}
+
+func (t *teardownBackground) Err() error {
+	for _, bg := range t.pushedBackgrounds() {
+		if err := bg.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *teardownBackground) Value(key interface{}) interface{} {
+	for _, bg := range t.pushedBackgrounds() {
+		if value := bg.Value(key); value != nil {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func (t *teardownBackground) Wait() {
+	for _, bg := range t.pushedBackgrounds() {
+		bg.Wait()
+	}
+}
+
+func (t *teardownBackground) WaitContext(ctx context.Context) error {
+	for _, bg := range t.pushedBackgrounds() {
+		if err := bg.WaitContext(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *teardownBackground) Reload(ctx context.Context) error {
+	for _, bg := range t.pushedBackgrounds() {
+		if err := bg.Reload(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *teardownBackground) Pause() {
+	pushed := t.pushedBackgrounds()
+
+	for i := len(pushed) - 1; i >= 0; i-- {
+		pushed[i].Pause()
+	}
+}
+
+func (t *teardownBackground) Resume() {
+	for _, bg := range t.pushedBackgrounds() {
+		bg.Resume()
+	}
+}
+
+// cause walks down the pushed Backgrounds from the top of the stack,
+// the ones closed first.
+func (t *teardownBackground) cause(ctx context.Context) error {
+	pushed := t.pushedBackgrounds()
+
+	for i := len(pushed) - 1; i >= 0; i-- {
+		if err := pushed[i].cause(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *teardownBackground) branches() []Background {
+	return t.pushedBackgrounds()
+}
+
+func (t *teardownBackground) DependsOn(children ...Background) Background {
+	return withDependency(t, children...)
+}
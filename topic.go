@@ -0,0 +1,124 @@
+package background
+
+import "sync"
+
+// Topic is an in-process publish/subscribe bus whose subscriptions are
+// Backgrounds, so they are unsubscribed and drained during the shutdown
+// of the trees they belong to. Topics can be shared between the components
+// with WithValue or Provide.
+//
+//	topic := background.NewTopic()
+//
+//	sub := topic.Subscribe(16, func(msg interface{}) {
+//		invalidate(msg.(CacheKey))
+//	})
+//
+//	topic.Publish(CacheKey("user:42"))
+//
+// The zero value is an empty Topic ready to use.
+type Topic struct {
+	subs map[*subscription]struct{}
+
+	sync.RWMutex
+}
+
+// NewTopic returns new empty Topic.
+func NewTopic() *Topic {
+	return &Topic{}
+}
+
+type subscription struct {
+	msgs chan interface{}
+
+	// end is closed when the subscription is unsubscribed.
+	end chan struct{}
+}
+
+// Publish sends msg to all current subscribers. It blocks until every
+// subscriber has room for msg in its buffer or is unsubscribed, the messages
+// published to unsubscribed subscribers are dropped.
+func (t *Topic) Publish(msg interface{}) {
+	t.RLock()
+	subs := make([]*subscription, 0, len(t.subs))
+	for sub := range t.subs {
+		subs = append(subs, sub)
+	}
+	t.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.msgs <- msg:
+		case <-sub.end:
+		}
+	}
+}
+
+// Subscribe returns new Background handling the messages published
+// to the Topic with handle, one by one in the order they were published.
+// Up to buffer messages are queued while handle is busy.
+//
+// When the Background is shut down, it unsubscribes from the Topic,
+// so the following messages are not delivered to it, and then handles
+// the queued messages before its shutdown is complete.
+func (t *Topic) Subscribe(buffer int, handle func(msg interface{})) Background {
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	var (
+		sub = &subscription{
+			msgs: make(chan interface{}, buffer),
+			end:  make(chan struct{}),
+		}
+		s = withShutdown()
+	)
+
+	t.Lock()
+	if t.subs == nil {
+		t.subs = make(map[*subscription]struct{})
+	}
+	t.subs[sub] = struct{}{}
+	t.Unlock()
+
+	goLabeled(caller(2), func() {
+		defer s.Done()
+
+		for {
+			select {
+			case msg := <-sub.msgs:
+				handle(msg)
+			case <-s.End():
+				t.unsubscribe(sub)
+
+				// drain the queued messages
+				for {
+					select {
+					case msg := <-sub.msgs:
+						handle(msg)
+					default:
+						return
+					}
+				}
+			}
+		}
+	})
+
+	return s
+}
+
+// unsubscribe removes sub from the Topic and unblocks its publishers.
+func (t *Topic) unsubscribe(sub *subscription) {
+	t.Lock()
+	delete(t.subs, sub)
+	t.Unlock()
+
+	close(sub.end)
+}
+
+// Subscribers returns the number of current subscribers.
+func (t *Topic) Subscribers() int {
+	t.RLock()
+	defer t.RUnlock()
+
+	return len(t.subs)
+}
@@ -0,0 +1,186 @@
+package background
+
+import "reflect"
+
+// Source describes how a Background was attached to its parent in the code
+// that built the tree.
+type Source int
+
+const (
+	// SourceRoot is the source of the root of the tree.
+	SourceRoot Source = iota
+	// SourceChildren means that the Background was passed as a child to
+	// a constructor, e.g. WithShutdown.
+	SourceChildren
+	// SourceMerge means that the Background was merged with one of Merge
+	// functions.
+	SourceMerge
+	// SourceDependency means that the Background was passed to DependsOn.
+	SourceDependency
+	// SourceParent means that DependsOn was called on the Background.
+	SourceParent
+	// SourceEmbedded means that the Background is embedded into its parent
+	// of another package's type, e.g. a struct embedding Background.
+	SourceEmbedded
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceRoot:
+		return "root"
+	case SourceChildren:
+		return "children"
+	case SourceMerge:
+		return "merge"
+	case SourceDependency:
+		return "dependency"
+	case SourceParent:
+		return "parent"
+	case SourceEmbedded:
+		return "embedded"
+	default:
+		return "unknown"
+	}
+}
+
+// brancher is implemented by Backgrounds with children.
+type brancher interface {
+	// branches returns Background's direct children.
+	branches() []Background
+
+	// branchSource returns the source of the child with index i.
+	branchSource(i int) Source
+}
+
+func (g *group) branches() []Background {
+	return g.backgrounds
+}
+
+func (g *group) branchSource(_ int) Source {
+	return g.source
+}
+
+func (d *dependBackground) branches() []Background {
+	return []Background{d.parent, d.children}
+}
+
+func (d *dependBackground) branchSource(i int) Source {
+	if i == 0 {
+		return SourceParent
+	}
+
+	return SourceDependency
+}
+
+// backgroundType is the type of Background interface.
+var backgroundType = reflect.TypeOf((*Background)(nil)).Elem()
+
+// embedding is the brancher of a Background of another package's type
+// with the Background embedded into it.
+type embedding struct {
+	bg Background
+}
+
+func (e embedding) branches() []Background {
+	return []Background{e.bg}
+}
+
+func (e embedding) branchSource(_ int) Source {
+	return SourceEmbedded
+}
+
+// brancherOf returns the brancher of bg: bg itself if it has children,
+// or the embedding if bg is a struct or a pointer to a struct of another
+// package embedding a Background, e.g. backgroundtest.Mock.
+func brancherOf(bg Background) (brancher, bool) {
+	if b, ok := bg.(brancher); ok {
+		return b, true
+	}
+
+	v := reflect.ValueOf(bg)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+
+		if !v.Type().Field(i).Anonymous || !field.CanInterface() || !field.Type().Implements(backgroundType) {
+			continue
+		}
+
+		if child, ok := field.Interface().(Background); ok && child != nil {
+			return embedding{bg: child}, true
+		}
+	}
+
+	return nil, false
+}
+
+// link describes how a visited Background is attached to its parent.
+type link struct {
+	// index is the position of the Background among the parent's children.
+	index  int
+	source Source
+}
+
+// walk calls f for every Background in bg's tree in the same order as
+// Background.Value searches it: from top to bottom and from left to right.
+// Backgrounds shared between multiple parents are visited once.
+func walk(bg Background, f func(Background)) {
+	walkPath(bg, func(bg Background, _ []string) { f(bg) })
+}
+
+// walkPath is like walk, but also passes f the annotations on the path
+// to the visited Background, including its own annotation.
+func walkPath(bg Background, f func(bg Background, path []string)) {
+	walkDepth(bg, func(bg Background, path []string, _ int, _ link) { f(bg, path) })
+}
+
+// walkDepth is like walkPath, but also passes f the depth of the visited
+// Background: the number of its ancestors on the path it was first
+// reached by, and its link to the parent on that path.
+func walkDepth(bg Background, f func(bg Background, path []string, depth int, l link)) {
+	visited := make(map[Background]struct{})
+
+	var visit func(bg Background, path []string, depth int, l link)
+
+	visit = func(bg Background, path []string, depth int, l link) {
+		if hashable(bg) {
+			if _, ok := visited[bg]; ok {
+				return
+			}
+
+			visited[bg] = struct{}{}
+		}
+
+		if a, ok := bg.(*annotationBackground); ok {
+			path = append(path[:len(path):len(path)], a.annotation)
+		}
+
+		f(bg, path, depth, l)
+
+		if b, ok := brancherOf(bg); ok {
+			for i, child := range b.branches() {
+				visit(child, path, depth+1, link{index: i, source: b.branchSource(i)})
+			}
+		}
+	}
+
+	visit(bg, nil, 0, link{source: SourceRoot})
+}
+
+// hashable reports whether bg can be a key of a map. The Backgrounds
+// of uncomparable dynamic types, e.g. the structs embedding Background
+// along with a slice, can't, so they are never deduplicated.
+func hashable(bg Background) bool {
+	return reflect.TypeOf(bg).Comparable()
+}
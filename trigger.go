@@ -0,0 +1,237 @@
+package background
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type triggerBackground struct {
+	*shutdownBackground
+
+	fn     func(ctx context.Context) error
+	errs   *errGroupBackground
+	policy TriggerPolicy
+	clock  Clock
+
+	// ctx is passed to fn, it is canceled when the ctx of the shutdown is done.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// running is true while fn runs, pending is true if there is a trigger
+	// not served by a run yet, ended is true after the shutdown began.
+	running, pending, ended bool
+
+	// lastStart is the start time of the last run,
+	// lastTrigger is the time of the last Trigger call.
+	lastStart, lastTrigger time.Time
+
+	// component is the pprof label of the goroutines, see LabelComponent.
+	component string
+
+	// timer is the timer of the scheduled run, nil if there is none.
+	// generation identifies the timer to ignore stopped timers that fire.
+	timer      Timer
+	generation int
+
+	mu sync.Mutex
+}
+
+// TriggerTail detaches after trigger Background initialization.
+// The tail is supposed to be used by the code that requests runs of the job,
+// e.g. an admin endpoint.
+type TriggerTail interface {
+	// Trigger requests a run of the Background's function and returns
+	// immediately. The run starts according to the Background's TriggerPolicy.
+	// If the function is already running, it is run once more after the
+	// current run: concurrent triggers are coalesced into one run.
+	// After the Background's shutdown began, Trigger does nothing.
+	Trigger()
+}
+
+// TriggerPolicy defines when the run requested with TriggerTail starts.
+type TriggerPolicy struct {
+	// start returns the earliest start time of the next run,
+	// zero time means immediately.
+	start func(lastStart, lastTrigger time.Time) time.Time
+}
+
+// TriggerImmediately returns TriggerPolicy that starts the run right after
+// the trigger. It is the policy of WithTrigger.
+func TriggerImmediately() TriggerPolicy {
+	return TriggerPolicy{
+		start: func(_, _ time.Time) time.Time { return time.Time{} },
+	}
+}
+
+// TriggerEvery returns TriggerPolicy that throttles runs: every run starts
+// at least d after the start of the previous one.
+func TriggerEvery(d time.Duration) TriggerPolicy {
+	return TriggerPolicy{
+		start: func(lastStart, _ time.Time) time.Time {
+			if lastStart.IsZero() {
+				return time.Time{}
+			}
+
+			return lastStart.Add(d)
+		},
+	}
+}
+
+// DebounceFor returns TriggerPolicy that debounces triggers: the run starts
+// once there were no triggers for d.
+func DebounceFor(d time.Duration) TriggerPolicy {
+	return TriggerPolicy{
+		start: func(_, lastTrigger time.Time) time.Time { return lastTrigger.Add(d) },
+	}
+}
+
+// WithTrigger returns new Background with merged children that runs fn
+// on demand with the returned TriggerTail.
+//
+// The ctx passed to fn is canceled when the ctx of the Background's shutdown
+// is done. The shutdown waits for the run in progress.
+// The first error returned by fn is returned by the Background's Err.
+func WithTrigger(fn func(ctx context.Context) error, children ...Background) (Background, TriggerTail) {
+	t := withTrigger(TriggerImmediately(), fn, children...)
+	return t, t
+}
+
+// WithTriggerPolicy is like WithTrigger, but the runs start according
+// to policy, so bursts of triggers result in a bounded number of runs.
+//
+// Time-dependent policies use the clock found in children,
+// see WithClock.
+func WithTriggerPolicy(policy TriggerPolicy, fn func(ctx context.Context) error, children ...Background) (Background, TriggerTail) {
+	t := withTrigger(policy, fn, children...)
+	return t, t
+}
+
+func withTrigger(policy TriggerPolicy, fn func(ctx context.Context) error, children ...Background) *triggerBackground {
+	errs := withErrorGroup()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t := &triggerBackground{
+		shutdownBackground: withShutdown(append([]Background{errs}, children...)...),
+		fn:                 fn,
+		errs:               errs,
+		policy:             policy,
+		ctx:                ctx,
+		cancel:             cancel,
+		component:          caller(3),
+	}
+
+	t.clock = clockOf(t.group)
+
+	goLabeled(t.component, t.watch)
+
+	return t
+}
+
+func (t *triggerBackground) Trigger() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ended {
+		return
+	}
+
+	t.pending = true
+	t.lastTrigger = t.clock.Now()
+
+	t.schedule()
+}
+
+// schedule starts the run or schedules it according to the policy.
+// Must be called with t.mu held.
+func (t *triggerBackground) schedule() {
+	if t.running {
+		// the run is scheduled after the current one
+		return
+	}
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+
+	wait := t.policy.start(t.lastStart, t.lastTrigger).Sub(t.clock.Now())
+	if wait > 0 {
+		t.generation++
+		generation := t.generation
+
+		t.timer = t.clock.AfterFunc(wait, func() { t.fire(generation) })
+
+		return
+	}
+
+	t.pending, t.running = false, true
+	t.lastStart = t.clock.Now()
+
+	goLabeled(t.component, t.run)
+}
+
+// fire starts the run scheduled with the timer of generation.
+func (t *triggerBackground) fire(generation int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if generation != t.generation || t.ended || t.running || !t.pending {
+		return
+	}
+
+	t.timer = nil
+
+	t.pending, t.running = false, true
+	t.lastStart = t.clock.Now()
+
+	goLabeled(t.component, t.run)
+}
+
+func (t *triggerBackground) run() {
+	if err := t.fn(t.ctx); err != nil {
+		t.errs.Error(err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.running = false
+
+	switch {
+	case t.ended:
+		t.shutdownBackground.Done()
+	case t.pending:
+		t.schedule()
+	}
+}
+
+// watch waits for the shutdown and completes it once the run
+// in progress is finished.
+func (t *triggerBackground) watch() {
+	<-t.End()
+
+	t.mu.Lock()
+	t.ended, t.pending = true, false
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+
+	if !t.running {
+		t.shutdownBackground.Done()
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.Context().Done():
+	case <-t.finishSig():
+	}
+
+	t.cancel()
+}
+
+func (t *triggerBackground) DependsOn(children ...Background) Background {
+	return withDependency(t, children...)
+}
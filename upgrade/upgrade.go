@@ -0,0 +1,328 @@
+//go:build !windows
+// +build !windows
+
+// Package upgrade provides coordination of graceful binary upgrades for
+// applications built on package background.
+//
+// The upgrade flow:
+//
+// 1. The running process creates its listeners with Upgrader's Listen and
+// calls Ready with its Background once the process is initialized.
+//
+// 2. On upgrade signal, the process calls Restart: it starts the new binary
+// passing it the listeners, waits until the new process is ready and then
+// shuts down the old process's Background.
+//
+// 3. The new process creates the Upgrader with New, which inherits
+// the listeners, so Listen returns them instead of creating new ones.
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lefelys/background"
+)
+
+const (
+	// envListeners holds keys of the inherited listeners separated by envSep.
+	// Listener files are passed starting from descriptor 3 in the same order.
+	envListeners = "BACKGROUND_UPGRADE_LISTENERS"
+	// envReady holds the descriptor of the pipe used to signal readiness
+	// of the new process.
+	envReady = "BACKGROUND_UPGRADE_READY"
+
+	envSep = ","
+
+	// firstFd is the descriptor of the first file from exec.Cmd's ExtraFiles.
+	firstFd = 3
+)
+
+// ErrNotReady is the error returned by Upgrade when the new process exits
+// before it is ready.
+var ErrNotReady = errors.New("new process exited before it was ready")
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Upgrader coordinates graceful binary upgrades.
+//
+// Upgrader's methods may be called by multiple goroutines simultaneously.
+type Upgrader struct {
+	// PreExec is called before the new process is started, e.g. to drain
+	// the work that can't be handed off. The upgrade is aborted if it
+	// returns an error.
+	PreExec func() error
+
+	// PostReady is called after the new process is ready and before the old
+	// process's Background is shut down by Restart.
+	PostReady func()
+
+	inherited map[string]net.Listener
+	listeners map[string]net.Listener
+	keys      []string
+	ready     *os.File
+
+	sync.Mutex
+}
+
+// New returns new Upgrader inheriting listeners from the parent process,
+// if the process is started by Upgrade.
+func New() (*Upgrader, error) {
+	u := &Upgrader{
+		inherited: make(map[string]net.Listener),
+		listeners: make(map[string]net.Listener),
+	}
+
+	if keys := os.Getenv(envListeners); keys != "" {
+		for i, key := range strings.Split(keys, envSep) {
+			f := os.NewFile(uintptr(firstFd+i), key)
+
+			l, err := net.FileListener(f)
+			f.Close()
+
+			if err != nil {
+				return nil, fmt.Errorf("inherit listener %s: %w", key, err)
+			}
+
+			u.inherited[key] = l
+		}
+	}
+
+	if fd := os.Getenv(envReady); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, fmt.Errorf("inherit readiness pipe: %w", err)
+		}
+
+		u.ready = os.NewFile(uintptr(n), "ready")
+	}
+
+	return u, nil
+}
+
+// Listen returns the listener inherited from the parent process for network
+// and address, or announces on the local network address, the same way
+// as net.Listen. The listener is passed to the new process on Upgrade.
+func (u *Upgrader) Listen(network, address string) (net.Listener, error) {
+	key := network + ":" + address
+
+	u.Lock()
+	defer u.Unlock()
+
+	if strings.Contains(key, envSep) {
+		return nil, fmt.Errorf("listen %s: address can't contain %q", key, envSep)
+	}
+
+	if l, ok := u.listeners[key]; ok {
+		return l, nil
+	}
+
+	l, ok := u.inherited[key]
+	if ok {
+		delete(u.inherited, key)
+	} else {
+		var err error
+
+		l, err = net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := l.(filer); !ok {
+		return nil, fmt.Errorf("listen %s: listener can't be passed to new process", key)
+	}
+
+	u.listeners[key] = l
+	u.keys = append(u.keys, key)
+
+	return l, nil
+}
+
+// Ready waits until bg is ready and notifies the parent process about it,
+// if the process is started by Upgrade. Inherited listeners that were not
+// requested with Listen are closed.
+func (u *Upgrader) Ready(ctx context.Context, bg background.Background) error {
+	select {
+	case <-bg.Ready():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	u.Lock()
+	defer u.Unlock()
+
+	for key, l := range u.inherited {
+		l.Close()
+		delete(u.inherited, key)
+	}
+
+	if u.ready == nil {
+		return nil
+	}
+
+	defer func() {
+		u.ready.Close()
+		u.ready = nil
+	}()
+
+	if _, err := u.ready.Write([]byte{1}); err != nil {
+		return fmt.Errorf("notify parent process: %w", err)
+	}
+
+	return nil
+}
+
+// Upgrade starts the new process from the current executable with the same
+// arguments, passing it the listeners, and waits until it calls Ready.
+//
+// If ctx is done before the new process is ready, the new process is killed.
+func (u *Upgrader) Upgrade(ctx context.Context) error {
+	if u.PreExec != nil {
+		if err := u.PreExec(); err != nil {
+			return fmt.Errorf("pre-exec: %w", err)
+		}
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd, err := u.command(w)
+	w.Close()
+
+	if err != nil {
+		return err
+	}
+
+	ready := make(chan error, 1)
+
+	go func() {
+		n, err := r.Read(make([]byte, 1))
+		if n == 1 {
+			ready <- nil
+			return
+		}
+
+		ready <- fmt.Errorf("%w: %v", ErrNotReady, err)
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+
+		return err
+	}
+
+	// the new process is not waited for, release its resources
+	cmd.Process.Release()
+
+	if u.PostReady != nil {
+		u.PostReady()
+	}
+
+	return nil
+}
+
+// command starts the new process with the listeners and write end
+// of the readiness pipe.
+func (u *Upgrader) command(ready *os.File) (*exec.Cmd, error) {
+	u.Lock()
+	defer u.Unlock()
+
+	files := make([]*os.File, 0, len(u.keys)+1)
+
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, key := range u.keys {
+		f, err := u.listeners[key].(filer).File()
+		if err != nil {
+			return nil, fmt.Errorf("pass listener %s: %w", key, err)
+		}
+
+		files = append(files, f)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, ready)
+	cmd.Env = append(environ(),
+		envListeners+"="+strings.Join(u.keys, envSep),
+		envReady+"="+strconv.Itoa(firstFd+len(files)),
+	)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// environ returns the environment of the current process without
+// the upgrade variables.
+func environ() []string {
+	var env []string
+
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envListeners+"=") || strings.HasPrefix(kv, envReady+"=") {
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	return env
+}
+
+// Restart upgrades the process with u and shuts down bg once the new process
+// is ready. The shutdown is limited by ctx as well.
+func Restart(ctx context.Context, u *Upgrader, bg background.Background) error {
+	if err := u.Upgrade(ctx); err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+
+	return bg.Shutdown(ctx)
+}
+
+// upgraderKey is a private Background value key for Upgrader.
+type upgraderKey struct{}
+
+// WithUpgrader returns new Background with merged children and u assigned
+// to it, making the listeners available to the tree.
+func WithUpgrader(u *Upgrader, children ...background.Background) background.Background {
+	return background.WithValue(upgraderKey{}, u, children...)
+}
+
+// FromBackground returns the Upgrader assigned to bg's tree with WithUpgrader,
+// or nil.
+func FromBackground(bg background.Background) *Upgrader {
+	u, _ := bg.Value(upgraderKey{}).(*Upgrader)
+	return u
+}
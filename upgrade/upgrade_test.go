@@ -0,0 +1,125 @@
+//go:build !windows
+// +build !windows
+
+package upgrade
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lefelys/background"
+)
+
+const (
+	failTimeout = 5 * time.Second
+
+	testAddress = "127.0.0.1:0"
+)
+
+// TestMain runs the new process's side of the upgrade when the test binary
+// is started by Upgrade.
+func TestMain(m *testing.M) {
+	if os.Getenv(envReady) == "" {
+		os.Exit(m.Run())
+	}
+
+	u, err := New()
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if len(u.inherited) != 1 {
+		os.Exit(2)
+	}
+
+	bg, tail := background.WithReadiness()
+	tail.Ok()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := u.Ready(ctx, bg); err != nil {
+		os.Exit(3)
+	}
+
+	os.Exit(0)
+}
+
+func TestListen(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	l1, err := u.Listen("tcp", testAddress)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l1.Close()
+
+	l2, err := u.Listen("tcp", testAddress)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if l1 != l2 {
+		t.Errorf("listener for the same address is created twice")
+	}
+
+	bg := WithUpgrader(u)
+
+	if FromBackground(bg) != u {
+		t.Errorf("upgrader is not found in Background")
+	}
+}
+
+func TestRestart(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	l, err := u.Listen("tcp", testAddress)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer l.Close()
+
+	var preExec, postReady bool
+
+	u.PreExec = func() error {
+		preExec = true
+		return nil
+	}
+
+	u.PostReady = func() {
+		postReady = true
+	}
+
+	bg, tail := background.WithShutdown()
+
+	go func() {
+		<-tail.End()
+		tail.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+
+	if err := Restart(ctx, u, bg); err != nil {
+		t.Fatalf("unexpected restart error: %v", err)
+	}
+
+	switch {
+	case !preExec:
+		t.Errorf("PreExec wasn't called")
+	case !postReady:
+		t.Errorf("PostReady wasn't called")
+	}
+
+	if _, ok := bg.ShutdownResult(); !ok {
+		t.Errorf("old Background wasn't shut down")
+	}
+}
@@ -1,6 +1,9 @@
 package background
 
-import "reflect"
+import (
+	"context"
+	"reflect"
+)
 
 type valueBackground struct {
 	*group
@@ -60,3 +63,109 @@ func (e *valueBackground) Value(key interface{}) (value interface{}) {
 func (e *valueBackground) DependsOn(children ...Background) Background {
 	return withDependency(e, children...)
 }
+
+// SearchOrder defines the order in which FindValue searches a tree.
+type SearchOrder int
+
+const (
+	// TopDown searches the tree the same way as Background.Value: from top
+	// to bottom and from left to right, so parents' values take precedence
+	// over children's.
+	TopDown SearchOrder = iota
+
+	// BottomUp searches the tree from bottom to top and from left to right,
+	// so children's values take precedence over parents'. It allows
+	// components to override defaults provided at the top of the tree.
+	BottomUp
+)
+
+// FindValue returns the value associated with key in bg's tree searched
+// in order, or nil if no value is associated with key.
+func FindValue(bg Background, key interface{}, order SearchOrder) interface{} {
+	if order == BottomUp {
+		return findValueBottomUp(bg, key)
+	}
+
+	return bg.Value(key)
+}
+
+func findValueBottomUp(bg Background, key interface{}) interface{} {
+	if _, ok := bg.(*scopeBackground); ok {
+		return nil
+	}
+
+	if b, ok := brancherOf(bg); ok {
+		for _, child := range b.branches() {
+			if value := findValueBottomUp(child, key); value != nil {
+				return value
+			}
+		}
+	}
+
+	// bg's children have no value for key, so bg.Value returns
+	// bg's own value, if any
+	return bg.Value(key)
+}
+
+type scopeBackground struct {
+	*group
+}
+
+// ScopeValues returns new Background with merged children, which values
+// are not visible outside of it: lookups in the tree the Background is a part
+// of don't search its children. It keeps values of library subtrees from
+// colliding with values of the application tree.
+//
+// Values accessed with package's functions, such as subtree names used by
+// Subtree, are scoped as well. Lookups in the children themselves are
+// not affected.
+func ScopeValues(children ...Background) Background {
+	return &scopeBackground{
+		group: merge(children...),
+	}
+}
+
+// Value always returns nil.
+func (s *scopeBackground) Value(_ interface{}) interface{} {
+	return nil
+}
+
+func (s *scopeBackground) DependsOn(children ...Background) Background {
+	return withDependency(s, children...)
+}
+
+type contextValuesBackground struct {
+	*group
+
+	ctx context.Context
+}
+
+// WithContextValues returns new Background with merged children, which Value
+// falls through to ctx's Value for keys not found in the children. It lets
+// applications that carry configuration or telemetry handles in a root
+// context use them as Background values without duplicating them:
+//
+//	bg := background.WithContextValues(ctx, server, db)
+//
+// Only the values of ctx are used: ctx's cancellation doesn't affect
+// the Background.
+func WithContextValues(ctx context.Context, children ...Background) Background {
+	return &contextValuesBackground{
+		group: merge(children...),
+		ctx:   ctx,
+	}
+}
+
+// Value returns value associated with key from the children,
+// or from ctx if it is not found there.
+func (c *contextValuesBackground) Value(key interface{}) interface{} {
+	if value := c.group.Value(key); value != nil {
+		return value
+	}
+
+	return c.ctx.Value(key)
+}
+
+func (c *contextValuesBackground) DependsOn(children ...Background) Background {
+	return withDependency(c, children...)
+}
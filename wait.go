@@ -1,12 +1,37 @@
 package background
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
+// ErrNegativeCounter is the error returned by Background.Err when WaitTail
+// of Background created with WithSafeWait is used to make WaitGroup's
+// counter negative.
+var ErrNegativeCounter = errors.New("negative WaitGroup counter")
+
 type waitBackground struct {
+	// pending and changes are accessed atomically and must stay the first
+	// fields to be 64-bit aligned on 32-bit platforms.
+	pending int64
+
+	// changes is the number of changes of the counter, see WaitWithWatchdog.
+	changes int64
+
 	*group
 	sync.WaitGroup
+
+	// errs is not nil in safe mode and is used to record misuse errors.
+	errs ErrTail
+
+	// drained is closed and replaced every time the counter drops to zero,
+	// so WaitContext doesn't need a goroutine blocked on the WaitGroup.
+	// It is nil until requested and is guarded by drainedMu.
+	drained   chan struct{}
+	drainedMu sync.Mutex
 }
 
 // WaitTail detaches after waitable background initialization.
@@ -20,6 +45,10 @@ type WaitTail interface {
 
 	// Add calls sync.WaitGroup's Add method
 	Add(i int)
+
+	// Pending returns the current value of the WaitGroup's counter.
+	// It does not include counters of Background's children.
+	Pending() int
 }
 
 // WithWait returns new waitable Background with merged children.
@@ -37,12 +66,196 @@ func withWait(children ...Background) *waitBackground {
 	}
 }
 
+// WithSafeWait returns new waitable Background with merged children
+// that is resistant to WaitTail misuse.
+//
+// Unlike WithWait, calls to the returned WaitTail that would make
+// the WaitGroup counter negative do not panic, but are ignored and
+// cause Background's Err to return ErrNegativeCounter.
+func WithSafeWait(children ...Background) (Background, WaitTail) {
+	s := withSafeWait(children...)
+
+	return s, s
+}
+
+func withSafeWait(children ...Background) *waitBackground {
+	errs := withErrorGroup()
+
+	return &waitBackground{
+		group: merge(append([]Background{errs}, children...)...),
+		errs:  errs,
+	}
+}
+
+// Add adds delta to the WaitGroup counter.
+func (w *waitBackground) Add(delta int) {
+	atomic.AddInt64(&w.changes, 1)
+
+	if w.errs == nil {
+		pending := atomic.AddInt64(&w.pending, int64(delta))
+		w.WaitGroup.Add(delta)
+
+		if pending == 0 {
+			w.drain()
+		}
+
+		return
+	}
+
+	for {
+		pending := atomic.LoadInt64(&w.pending)
+		if pending+int64(delta) < 0 {
+			w.errs.Error(ErrNegativeCounter)
+			return
+		}
+
+		if atomic.CompareAndSwapInt64(&w.pending, pending, pending+int64(delta)) {
+			w.WaitGroup.Add(delta)
+
+			if pending+int64(delta) == 0 {
+				w.drain()
+			}
+
+			return
+		}
+	}
+}
+
+// drain closes the channel returned by drainedSig.
+func (w *waitBackground) drain() {
+	w.drainedMu.Lock()
+	defer w.drainedMu.Unlock()
+
+	if w.drained != nil {
+		close(w.drained)
+		w.drained = nil
+	}
+}
+
+// drainedSig returns a channel that's closed the next time the counter
+// drops to zero.
+func (w *waitBackground) drainedSig() <-chan struct{} {
+	w.drainedMu.Lock()
+	defer w.drainedMu.Unlock()
+
+	if w.drained == nil {
+		w.drained = make(chan struct{})
+	}
+
+	return w.drained
+}
+
+// Done decrements the WaitGroup counter by one.
+func (w *waitBackground) Done() {
+	w.Add(-1)
+}
+
+// Pending returns the current value of the WaitGroup counter.
+func (w *waitBackground) Pending() int {
+	return int(atomic.LoadInt64(&w.pending))
+}
+
 // Wait blocks until Backgrounds's and Backgrounds's children counters are zero.
 func (w *waitBackground) Wait() {
 	w.WaitGroup.Wait()
 	w.group.Wait()
 }
 
+// WaitContext blocks until Backgrounds's and Backgrounds's children counters
+// are zero or ctx is done.
+func (w *waitBackground) WaitContext(ctx context.Context) error {
+	for {
+		// the signal is taken before the counter is checked,
+		// so the drop to zero in between isn't missed
+		drained := w.drainedSig()
+
+		if atomic.LoadInt64(&w.pending) <= 0 {
+			break
+		}
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return w.group.WaitContext(ctx)
+}
+
 func (w *waitBackground) DependsOn(children ...Background) Background {
 	return withDependency(w, children...)
 }
+
+// WaitAny blocks until the waiting for any of bgs is complete, i.e. its Wait
+// would return, and returns its index. Background.Wait keeps wait-all
+// semantics: it waits for all waitable Backgrounds in the tree.
+//
+// WaitAny with no Backgrounds blocks forever.
+func WaitAny(bgs ...Background) int {
+	i, _ := WaitAnyContext(context.Background(), bgs...)
+	return i
+}
+
+// WaitAnyContext is like WaitAny, but returns -1 and ctx's error if ctx is
+// done before the waiting for any of bgs is complete.
+func WaitAnyContext(ctx context.Context, bgs ...Background) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	first := make(chan int, len(bgs))
+
+	for i, bg := range bgs {
+		go func(i int, bg Background) {
+			if bg.WaitContext(ctx) == nil {
+				first <- i
+			}
+		}(i, bg)
+	}
+
+	select {
+	case i := <-first:
+		return i, nil
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+}
+
+// WaitAnySubtree is like WaitAnyContext, but waits for the subtrees of bg
+// annotated with names and returns the name of the first drained subtree.
+//
+// Returns error wrapping ErrSubtreeNotFound if any of the subtrees is not found.
+func WaitAnySubtree(ctx context.Context, bg Background, names ...string) (string, error) {
+	subs := make([]Background, len(names))
+
+	for i, name := range names {
+		if subs[i] = Subtree(bg, name); subs[i] == nil {
+			return "", fmt.Errorf("%s: %w", name, ErrSubtreeNotFound)
+		}
+	}
+
+	i, err := WaitAnyContext(ctx, subs...)
+	if err != nil {
+		return "", err
+	}
+
+	return names[i], nil
+}
+
+// Drain waits for all WaitGroup counters in bg's tree to reach zero
+// and then gracefully shuts down bg, both bounded by ctx.
+//
+// Errors are annotated with the phase that failed: "wait" if ctx is done
+// before the counters reach zero, in which case bg is not shut down,
+// or "shutdown" otherwise.
+func Drain(ctx context.Context, bg Background) error {
+	if err := bg.WaitContext(ctx); err != nil {
+		return fmt.Errorf("wait: %w", err)
+	}
+
+	if err := bg.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package background
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// warmupFailuresKey is a private Value key for the failures of the optional
+// warmup tasks.
+type warmupFailuresKey struct{}
+
+// warmupFailures holds the failures of the optional warmup tasks.
+type warmupFailures struct {
+	errs []error
+
+	mu sync.Mutex
+}
+
+// WarmupTask is a task run by WithWarmup.
+type WarmupTask struct {
+	// Run runs the task.
+	Run func(ctx context.Context) error
+
+	// Optional tasks don't block readiness nor fail the Background when
+	// they fail, so the Background degrades instead, see WarmupFailures.
+	Optional bool
+}
+
+// WithWarmup returns new Background that runs tasks at startup with at most
+// limit of them running simultaneously, and becomes ready once all tasks
+// are complete and none of the required ones failed. If limit is less than 1,
+// the number of simultaneously running tasks is not limited.
+//
+// The first error returned by a required task is returned by the Background's
+// Err. The failures of optional tasks don't affect Err: they mark
+// the Background as degraded, see Degraded, and are returned by
+// WarmupFailures. The ctx passed to tasks is canceled when the Background's
+// closing begins, the closing waits for the running tasks and the tasks that
+// haven't started yet are skipped.
+func WithWarmup(limit int, tasks ...WarmupTask) Background {
+	var (
+		failures = &warmupFailures{}
+		errs     = withErrorGroup()
+		health   = withHealth()
+		s        = withShutdown(errs, health, withValue(warmupFailuresKey{}, failures))
+		r        = withReadiness(s)
+	)
+
+	report := func(err error) {
+		health.Degrade("warmup: " + failures.add(err).Error())
+	}
+
+	goLabeled(caller(2), func() {
+		defer s.Done()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		finished := make(chan bool, 1)
+		go func() { finished <- warmup(ctx, limit, tasks, errs, report) }()
+
+		select {
+		case ok := <-finished:
+			if ok {
+				r.Ok()
+			}
+		case <-s.End():
+			cancel()
+			<-finished
+
+			return
+		}
+
+		<-s.End()
+	})
+
+	return r
+}
+
+// warmup runs tasks and returns true if none of the required ones failed.
+// The failures of the optional tasks are passed to report.
+func warmup(ctx context.Context, limit int, tasks []WarmupTask, errs ErrTail, report func(err error)) bool {
+	var (
+		wg     sync.WaitGroup
+		sem    chan struct{}
+		failed int32
+	)
+
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
+	for _, task := range tasks {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+
+		go func(task WarmupTask) {
+			defer wg.Done()
+
+			if err := task.Run(ctx); err != nil {
+				if task.Optional {
+					report(err)
+				} else {
+					errs.Error(err)
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+
+			if sem != nil {
+				<-sem
+			}
+		}(task)
+	}
+
+	wg.Wait()
+
+	return ctx.Err() == nil && atomic.LoadInt32(&failed) == 0
+}
+
+// add records err and returns all recorded failures merged.
+func (f *warmupFailures) add(err error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errs = append(f.errs, err)
+
+	return &MergedErrors{Errs: append([]error(nil), f.errs...)}
+}
+
+// WarmupFailures returns the failures of the optional tasks of the first
+// Background created with WithWarmup in bg's tree, in the order they failed.
+// It returns nil if there is no such Background or none of its optional
+// tasks failed.
+func WarmupFailures(bg Background) []error {
+	f, ok := bg.Value(warmupFailuresKey{}).(*warmupFailures)
+	if !ok {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]error(nil), f.errs...)
+}
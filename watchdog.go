@@ -0,0 +1,84 @@
+package background
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WaitWithWatchdog is like bg.Wait, but calls onStall when none of the
+// WaitGroup counters in bg's tree changes for d, so stalled waits in batch
+// jobs are reported instead of hanging silently:
+//
+//	background.WaitWithWatchdog(bg, time.Minute, func(pending []string) {
+//		log.Printf("wait stalled: %s", strings.Join(pending, ", "))
+//	})
+//
+// The pending are the Backgrounds created with WithWait and WithSafeWait
+// that have non-zero counters, described by the annotations on the path
+// to them and their counters, e.g. "workers: 3 pending". onStall is called
+// every d until the waiting progresses or is complete.
+//
+// The stalls are detected by the clock assigned to bg with WithClock
+// or by the system clock.
+func WaitWithWatchdog(bg Background, d time.Duration, onStall func(pending []string)) {
+	done := make(chan struct{})
+
+	go func() {
+		bg.Wait()
+		close(done)
+	}()
+
+	timer := clockOf(bg).NewTimer(d)
+	defer timer.Stop()
+
+	var (
+		changes, _ = waitProgress(bg)
+		pending    []string
+	)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C():
+		}
+
+		last := changes
+
+		changes, pending = waitProgress(bg)
+		if changes == last && len(pending) > 0 {
+			onStall(pending)
+		}
+
+		timer.Reset(d)
+	}
+}
+
+// waitProgress returns the total number of changes of the WaitGroup counters
+// in bg's tree and the descriptions of the non-zero counters.
+func waitProgress(bg Background) (changes int64, pending []string) {
+	walkPath(bg, func(bg Background, path []string) {
+		w, ok := bg.(*waitBackground)
+		if !ok {
+			return
+		}
+
+		changes += atomic.LoadInt64(&w.changes)
+
+		n := w.Pending()
+		if n == 0 {
+			return
+		}
+
+		if len(path) == 0 {
+			pending = append(pending, fmt.Sprintf("%d pending", n))
+			return
+		}
+
+		pending = append(pending, fmt.Sprintf("%s: %d pending", strings.Join(path, ": "), n))
+	})
+
+	return changes, pending
+}
@@ -0,0 +1,10 @@
+module github.com/lefelys/background/winsvc
+
+go 1.13
+
+require (
+	github.com/lefelys/background v0.0.0
+	golang.org/x/sys v0.0.0-20210119212857-b64e53b001e4
+)
+
+replace github.com/lefelys/background => ../
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+// Package winsvc runs applications built on package background as Windows
+// services, mapping service control requests to the Background's lifecycle:
+//
+//	Stop, Shutdown -> Background.Shutdown
+//	Pause          -> Background.Pause
+//	Continue       -> Background.Resume
+//
+// The package is a separate module to keep package background free of
+// dependencies.
+package winsvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/lefelys/background"
+	"golang.org/x/sys/windows/svc"
+)
+
+const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptPauseAndContinue
+
+// Handler implements svc.Handler for the application's Background.
+type Handler struct {
+	// Background is the application's Background.
+	Background background.Background
+
+	// ShutdownTimeout limits the time of Background's shutdown.
+	// Zero means no limit.
+	ShutdownTimeout time.Duration
+
+	// OnError is called with the Background's shutdown error, if not nil.
+	OnError func(err error)
+}
+
+// Run runs the service name with Handler for bg.
+// It blocks until the service is stopped.
+func Run(name string, bg background.Background, shutdownTimeout time.Duration) error {
+	return svc.Run(name, &Handler{
+		Background:      bg,
+		ShutdownTimeout: shutdownTimeout,
+	})
+}
+
+// Execute reports the service as running once the Background is ready and
+// handles service control requests until the service is stopped.
+//
+// If the Background's shutdown returns an error, Execute returns
+// service-specific exit code 1.
+func (h *Handler) Execute(_ []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StartPending}
+
+	if !h.start(r, s) {
+		return h.stop(s)
+	}
+
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for c := range r {
+		switch c.Cmd {
+		case svc.Interrogate:
+			s <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			return h.stop(s)
+		case svc.Pause:
+			h.Background.Pause()
+			s <- svc.Status{State: svc.Paused, Accepts: accepts}
+		case svc.Continue:
+			h.Background.Resume()
+			s <- svc.Status{State: svc.Running, Accepts: accepts}
+		}
+	}
+
+	return false, 0
+}
+
+// start waits until the Background is ready, handling the requests sent
+// meanwhile. It returns false if Stop or Shutdown is requested first.
+func (h *Handler) start(r <-chan svc.ChangeRequest, s chan<- svc.Status) bool {
+	for {
+		select {
+		case <-h.Background.Ready():
+			return true
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				return false
+			}
+		}
+	}
+}
+
+func (h *Handler) stop(s chan<- svc.Status) (bool, uint32) {
+	s <- svc.Status{State: svc.StopPending}
+
+	ctx := context.Background()
+
+	if h.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, h.ShutdownTimeout)
+		defer cancel()
+	}
+
+	if err := h.Background.Shutdown(ctx); err != nil {
+		if h.OnError != nil {
+			h.OnError(err)
+		}
+
+		return true, 1
+	}
+
+	return false, 0
+}